@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/base64"
+	"flag"
 	"io"
 	"log"
 	"main/modules"
@@ -12,7 +13,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"unicode"
 
 	tg "github.com/amarnathcjd/gogram/telegram"
 	_ "github.com/joho/godotenv/autoload"
@@ -25,16 +25,24 @@ var LoadModules = os.Getenv("ENV") != "development"
 
 var aesEncryptedTextRegex = regexp.MustCompile(`(?i)^(?:U2FsdGVkX1[0-9A-Za-z+/=]{8,}|(?:[0-9A-F]{2}){16,}|[A-Za-z0-9+/]{16,}={0,2})$`)
 
-func containsNonEnglishLetters(text string) bool {
-	for _, r := range text {
-		if unicode.IsLetter(r) && r > unicode.MaxASCII {
-			return true
-		}
+func main() {
+	migrateStore := flag.Bool("migrate-store", false, "copy database.db into the SQLite store at SQLITE_PATH and exit")
+	migrateDryRun := flag.Bool("migrate-dry-run", false, "report pending schema migrations without applying them and exit")
+	flag.Parse()
+	if *migrateDryRun {
+		runSchemaMigrations(true)
+		return
+	}
+	// The migrations rewrite store records in database.db, which only bolt
+	// reads from; --migrate-store still needs them before copying.
+	if *migrateStore || db.StoreBackend() == db.BackendBolt {
+		runSchemaMigrations(false)
+	}
+	if *migrateStore {
+		runStoreMigration()
+		return
 	}
-	return false
-}
 
-func main() {
 	logZap, err := os.OpenFile("log.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
 		log.Fatal(err)
@@ -81,6 +89,52 @@ func main() {
 	client.Logger.Info("Bot stopped")
 }
 
+// runSchemaMigrations brings database.db up to the current schema version.
+func runSchemaMigrations(dryRun bool) {
+	from, err := db.SchemaVersion()
+	if err != nil {
+		log.Fatalf("[schema] %v", err)
+	}
+	results, err := db.RunMigrations(dryRun)
+	if err != nil {
+		log.Fatalf("[schema] %v", err)
+	}
+
+	verb := "applied"
+	if dryRun {
+		verb = "would apply"
+	}
+	for _, r := range results {
+		log.Printf("[schema] %s v%d %s: %d records changed", verb, r.Version, r.Name, r.Changed)
+	}
+	switch {
+	case dryRun:
+		log.Printf("[schema] database at v%d, latest v%d, %d migrations pending", from, db.LatestSchemaVersion(), len(results))
+	case len(results) > 0:
+		log.Printf("[schema] migrated database from v%d to v%d", from, db.LatestSchemaVersion())
+	}
+}
+
+// runStoreMigration copies the bbolt data covered by db.Store into SQLite.
+func runStoreMigration() {
+	path := db.SQLitePath()
+	dst, err := db.OpenSQLiteStore(path)
+	if err != nil {
+		log.Fatalf("[migrate] opening %s: %v", path, err)
+	}
+	defer dst.Close()
+	defer db.CloseDB()
+
+	counts, err := db.MigrateBoltStore(dst)
+	if err != nil {
+		log.Fatalf("[migrate] %v", err)
+	}
+	for _, kind := range []string{"notes", "filters", "warns", "warn_settings", "welcome", "goodbye", "rules", "blacklist", "blacklist_settings", "sticker_packs"} {
+		log.Printf("[migrate] %s: %d", kind, counts[kind])
+	}
+	log.Printf("[migrate] done, set DB_BACKEND=sqlite to use %s", path)
+}
+
 func b64toBytes(s string) []byte {
 	a, _ := base64.StdEncoding.DecodeString(s)
 	return a
@@ -0,0 +1,287 @@
+package modules
+
+import (
+	"fmt"
+	"main/modules/db"
+	"strconv"
+	"strings"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+const actionLogPageSize = 10
+
+var actionLogTypes = []string{"ban", "unban", "tban", "mute", "unmute", "tmute", "kick", "warn", "twarn", "fban", "unfban"}
+
+func isActionLogType(s string) bool {
+	for _, t := range actionLogTypes {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ActionLogHandler - /actionlog [user] [type] [since]
+func ActionLogHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Action logs are only available in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "ban") {
+		m.Reply("You need Ban Users permission to view the action log")
+		return nil
+	}
+
+	var (
+		userID     int64
+		actionType string
+		since      time.Time
+	)
+
+	if m.IsReply() {
+		reply, err := m.GetReplyMessage()
+		if err == nil {
+			userID = reply.SenderID()
+		}
+	}
+
+	for _, arg := range strings.Fields(m.Args()) {
+		arg = strings.ToLower(arg)
+		switch {
+		case isActionLogType(arg):
+			actionType = arg
+		case arg[0] >= '0' && arg[0] <= '9' && strings.IndexFunc(arg, func(r rune) bool { return r < '0' || r > '9' }) != -1:
+			d, err := parseAdminDuration(arg)
+			if err != nil || d <= 0 {
+				m.Reply("Invalid duration. Examples: 1h, 1d, 1w")
+				return nil
+			}
+			since = time.Now().Add(-d)
+		default:
+			user, err := m.Client.ResolvePeer(arg)
+			if err != nil {
+				m.Reply("I couldn't find that user.\nUsage: /actionlog [user] [type] [since]")
+				return nil
+			}
+			userID = m.Client.GetPeerID(user)
+		}
+	}
+
+	text, kb := renderActionLogPage(m.Client, m.ChatID(), 0, userID, actionType, since)
+	m.Reply(text, &tg.SendOptions{ReplyMarkup: kb})
+	return nil
+}
+
+func ActionLogCallback(c *tg.CallbackQuery) error {
+	data := c.DataString()
+	if !strings.HasPrefix(data, "alog_") {
+		return nil
+	}
+
+	// alog_<page>_<userID>_<type>_<sinceUnix>
+	parts := strings.Split(strings.TrimPrefix(data, "alog_"), "_")
+	if len(parts) != 4 {
+		return nil
+	}
+
+	if !IsUserAdmin(c.Client, c.SenderID, c.ChatID, "ban") {
+		c.Answer("Only admins can browse the action log", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	page, _ := strconv.Atoi(parts[0])
+	userID, _ := strconv.ParseInt(parts[1], 10, 64)
+	actionType := parts[2]
+	if actionType == "-" {
+		actionType = ""
+	}
+	var since time.Time
+	if sinceUnix, _ := strconv.ParseInt(parts[3], 10, 64); sinceUnix > 0 {
+		since = time.Unix(sinceUnix, 0)
+	}
+
+	text, kb := renderActionLogPage(c.Client, c.ChatID, page, userID, actionType, since)
+	c.Edit(text, &tg.SendOptions{ReplyMarkup: kb})
+	c.Answer("")
+	return nil
+}
+
+func renderActionLogPage(client *tg.Client, chatID int64, page int, userID int64, actionType string, since time.Time) (string, tg.ReplyMarkup) {
+	entries, err := db.GetActionLogs(chatID, &db.ActionLogQuery{
+		UserID: userID,
+		Type:   actionType,
+		Since:  since,
+	})
+	if err != nil {
+		return "Failed to read action log", nil
+	}
+
+	if len(entries) == 0 {
+		return "No matching actions recorded in this chat", nil
+	}
+
+	pages := (len(entries) + actionLogPageSize - 1) / actionLogPageSize
+	if page < 0 {
+		page = 0
+	}
+	if page >= pages {
+		page = pages - 1
+	}
+
+	start := page * actionLogPageSize
+	end := min(start+actionLogPageSize, len(entries))
+
+	names := make(map[int64]string)
+	nameOf := func(id int64) string {
+		if name, ok := names[id]; ok {
+			return name
+		}
+		name := strconv.FormatInt(id, 10)
+		if user, err := client.GetUser(id); err == nil && user != nil {
+			name = user.FirstName
+		}
+		names[id] = name
+		return name
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>Action Log</b> (%d entries)\n", len(entries)))
+	if userID != 0 {
+		sb.WriteString(fmt.Sprintf("<b>User:</b> %s\n", nameOf(userID)))
+	}
+	if actionType != "" {
+		sb.WriteString(fmt.Sprintf("<b>Type:</b> %s\n", actionType))
+	}
+	if !since.IsZero() {
+		sb.WriteString(fmt.Sprintf("<b>Since:</b> %s\n", since.Format("2006-01-02 15:04")))
+	}
+	sb.WriteString("\n")
+
+	for _, entry := range entries[start:end] {
+		sb.WriteString(fmt.Sprintf("<code>%s</code> <b>%s</b> %s <i>by</i> %s",
+			entry.Timestamp.Format("01-02 15:04"), entry.Type, nameOf(entry.UserID), nameOf(entry.AdminID)))
+		if duration, ok := entry.Data["duration"].(string); ok && duration != "" {
+			sb.WriteString(" for " + duration)
+		}
+		if reason, ok := entry.Data["reason"].(string); ok && reason != "" {
+			sb.WriteString("\n  <b>Reason:</b> " + reason)
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("\n<i>Page %d/%d</i>", page+1, pages))
+
+	if pages == 1 {
+		return sb.String(), nil
+	}
+
+	typeKey := actionType
+	if typeKey == "" {
+		typeKey = "-"
+	}
+	var sinceUnix int64
+	if !since.IsZero() {
+		sinceUnix = since.Unix()
+	}
+	pageData := func(p int) string {
+		return fmt.Sprintf("alog_%d_%d_%s_%d", p, userID, typeKey, sinceUnix)
+	}
+
+	b := tg.Button
+	var row []tg.KeyboardButton
+	if page > 0 {
+		row = append(row, b.Data("« Prev", pageData(page-1)))
+	}
+	if page < pages-1 {
+		row = append(row, b.Data("Next »", pageData(page+1)))
+	}
+
+	return sb.String(), tg.NewKeyboard().AddRow(row...).Build()
+}
+
+// ActionLogRetentionHandler - /actionlogretention [entries] [days]
+func ActionLogRetentionHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Action logs are only available in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info rights to configure action log retention")
+		return nil
+	}
+
+	settings, err := db.GetActionLogSettings(m.ChatID())
+	if err != nil {
+		m.Reply("Failed to read action log settings")
+		return nil
+	}
+
+	args := strings.Fields(m.Args())
+	if len(args) == 0 {
+		m.Reply(fmt.Sprintf(`<b>Action Log Retention</b>
+
+<b>Max entries:</b> %d
+<b>Max age:</b> %d days
+
+Usage: /actionlogretention [entries] [days]
+Use 0 to disable a limit`, settings.MaxEntries, settings.MaxAgeDays))
+		return nil
+	}
+
+	entries, err := strconv.Atoi(args[0])
+	if err != nil || entries < 0 {
+		m.Reply("Entry limit must be a non-negative number")
+		return nil
+	}
+	settings.MaxEntries = entries
+
+	if len(args) > 1 {
+		days, err := strconv.Atoi(args[1])
+		if err != nil || days < 0 {
+			m.Reply("Day limit must be a non-negative number")
+			return nil
+		}
+		settings.MaxAgeDays = days
+	}
+
+	if err := db.SetActionLogSettings(m.ChatID(), settings); err != nil {
+		m.Reply("Failed to update action log settings")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("Action log will keep up to %d entries for %d days", settings.MaxEntries, settings.MaxAgeDays))
+	return nil
+}
+
+func registerActionLogHandlers() {
+	c := Client
+	c.On("cmd:actionlog", ActionLogHandler)
+	c.On("cmd:actionlogretention", ActionLogRetentionHandler)
+	c.On("callback:alog_", ActionLogCallback)
+}
+
+func init() {
+	QueueHandlerRegistration(registerActionLogHandlers)
+
+	Mods.AddModule("ActionLog", `<b>Action Log</b>
+
+Every ban, mute, kick and warn issued through the bot is kept per chat.
+
+<b>Commands:</b>
+/actionlog [user] [type] [since] - Browse recorded actions (newest first)
+/actionlogretention [entries] [days] - Set how many entries and days to keep
+
+<b>Filters:</b>
+• user - @username, ID, or reply to a message
+• type - ban, unban, tban, mute, unmute, tmute, kick, warn, twarn, fban, unfban
+• since - 1h, 2d, 1w
+
+<b>Examples:</b>
+<code>/actionlog</code>
+<code>/actionlog @user ban</code>
+<code>/actionlog mute 7d</code>`)
+}
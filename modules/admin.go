@@ -165,6 +165,7 @@ func BanUserHandle(m *tg.NewMessage) error {
 		m.Reply(adminFriendlyError(opErr, "ban"))
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{Category: LogCategoryBans, Action: "ban", TargetID: targetID, Reason: reason})
 
 	b := tg.Button
 	m.Reply(msg, &tg.SendOptions{
@@ -209,16 +210,17 @@ func UnbanUserHandle(m *tg.NewMessage) error {
 		return nil
 	}
 
-	msg, opErr := performUnban(m.Client, m.ChatID(), user)
+	msg, opErr := performUnban(m.Client, m.ChatID(), user, m.SenderID())
 	if opErr != nil {
 		m.Reply(adminFriendlyError(opErr, "unban"))
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{Category: LogCategoryBans, Action: "unban", TargetID: targetID})
 	m.Reply(msg)
 	return nil
 }
 
-func performUnban(client *tg.Client, chatID int64, user tg.InputPeer) (string, error) {
+func performUnban(client *tg.Client, chatID int64, user tg.InputPeer, adminID int64) (string, error) {
 	channel, _ := client.GetChannel(chatID)
 	if channel != nil && !CanBot(client, channel, "ban") {
 		return "", errors.New("missing bot rights")
@@ -228,6 +230,8 @@ func performUnban(client *tg.Client, chatID int64, user tg.InputPeer) (string, e
 	if err != nil || !done {
 		return "", err
 	}
+	RecordAction(chatID, client.GetPeerID(user), adminID, "unban", nil)
+
 	name := GetPeerDisplayName(client, user)
 	return fmt.Sprintf("Done. %s has been unbanned.", name), nil
 }
@@ -244,16 +248,17 @@ func KickUserHandle(m *tg.NewMessage) error {
 		return nil
 	}
 
-	msg, opErr := performKick(m.Client, m.ChatID(), user, reason)
+	msg, opErr := performKick(m.Client, m.ChatID(), user, reason, m.SenderID())
 	if opErr != nil {
 		m.Reply(adminFriendlyError(opErr, "kick"))
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{Category: LogCategoryKicks, Action: "kick", TargetID: targetID, Reason: reason})
 	m.Reply(msg)
 	return nil
 }
 
-func performKick(client *tg.Client, chatID int64, user tg.InputPeer, reason string) (string, error) {
+func performKick(client *tg.Client, chatID int64, user tg.InputPeer, reason string, adminID int64) (string, error) {
 	channel, _ := client.GetChannel(chatID)
 	if channel != nil && !CanBot(client, channel, "ban") {
 		return "", errors.New("missing bot rights")
@@ -264,6 +269,9 @@ func performKick(client *tg.Client, chatID int64, user tg.InputPeer, reason stri
 		return "", err
 	}
 
+	userID := client.GetPeerID(user)
+	RecordAction(chatID, userID, adminID, "kick", map[string]interface{}{"reason": reason})
+
 	name := GetPeerDisplayName(client, user)
 	msg := fmt.Sprintf("Done. %s has been kicked.", name)
 	if reason != "" {
@@ -345,6 +353,8 @@ func TbanUserHandle(m *tg.NewMessage) error {
 		m.Reply(adminFriendlyError(opErr, "temp-ban"))
 		return nil
 	}
+	duration, _ := parseAdminDuration(parts[0])
+	logMessageEvent(m, &LogEvent{Category: LogCategoryBans, Action: "tban", TargetID: targetID, Reason: reason, Duration: formatAdminDuration(duration)})
 
 	b := tg.Button
 	m.Reply(msg, &tg.SendOptions{
@@ -413,6 +423,8 @@ func TmuteUserHandle(m *tg.NewMessage) error {
 		m.Reply(adminFriendlyError(opErr, "temp-mute"))
 		return nil
 	}
+	duration, _ := parseAdminDuration(parts[0])
+	logMessageEvent(m, &LogEvent{Category: LogCategoryMutes, Action: "tmute", TargetID: targetID, Reason: reason, Duration: formatAdminDuration(duration)})
 
 	b := tg.Button
 	m.Reply(msg, &tg.SendOptions{
@@ -471,6 +483,7 @@ func MuteUserHandle(m *tg.NewMessage) error {
 		m.Reply(adminFriendlyError(opErr, "mute"))
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{Category: LogCategoryMutes, Action: "mute", TargetID: targetID, Reason: reason})
 
 	b := tg.Button
 	m.Reply(msg, &tg.SendOptions{
@@ -515,16 +528,17 @@ func UnmuteUserHandle(m *tg.NewMessage) error {
 		return nil
 	}
 
-	msg, opErr := performUnmute(m.Client, m.ChatID(), user)
+	msg, opErr := performUnmute(m.Client, m.ChatID(), user, m.SenderID())
 	if opErr != nil {
 		m.Reply(adminFriendlyError(opErr, "unmute"))
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{Category: LogCategoryMutes, Action: "unmute", TargetID: targetID})
 	m.Reply(msg)
 	return nil
 }
 
-func performUnmute(client *tg.Client, chatID int64, user tg.InputPeer) (string, error) {
+func performUnmute(client *tg.Client, chatID int64, user tg.InputPeer, adminID int64) (string, error) {
 	channel, _ := client.GetChannel(chatID)
 	if channel != nil && !CanBot(client, channel, "ban") {
 		return "", errors.New("missing bot rights")
@@ -534,6 +548,8 @@ func performUnmute(client *tg.Client, chatID int64, user tg.InputPeer) (string,
 	if err != nil || !done {
 		return "", err
 	}
+	RecordAction(chatID, client.GetPeerID(user), adminID, "unmute", nil)
+
 	name := GetPeerDisplayName(client, user)
 	return fmt.Sprintf("Done. %s has been unmuted.", name), nil
 }
@@ -560,6 +576,7 @@ func SbanUserHandle(m *tg.NewMessage) error {
 		replyTemp(m, adminFriendlyError(opErr, "ban"), 6)
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{Category: LogCategoryBans, Action: "sban", TargetID: m.Client.GetPeerID(user)})
 	replyTemp(m, "Done.", 3)
 	return nil
 }
@@ -586,6 +603,7 @@ func SmuteUserHandle(m *tg.NewMessage) error {
 		replyTemp(m, adminFriendlyError(opErr, "mute"), 6)
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{Category: LogCategoryMutes, Action: "smute", TargetID: m.Client.GetPeerID(user)})
 	replyTemp(m, "Done.", 3)
 	return nil
 }
@@ -607,11 +625,12 @@ func SkickUserHandle(m *tg.NewMessage) error {
 		replyTemp(m, "I couldn't find who to kick. "+adminUsage("kick"), 6)
 		return nil
 	}
-	_, opErr := performKick(m.Client, m.ChatID(), user, "")
+	_, opErr := performKick(m.Client, m.ChatID(), user, "", m.SenderID())
 	if opErr != nil {
 		replyTemp(m, adminFriendlyError(opErr, "kick"), 6)
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{Category: LogCategoryKicks, Action: "skick", TargetID: m.Client.GetPeerID(user)})
 	replyTemp(m, "Done.", 3)
 	return nil
 }
@@ -671,6 +690,13 @@ func DBanUserHandle(m *tg.NewMessage) error {
 		m.Reply(adminFriendlyError(opErr, "ban"))
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryBans,
+		Action:   "dban",
+		TargetID: m.Client.GetPeerID(peer),
+		Reason:   strings.TrimSpace(m.Args()),
+		Details:  "Message deleted: " + trimString(reply.Text(), 200),
+	})
 	m.Reply(msg)
 	return nil
 }
@@ -705,6 +731,13 @@ func DMuteUserHandle(m *tg.NewMessage) error {
 		m.Reply(adminFriendlyError(opErr, "mute"))
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryMutes,
+		Action:   "dmute",
+		TargetID: m.Client.GetPeerID(peer),
+		Reason:   strings.TrimSpace(m.Args()),
+		Details:  "Message deleted: " + trimString(reply.Text(), 200),
+	})
 	m.Reply(msg)
 	return nil
 }
@@ -734,11 +767,18 @@ func DKickUserHandle(m *tg.NewMessage) error {
 		return nil
 	}
 	m.Client.DeleteMessages(m.ChatID(), []int32{int32(reply.ID)})
-	msg, opErr := performKick(m.Client, m.ChatID(), peer, strings.TrimSpace(m.Args()))
+	msg, opErr := performKick(m.Client, m.ChatID(), peer, strings.TrimSpace(m.Args()), m.SenderID())
 	if opErr != nil {
 		m.Reply(adminFriendlyError(opErr, "kick"))
 		return nil
 	}
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryKicks,
+		Action:   "dkick",
+		TargetID: m.Client.GetPeerID(peer),
+		Reason:   strings.TrimSpace(m.Args()),
+		Details:  "Message deleted: " + trimString(reply.Text(), 200),
+	})
 	m.Reply(msg)
 	return nil
 }
@@ -857,6 +897,12 @@ func PurgeMessagesHandle(m *tg.NewMessage) error {
 		m.Client.DeleteMessages(m.ChatID(), msgIDs)
 	}
 
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryPurges,
+		Action:   "purge",
+		Details:  fmt.Sprintf("%d messages (%d to %d)", len(msgIDs), startMsgID, endMsgID),
+	})
+
 	statusMsg, _ := m.Reply(fmt.Sprintf("✅ Purged %d messages.", len(msgIDs)))
 
 	// Auto-delete the status message after 5 seconds
@@ -873,6 +919,11 @@ func LockHandle(m *tg.NewMessage) error {
 		m.Reply("You don't have permission to lock chat settings here.")
 		return nil
 	}
+	if fields := strings.Fields(strings.ToLower(m.Args())); len(fields) > 0 {
+		if lock := findBotLock(fields[0]); lock != nil {
+			return lockBotLock(m, lock, fields[1:])
+		}
+	}
 	if !CanBot(m.Client, m.Channel, "ban") {
 		m.Reply("I need admin permission to manage chat restrictions.")
 		return nil
@@ -880,7 +931,7 @@ func LockHandle(m *tg.NewMessage) error {
 
 	args := strings.ToLower(strings.TrimSpace(m.Args()))
 	if args == "" {
-		m.Reply("Please specify what to lock: `all`, `messages`, `media`, `stickers`, `gifs`, `polls`, `invite`, `pin`, `info`")
+		m.Reply("Please specify what to lock: `all`, `messages`, `media`, `stickers`, `gifs`, `polls`, `invite`, `pin`, `info`\nBot-enforced: " + botLockNames())
 		return nil
 	}
 
@@ -953,7 +1004,7 @@ func LockHandle(m *tg.NewMessage) error {
 		lockMsg += "Change chat info"
 		updated = true
 	default:
-		m.Reply("Unknown lock type. Available: `all`, `messages`, `media`, `stickers`, `gifs`, `polls`, `invite`, `pin`, `info`")
+		m.Reply("Unknown lock type. Available: `all`, `messages`, `media`, `stickers`, `gifs`, `polls`, `invite`, `pin`, `info`\nBot-enforced: " + botLockNames())
 		return nil
 	}
 
@@ -964,6 +1015,7 @@ func LockHandle(m *tg.NewMessage) error {
 			m.Reply(adminFriendlyError(err, "update chat permissions"))
 			return nil
 		}
+		logMessageEvent(m, &LogEvent{Category: LogCategoryLocks, Action: "lock", Details: strings.TrimPrefix(lockMsg, "🔒 Locked: ")})
 		m.Reply(lockMsg)
 	}
 
@@ -975,6 +1027,11 @@ func UnlockHandle(m *tg.NewMessage) error {
 		m.Reply("You don't have permission to unlock chat settings here.")
 		return nil
 	}
+	if fields := strings.Fields(strings.ToLower(m.Args())); len(fields) > 0 {
+		if lock := findBotLock(fields[0]); lock != nil {
+			return unlockBotLock(m, lock)
+		}
+	}
 	if !CanBot(m.Client, m.Channel, "ban") {
 		m.Reply("I need admin permission to manage chat restrictions.")
 		return nil
@@ -982,7 +1039,7 @@ func UnlockHandle(m *tg.NewMessage) error {
 
 	args := strings.ToLower(strings.TrimSpace(m.Args()))
 	if args == "" {
-		m.Reply("Please specify what to unlock: `all`, `messages`, `media`, `stickers`, `gifs`, `polls`, `invite`, `pin`, `info`")
+		m.Reply("Please specify what to unlock: `all`, `messages`, `media`, `stickers`, `gifs`, `polls`, `invite`, `pin`, `info`\nBot-enforced: " + botLockNames())
 		return nil
 	}
 
@@ -1055,7 +1112,7 @@ func UnlockHandle(m *tg.NewMessage) error {
 		unlockMsg += "Change chat info"
 		updated = true
 	default:
-		m.Reply("Unknown unlock type. Available: `all`, `messages`, `media`, `stickers`, `gifs`, `polls`, `invite`, `pin`, `info`")
+		m.Reply("Unknown unlock type. Available: `all`, `messages`, `media`, `stickers`, `gifs`, `polls`, `invite`, `pin`, `info`\nBot-enforced: " + botLockNames())
 		return nil
 	}
 
@@ -1066,6 +1123,7 @@ func UnlockHandle(m *tg.NewMessage) error {
 			m.Reply(adminFriendlyError(err, "update chat permissions"))
 			return nil
 		}
+		logMessageEvent(m, &LogEvent{Category: LogCategoryLocks, Action: "unlock", Details: strings.TrimPrefix(unlockMsg, "🔓 Unlocked: ")})
 		m.Reply(unlockMsg)
 	}
 
@@ -1081,8 +1139,7 @@ func LocksHandle(m *tg.NewMessage) error {
 
 	defaultRights := channel.DefaultBannedRights
 	if defaultRights == nil {
-		m.Reply("📋 <b>Current Locks:</b>\n\nNo restrictions are currently active.")
-		return nil
+		defaultRights = &tg.ChatBannedRights{}
 	}
 
 	locks := "<b>📋 Current Locks:</b>\n\n"
@@ -1108,10 +1165,13 @@ func LocksHandle(m *tg.NewMessage) error {
 	checkLock(defaultRights.PinMessages, "Pin messages")
 	checkLock(defaultRights.ChangeInfo, "Change info")
 
-	if lockCount == 0 {
+	botStatus, botCount := botLocksStatus(m.ChatID())
+	locks += "\n<b>Bot-enforced:</b>\n" + botStatus
+
+	if lockCount+botCount == 0 {
 		locks += "\n<i>No restrictions are currently active.</i>"
 	} else {
-		locks += fmt.Sprintf("\n<i>Total locked: %d/10</i>", lockCount)
+		locks += fmt.Sprintf("\n<i>Total locked: %d/10 native, %d/%d bot-enforced</i>", lockCount, botCount, len(botLocks))
 	}
 
 	m.Reply(locks)
@@ -1196,13 +1256,13 @@ func AdminVerifyCallback(c *tg.CallbackQuery) error {
 	case "ban":
 		resultMsg, opErr = performBan(c.Client, c.ChatID, user, "", c.SenderID)
 	case "unban":
-		resultMsg, opErr = performUnban(c.Client, c.ChatID, user)
+		resultMsg, opErr = performUnban(c.Client, c.ChatID, user, c.SenderID)
 	case "kick":
-		resultMsg, opErr = performKick(c.Client, c.ChatID, user, "")
+		resultMsg, opErr = performKick(c.Client, c.ChatID, user, "", c.SenderID)
 	case "mute":
 		resultMsg, opErr = performMute(c.Client, c.ChatID, user, "", c.SenderID)
 	case "unmute":
-		resultMsg, opErr = performUnmute(c.Client, c.ChatID, user)
+		resultMsg, opErr = performUnmute(c.Client, c.ChatID, user, c.SenderID)
 	case "tban":
 		if len(parts) < 3 {
 			opErr = errors.New("missing duration")
@@ -1229,12 +1289,13 @@ func AdminVerifyCallback(c *tg.CallbackQuery) error {
 		if msgID != 0 {
 			c.Client.DeleteMessages(c.ChatID, []int32{msgID})
 		}
-		resultMsg, opErr = performKick(c.Client, c.ChatID, user, "")
+		resultMsg, opErr = performKick(c.Client, c.ChatID, user, "", c.SenderID)
 	}
 
 	if opErr != nil {
 		c.Answer(adminFriendlyError(opErr, action), &tg.CallbackOptions{Alert: true})
 	} else {
+		logCallbackEvent(c, &LogEvent{Category: actionLogCategory(action), Action: action, TargetID: int64(targetID)})
 		if resultMsg != "" {
 			c.Client.SendMessage(c.ChatID, resultMsg)
 		}
@@ -1479,11 +1540,15 @@ func init() {
 
 <b>Lock/Unlock:</b>
 /lock [type] - Lock chat permissions (messages, media, stickers, gifs, polls, invite, pin, info, all)
+/lock [type] [action] [duration] - Bot-enforced locks: url, forward, forwardchannel, inlinebot, button, mention, emoji, rtl, cyrillic, chinese, contact, location, voice, videonote, bots
 /unlock [type] - Unlock chat permissions
 /locks - View current lock status
 
+Bot-enforced locks delete offending messages and can also warn, mute, kick, ban, tmute or tban the sender (e.g. <code>/lock url tmute 1h</code>). Admins and approved users are exempt.
+
 <b>Info:</b>
 /id - Get user and chat IDs with detailed info
+/admincache - Reload the cached admin list after changing admins
 
 <b>Usage:</b>
 Reply to a user's message OR provide their @username or ID.
@@ -1521,6 +1586,4 @@ func registerAdminHandlers() {
 	c.On("cmd:locks", LocksHandle)
 	c.On("cmd:restart", RestartHandle, tg.CustomFilter(FilterOwner))
 	c.On("cmd:id", IDHandle)
-	c.On("cmd:gban", Gban, tg.CustomFilter(FilterOwner))
-	c.On("cmd:ungban", Ungban, tg.CustomFilter(FilterOwner))
 }
@@ -0,0 +1,218 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+const (
+	adminCacheTTL = 10 * time.Minute
+	// adminCacheMinRefresh stops /admincache from refetching the list on
+	// every call when it was loaded moments ago.
+	adminCacheMinRefresh = 30 * time.Second
+	// Telegram caps a chat at 50 admins plus bots; 200 is one request.
+	maxChatAdmins = 200
+)
+
+type chatAdmins struct {
+	members  map[int64]*tg.Participant
+	loadedAt time.Time
+}
+
+// adminCache holds each chat's admin list, loaded with the admins filter on
+// first use and refetched once it is older than adminCacheTTL or dropped by
+// a participant update.
+type adminCache struct {
+	mu    sync.RWMutex
+	chats map[int64]*chatAdmins
+}
+
+var chatAdminCache = &adminCache{chats: make(map[int64]*chatAdmins)}
+
+// adminCacheKey maps the -100 prefixed form of a channel ID to the bare ID
+// that m.ChatID() and participant updates use.
+func adminCacheKey(chatID int64) int64 {
+	if chatID < -1_000_000_000_000 {
+		return -1_000_000_000_000 - chatID
+	}
+	return chatID
+}
+
+func (ac *adminCache) get(client *tg.Client, chatID int64) (*chatAdmins, error) {
+	ac.mu.RLock()
+	ca, ok := ac.chats[adminCacheKey(chatID)]
+	ac.mu.RUnlock()
+	if ok && time.Since(ca.loadedAt) < adminCacheTTL {
+		return ca, nil
+	}
+	return ac.load(client, chatID)
+}
+
+func (ac *adminCache) load(client *tg.Client, chatID int64) (*chatAdmins, error) {
+	members, _, err := client.GetChatMembers(chatID, &tg.ParticipantOptions{
+		Filter: &tg.ChannelParticipantsAdmins{},
+		Limit:  maxChatAdmins,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ca := &chatAdmins{members: make(map[int64]*tg.Participant, len(members)), loadedAt: time.Now()}
+	for _, p := range members {
+		if p.User != nil {
+			ca.members[p.User.ID] = p
+		}
+	}
+
+	ac.mu.Lock()
+	ac.chats[adminCacheKey(chatID)] = ca
+	ac.mu.Unlock()
+	return ca, nil
+}
+
+// refresh reloads chatID unless it was loaded within adminCacheMinRefresh.
+func (ac *adminCache) refresh(client *tg.Client, chatID int64) (*chatAdmins, error) {
+	ac.mu.RLock()
+	ca, ok := ac.chats[adminCacheKey(chatID)]
+	ac.mu.RUnlock()
+	if ok && time.Since(ca.loadedAt) < adminCacheMinRefresh {
+		return ca, nil
+	}
+	return ac.load(client, chatID)
+}
+
+func (ac *adminCache) invalidate(chatID int64) {
+	ac.mu.Lock()
+	delete(ac.chats, adminCacheKey(chatID))
+	ac.mu.Unlock()
+}
+
+// participantHasRight reports whether p is the creator, or an admin holding
+// right. A nil p is a regular member.
+func participantHasRight(p *tg.Participant, right string) bool {
+	if p == nil {
+		return false
+	}
+	switch p.Status {
+	case tg.Creator:
+		return true
+	case tg.Admin:
+		return hasAdminRight(p.Rights, right)
+	}
+	return false
+}
+
+// hasAdminRight maps the right names used across the modules to their
+// ChatAdminRights field; "" only asks for admin status.
+func hasAdminRight(rights *tg.ChatAdminRights, right string) bool {
+	if right == "" {
+		return true
+	}
+	if rights == nil {
+		return false
+	}
+	switch right {
+	case "change_info", "info":
+		return rights.ChangeInfo
+	case "post":
+		return rights.PostMessages
+	case "edit":
+		return rights.EditMessages
+	case "delete":
+		return rights.DeleteMessages
+	case "ban":
+		return rights.BanUsers
+	case "invite":
+		return rights.InviteUsers
+	case "pin":
+		return rights.PinMessages
+	case "promote":
+		return rights.AddAdmins
+	case "anonymous":
+		return rights.Anonymous
+	case "call":
+		return rights.ManageCall
+	case "other":
+		return rights.Other
+	case "topics":
+		return rights.ManageTopics
+	case "post_stories":
+		return rights.PostStories
+	case "edit_stories":
+		return rights.EditStories
+	case "delete_stories":
+		return rights.DeleteStories
+	case "direct_messages":
+		return rights.ManageDirectMessages
+	case "ranks":
+		return rights.ManageRanks
+	}
+	return false
+}
+
+func isAdminParticipant(p tg.ChannelParticipant) bool {
+	switch p.(type) {
+	case *tg.ChannelParticipantAdmin, *tg.ChannelParticipantCreator:
+		return true
+	}
+	return false
+}
+
+// AdminCacheParticipantHandler drops the chat's admin list whenever someone,
+// the bot included, gains, loses or changes admin rights.
+func AdminCacheParticipantHandler(p *tg.ParticipantUpdate) error {
+	if isAdminParticipant(p.Old) || isAdminParticipant(p.New) {
+		chatAdminCache.invalidate(p.ChatID())
+	}
+	return nil
+}
+
+// AdminCacheHandler - /admincache, reloads the chat's admin list
+func AdminCacheHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("This command only works in groups.")
+		return nil
+	}
+
+	admins, err := chatAdminCache.refresh(m.Client, m.ChatID())
+	if err != nil {
+		m.Reply("Failed to reload admins: " + err.Error())
+		return nil
+	}
+	if !participantHasRight(admins.members[m.SenderID()], "") {
+		m.Reply("You need to be an admin to use this.")
+		return nil
+	}
+
+	var missing []string
+	bot := admins.members[m.Client.Me().ID]
+	for _, right := range []string{"delete", "ban", "pin", "invite", "change_info", "promote"} {
+		if !participantHasRight(bot, right) {
+			missing = append(missing, right)
+		}
+	}
+
+	text := fmt.Sprintf("<b>Admin cache reloaded</b>\n<b>Admins:</b> %d", len(admins.members))
+	switch {
+	case bot == nil:
+		text += "\n\nI'm not an admin here, so most moderation commands won't work."
+	case len(missing) > 0:
+		text += "\n<b>I'm missing:</b> " + strings.Join(missing, ", ")
+	}
+	m.Reply(text)
+	return nil
+}
+
+func registerAdminCacheHandlers() {
+	c := Client
+	c.On("cmd:admincache", AdminCacheHandler)
+	c.On(tg.OnParticipant, AdminCacheParticipantHandler)
+}
+
+func init() {
+	QueueHandlerRegistration(registerAdminCacheHandlers)
+}
@@ -2,22 +2,17 @@ package modules
 
 import (
 	"fmt"
+	"html"
+	"log"
+	"main/modules/db"
 	"math/rand"
 	"strings"
 	"time"
+	"unicode/utf16"
 
 	tg "github.com/amarnathcjd/gogram/telegram"
 )
 
-type AFK struct {
-	Name    string
-	Message string
-	Media   string
-	Time    int64
-}
-
-var afkList = make(map[int64]AFK)
-
 var randomAFKMessages = []string{
 	"<b>%s</b> is AFK since <b>%s</b>.",
 	"<b>%s</b> is AFK for <b>%s</b>.",
@@ -27,164 +22,203 @@ var randomAFKMessages = []string{
 	"<b>%s</b> is currently AFK for <b>%s</b>.",
 }
 
+// isAFKCommand matches /afk, !afk and .afk (optionally @bot) but not longer
+// commands such as /afkdigest.
+func isAFKCommand(text string) bool {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || len(fields[0]) < 2 || !strings.ContainsRune("/!.", rune(fields[0][0])) {
+		return false
+	}
+	cmd, _, _ := strings.Cut(strings.ToLower(fields[0][1:]), "@")
+	return cmd == "afk"
+}
+
+func afkDuration(since time.Time) string {
+	return trimDecimal(time.Since(since).Round(time.Second).String())
+}
+
 func AFKHandler(m *tg.NewMessage) error {
-	if strings.HasPrefix(m.Text(), "/afk") || strings.HasPrefix(m.Text(), "!afk") || strings.HasPrefix(m.Text(), ".afk") {
+	if m.Sender == nil {
+		return nil
+	}
+
+	if isAFKCommand(m.Text()) {
 		media := ""
 		if m.IsReply() {
 			r, err := m.GetReplyMessage()
 			if err == nil {
-				if r.IsMedia() {
+				if r.IsMedia() && r.File != nil {
 					media = r.File.FileID
 				}
 			}
 		}
-		afkList[m.Sender.ID] = AFK{
-			Name:    m.Sender.Username,
-			Message: m.Args(),
-			Media:   media,
-			Time:    time.Now().Unix(),
+
+		err := db.SetAFK(&db.AFKStatus{
+			UserID:   m.SenderID(),
+			Name:     m.Sender.FirstName,
+			Username: m.Sender.Username,
+			Reason:   m.Args(),
+			Media:    media,
+			Since:    time.Now(),
+		})
+		if err != nil {
+			m.Reply("Failed to set AFK")
+			return nil
 		}
 
 		m.Reply("You are now AFK.")
 		return nil
-	} else {
-		if afk, ok := afkList[m.SenderID()]; ok {
-			delete(afkList, m.SenderID())
-			duration := time.Since(time.Unix(afk.Time, 0)).String()
-			m.Reply(fmt.Sprintf("Welcome back <b>%s</b>! You were AFK for %s.", afk.Name, duration))
-		} else {
-			if m.IsReply() {
-				r, err := m.GetReplyMessage()
-				if err == nil {
-					if afk, ok := afkList[r.SenderID()]; ok {
-						duration := time.Since(time.Unix(afk.Time, 0)).String()
-						msg := randomAFKMessages[rand.Intn(len(randomAFKMessages))]
-						if afk.Media != "" {
-							var msg = fmt.Sprintf(msg, afk.Name, duration)
-							if afk.Message != "" {
-								msg += "\nReason: " + afk.Message
-							}
-							media, _ := tg.ResolveBotFileID(afk.Media)
-							if IsSticker(media) {
-								m.ReplyMedia(media)
-								m.Respond(msg)
-							} else {
-								m.ReplyMedia(media, &tg.MediaOptions{
-									Caption: msg,
-								})
-							}
-						} else {
-							var msg = fmt.Sprintf(msg, afk.Name, duration)
-							if afk.Message != "" {
-								msg += "\nReason: " + afk.Message
-							}
-
-							m.Reply(msg)
-						}
-					}
-				}
-			} else {
-				if len(m.Message.Entities) > 0 {
-					for _, entity := range m.Message.Entities {
-						switch e := entity.(type) {
-						case *tg.MessageEntityMentionName:
-							if afk, ok := afkList[e.UserID]; ok {
-								duration := time.Since(time.Unix(afk.Time, 0)).String()
-								msg := randomAFKMessages[rand.Intn(len(randomAFKMessages))]
-								if afk.Media != "" {
-									var msg = fmt.Sprintf(msg, afk.Name, duration)
-									if afk.Message != "" {
-										msg += "\nReason: " + afk.Message
-									}
-									media, _ := tg.ResolveBotFileID(afk.Media)
-									if IsSticker(media) {
-										m.ReplyMedia(media)
-										m.Respond(msg)
-									} else {
-										m.ReplyMedia(media, &tg.MediaOptions{
-											Caption: msg,
-										})
-									}
-								} else {
-									var msg = fmt.Sprintf(msg, afk.Name, duration)
-									if afk.Message != "" {
-										msg += "\nReason: " + afk.Message
-									}
-
-									m.Reply(msg)
-								}
-							}
-						case *tg.MessageEntityMention:
-							offset := e.Offset
-							length := e.Length
-
-							username := m.Text()[offset : offset+length]
-							for _, afk := range afkList {
-								if afk.Name == username {
-									duration := time.Since(time.Unix(afk.Time, 0)).String()
-									msg := randomAFKMessages[rand.Intn(len(randomAFKMessages))]
-									if afk.Media != "" {
-										var msg = fmt.Sprintf(msg, afk.Name, trimDecimal(duration))
-										if afk.Message != "" {
-											msg += "\nReason: " + afk.Message
-										}
-										media, _ := tg.ResolveBotFileID(afk.Media)
-										if IsSticker(media) {
-											m.ReplyMedia(media)
-											m.Respond(msg)
-										} else {
-											m.ReplyMedia(media, &tg.MediaOptions{
-												Caption: msg,
-											})
-										}
-									} else {
-										var msg = fmt.Sprintf(msg, afk.Name, trimDecimal(duration))
-										if afk.Message != "" {
-											msg += "\nReason: " + afk.Message
-										}
-
-										m.Reply(msg)
-									}
-								}
-							}
-
-							user, err := m.Client.ResolvePeer(username)
-							if err == nil {
-								peerId := m.Client.GetPeerID(user)
-								if afk, ok := afkList[peerId]; ok {
-									duration := time.Since(time.Unix(afk.Time, 0)).String()
-									msg := randomAFKMessages[rand.Intn(len(randomAFKMessages))]
-									if afk.Media != "" {
-										var msg = fmt.Sprintf(msg, afk.Name, trimDecimal(duration))
-										if afk.Message != "" {
-											msg += "\nReason: " + afk.Message
-										}
-										media, _ := tg.ResolveBotFileID(afk.Media)
-										if IsSticker(media) {
-											m.ReplyMedia(media)
-											m.Respond(msg)
-										} else {
-											m.ReplyMedia(media, &tg.MediaOptions{
-												Caption: msg,
-											})
-										}
-									} else {
-										var msg = fmt.Sprintf(msg, afk.Name, trimDecimal(duration))
-										if afk.Message != "" {
-											msg += "\nReason: " + afk.Message
-										}
-
-										m.Reply(msg)
-									}
-								}
-							}
-						}
-					}
-				}
+	}
+
+	if afk, _ := db.RemoveAFK(m.SenderID()); afk != nil {
+		m.Reply(fmt.Sprintf("Welcome back <b>%s</b>! You were AFK for %s.", afk.Name, afkDuration(afk.Since)))
+		if afk.MentionCount > 0 && db.GetAFKDigest(afk.UserID) {
+			go sendAFKDigest(m.Client, afk)
+		}
+		return nil
+	}
+
+	for _, afk := range mentionedAFKUsers(m) {
+		replyAFKNotice(m, afk)
+		if m.IsPrivate() {
+			continue
+		}
+
+		mention := db.AFKMention{
+			ChatID:    m.ChatID(),
+			MessageID: m.ID,
+			FromID:    m.SenderID(),
+			FromName:  m.Sender.FirstName,
+			Time:      time.Now(),
+		}
+		if m.Channel != nil {
+			mention.ChatTitle = m.Channel.Title
+			mention.Link = fmt.Sprintf("https://t.me/c/%d/%d", m.ChatID(), m.ID)
+		} else if m.Chat != nil {
+			mention.ChatTitle = m.Chat.Title
+		}
+		err := db.AddAFKMention(afk.UserID, mention)
+		if err != nil {
+			log.Printf("Failed to record AFK mention for %d: %v", afk.UserID, err)
+		}
+	}
+
+	return nil
+}
+
+// mentionedAFKUsers returns the AFK users m refers to by reply, text mention
+// or @username, each once.
+func mentionedAFKUsers(m *tg.NewMessage) []*db.AFKStatus {
+	var found []*db.AFKStatus
+	seen := map[int64]bool{m.SenderID(): true}
+	add := func(afk *db.AFKStatus) {
+		if afk != nil && !seen[afk.UserID] {
+			seen[afk.UserID] = true
+			found = append(found, afk)
+		}
+	}
+
+	if m.IsReply() {
+		if r, err := m.GetReplyMessage(); err == nil {
+			afk, _ := db.GetAFK(r.SenderID())
+			add(afk)
+		}
+	}
+
+	text := utf16.Encode([]rune(m.Text()))
+	for _, entity := range m.Message.Entities {
+		switch e := entity.(type) {
+		case *tg.MessageEntityMentionName:
+			afk, _ := db.GetAFK(e.UserID)
+			add(afk)
+		case *tg.MessageEntityMention:
+			// Entity offsets count UTF-16 code units.
+			start, end := int(e.Offset), int(e.Offset+e.Length)
+			if start < 0 || end > len(text) || start >= end {
+				continue
 			}
+			username := strings.TrimPrefix(string(utf16.Decode(text[start:end])), "@")
+			afk, _ := db.GetAFKByUsername(username)
+			add(afk)
 		}
 	}
 
+	return found
+}
+
+func replyAFKNotice(m *tg.NewMessage, afk *db.AFKStatus) {
+	msg := fmt.Sprintf(randomAFKMessages[rand.Intn(len(randomAFKMessages))], afk.Name, afkDuration(afk.Since))
+	if afk.Reason != "" {
+		msg += "\nReason: " + afk.Reason
+	}
+
+	if afk.Media == "" {
+		m.Reply(msg)
+		return
+	}
+
+	media, _ := tg.ResolveBotFileID(afk.Media)
+	if IsSticker(media) {
+		m.ReplyMedia(media)
+		m.Respond(msg)
+	} else {
+		m.ReplyMedia(media, &tg.MediaOptions{
+			Caption: msg,
+		})
+	}
+}
+
+// sendAFKDigest DMs a returning user the list of messages that mentioned them.
+func sendAFKDigest(client *tg.Client, afk *db.AFKStatus) {
+	var sb strings.Builder
+	if afk.MentionCount == 1 {
+		sb.WriteString("<b>You were mentioned once while AFK</b>\n\n")
+	} else {
+		fmt.Fprintf(&sb, "<b>You were mentioned %d times while AFK</b>\n\n", afk.MentionCount)
+	}
+
+	for i, mention := range afk.Mentions {
+		chat := mention.ChatTitle
+		if chat == "" {
+			chat = fmt.Sprint(mention.ChatID)
+		}
+		fmt.Fprintf(&sb, "%d. <b>%s</b> by %s", i+1, html.EscapeString(chat), html.EscapeString(mention.FromName))
+		if mention.Link != "" {
+			fmt.Fprintf(&sb, ", <a href=\"%s\">view message</a>", mention.Link)
+		}
+		sb.WriteString("\n")
+	}
+	if extra := afk.MentionCount - len(afk.Mentions); extra > 0 {
+		fmt.Fprintf(&sb, "\n<i>...and %d more</i>", extra)
+	}
+
+	if _, err := client.SendMessage(afk.UserID, sb.String(), &tg.SendOptions{LinkPreview: false}); err != nil {
+		log.Printf("Failed to send AFK digest to %d: %v", afk.UserID, err)
+	}
+}
+
+// AFKDigestHandler - /afkdigest on|off
+func AFKDigestHandler(m *tg.NewMessage) error {
+	switch strings.ToLower(strings.TrimSpace(m.Args())) {
+	case "on", "yes", "enable":
+		if err := db.SetAFKDigest(m.SenderID(), true); err != nil {
+			m.Reply("Failed to update AFK digest")
+			return nil
+		}
+		m.Reply("When you come back from AFK I'll DM you a list of where you were mentioned.\nMake sure you've started me in private.")
+	case "off", "no", "disable":
+		if err := db.SetAFKDigest(m.SenderID(), false); err != nil {
+			m.Reply("Failed to update AFK digest")
+			return nil
+		}
+		m.Reply("AFK digest disabled")
+	default:
+		status := "disabled"
+		if db.GetAFKDigest(m.SenderID()) {
+			status = "enabled"
+		}
+		m.Reply(fmt.Sprintf("AFK digest is <b>%s</b>\n\nUsage: /afkdigest on/off", status))
+	}
 	return nil
 }
 
@@ -263,9 +297,20 @@ func SedHandler(m *tg.NewMessage) error {
 func registerAFKHandlers() {
 	c := Client
 	c.On(tg.OnNewMessage, AFKHandler)
+	c.On("cmd:afkdigest", AFKDigestHandler)
 	c.On(tg.OnNewMessage, SedHandler)
 }
 
 func init() {
 	QueueHandlerRegistration(registerAFKHandlers)
+
+	Mods.AddModule("AFK", `<b>AFK</b>
+
+Let others know you're away.
+
+<b>Commands:</b>
+/afk [reason] - Mark yourself AFK (reply to media to attach it)
+/afkdigest on/off - DM me a list of mentions when I come back
+
+Anyone who replies to you, tags you or mentions your @username while you're AFK gets a notice. Sending any message marks you as back.`)
 }
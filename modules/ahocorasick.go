@@ -0,0 +1,96 @@
+package modules
+
+// ahoCorasick finds every occurrence of a fixed set of byte patterns in a
+// single pass over the input.
+type ahoCorasick struct {
+	nodes    []acNode
+	patterns []string
+}
+
+type acNode struct {
+	next map[byte]int32
+	fail int32
+	// out holds indices into patterns that end at this node, including those
+	// inherited through fail links.
+	out []int
+}
+
+// acMatch is a pattern occurrence at text[Start:End].
+type acMatch struct {
+	Pattern int
+	Start   int
+	End     int
+}
+
+func newAhoCorasick(patterns []string) *ahoCorasick {
+	ac := &ahoCorasick{
+		nodes:    []acNode{{next: make(map[byte]int32)}},
+		patterns: patterns,
+	}
+
+	for i, p := range patterns {
+		if p == "" {
+			continue
+		}
+		cur := int32(0)
+		for j := 0; j < len(p); j++ {
+			nxt, ok := ac.nodes[cur].next[p[j]]
+			if !ok {
+				nxt = int32(len(ac.nodes))
+				ac.nodes = append(ac.nodes, acNode{next: make(map[byte]int32)})
+				ac.nodes[cur].next[p[j]] = nxt
+			}
+			cur = nxt
+		}
+		ac.nodes[cur].out = append(ac.nodes[cur].out, i)
+	}
+
+	// Breadth-first so every fail target is finished before it is used.
+	queue := make([]int32, 0, len(ac.nodes))
+	for _, child := range ac.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for b, child := range ac.nodes[cur].next {
+			f := ac.nodes[cur].fail
+			for f != 0 {
+				if _, ok := ac.nodes[f].next[b]; ok {
+					break
+				}
+				f = ac.nodes[f].fail
+			}
+			if t, ok := ac.nodes[f].next[b]; ok && t != child {
+				ac.nodes[child].fail = t
+			}
+			ac.nodes[child].out = append(ac.nodes[child].out, ac.nodes[ac.nodes[child].fail].out...)
+			queue = append(queue, child)
+		}
+	}
+
+	return ac
+}
+
+// findAll returns every pattern occurrence in text, ordered by end position.
+func (ac *ahoCorasick) findAll(text string) []acMatch {
+	var matches []acMatch
+	cur := int32(0)
+	for i := 0; i < len(text); i++ {
+		b := text[i]
+		for {
+			if nxt, ok := ac.nodes[cur].next[b]; ok {
+				cur = nxt
+				break
+			}
+			if cur == 0 {
+				break
+			}
+			cur = ac.nodes[cur].fail
+		}
+		for _, p := range ac.nodes[cur].out {
+			matches = append(matches, acMatch{Pattern: p, Start: i + 1 - len(ac.patterns[p]), End: i + 1})
+		}
+	}
+	return matches
+}
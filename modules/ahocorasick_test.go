@@ -0,0 +1,171 @@
+package modules
+
+import (
+	"main/modules/db"
+	"reflect"
+	"testing"
+)
+
+func TestAhoCorasickFindAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+		want     []acMatch
+	}{
+		{
+			name:     "overlapping",
+			patterns: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			want: []acMatch{
+				{Pattern: 1, Start: 1, End: 4},
+				{Pattern: 0, Start: 2, End: 4},
+				{Pattern: 3, Start: 2, End: 6},
+			},
+		},
+		{
+			name:     "nested",
+			patterns: []string{"a", "ab", "abc", "bc", "c"},
+			text:     "abc",
+			want: []acMatch{
+				{Pattern: 0, Start: 0, End: 1},
+				{Pattern: 1, Start: 0, End: 2},
+				{Pattern: 2, Start: 0, End: 3},
+				{Pattern: 3, Start: 1, End: 3},
+				{Pattern: 4, Start: 2, End: 3},
+			},
+		},
+		{
+			name:     "repeated",
+			patterns: []string{"aa"},
+			text:     "aaaa",
+			want: []acMatch{
+				{Pattern: 0, Start: 0, End: 2},
+				{Pattern: 0, Start: 1, End: 3},
+				{Pattern: 0, Start: 2, End: 4},
+			},
+		},
+		{
+			name:     "fail link after mismatch",
+			patterns: []string{"abcd", "bce"},
+			text:     "abce",
+			want:     []acMatch{{Pattern: 1, Start: 1, End: 4}},
+		},
+		{
+			name:     "multibyte",
+			patterns: []string{"привет", "мир"},
+			text:     "привет, мир",
+			want: []acMatch{
+				{Pattern: 0, Start: 0, End: 12},
+				{Pattern: 1, Start: 14, End: 20},
+			},
+		},
+		{
+			name:     "empty pattern ignored",
+			patterns: []string{"", "x"},
+			text:     "xx",
+			want: []acMatch{
+				{Pattern: 1, Start: 0, End: 1},
+				{Pattern: 1, Start: 1, End: 2},
+			},
+		},
+		{
+			name:     "no match",
+			patterns: []string{"foo"},
+			text:     "fo of oof",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newAhoCorasick(tt.patterns).findAll(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findAll(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterMatcher(t *testing.T) {
+	filter := func(keyword string) *db.Filter {
+		mode, triggers, err := parseFilterTriggers(keyword)
+		if err != nil {
+			t.Fatalf("parseFilterTriggers(%q): %v", keyword, err)
+		}
+		return &db.Filter{Keyword: keyword, Mode: mode, Triggers: triggers}
+	}
+
+	tests := []struct {
+		name    string
+		filters []string
+		text    string
+		want    []string
+	}{
+		{"word", []string{"hi"}, "oh hi there", []string{"hi"}},
+		{"case insensitive", []string{"hi"}, "HI!", []string{"hi"}},
+		{"inside a word", []string{"hi"}, "this", nil},
+		{"word followed by digit", []string{"hi"}, "hi2", nil},
+		{"alternatives", []string{"(hey|hello)"}, "well hello", []string{"(hey|hello)"}},
+		{"phrase", []string{"good morning"}, "Good morning all", []string{"good morning"}},
+		{"overlapping keywords", []string{"good", "good morning", "morning"}, "good morning", []string{"good", "good morning", "morning"}},
+		{"ordered by position", []string{"b", "a"}, "a b", []string{"a", "b"}},
+		{"each filter once", []string{"(hi|hey)"}, "hi hey hi", []string{"(hi|hey)"}},
+		{"prefix", []string{"prefix:!rules"}, "!rules please", []string{"prefix:!rules"}},
+		{"prefix not at start", []string{"prefix:!rules"}, "see !rules", nil},
+		{"exact", []string{"exact:ping"}, "  Ping ", []string{"exact:ping"}},
+		{"exact with extra text", []string{"exact:ping"}, "ping pong", nil},
+		{"regex", []string{"regex:^\\d{3}$"}, "123", []string{"regex:^\\d{3}$"}},
+		{"regex case insensitive", []string{"regex:Foo"}, "FOO", []string{"regex:Foo"}},
+		{"trigger starting with punctuation", []string{"!help"}, "type !help", []string{"!help"}},
+		{"unicode word", []string{"привет"}, "Привет всем", []string{"привет"}},
+		{"unicode inside a word", []string{"мир"}, "мировой", nil},
+		{"unicode after a letter", []string{"ñu"}, "año ñu", []string{"ñu"}},
+		{"unicode glued to letter", []string{"ñu"}, "añu", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filters []*db.Filter
+			for _, k := range tt.filters {
+				filters = append(filters, filter(k))
+			}
+			var got []string
+			for _, f := range newFilterMatcher(filters).matchAll(tt.text) {
+				got = append(got, f.Keyword)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchAll(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterTriggers(t *testing.T) {
+	tests := []struct {
+		keyword  string
+		mode     string
+		triggers []string
+		wantErr  bool
+	}{
+		{"Hello", db.FilterModeWord, []string{"hello"}, false},
+		{"(A| b |)", db.FilterModeWord, []string{"a", "b"}, false},
+		{"EXACT:Ping", db.FilterModeExact, []string{"ping"}, false},
+		{"prefix:!Rules", db.FilterModePrefix, []string{"!rules"}, false},
+		{"regex:Ab+c", db.FilterModeRegex, []string{"Ab+c"}, false},
+		{"regex:(", "", nil, true},
+		// Lowercasing shrinks "İ" by a byte, which used to make the mode
+		// prefix check slice past the end.
+		{"İİ", db.FilterModeWord, []string{"ii"}, false},
+	}
+
+	for _, tt := range tests {
+		mode, triggers, err := parseFilterTriggers(tt.keyword)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFilterTriggers(%q) error = %v, wantErr %v", tt.keyword, err, tt.wantErr)
+			continue
+		}
+		if mode != tt.mode || !reflect.DeepEqual(triggers, tt.triggers) {
+			t.Errorf("parseFilterTriggers(%q) = %q, %q, want %q, %q", tt.keyword, mode, triggers, tt.mode, tt.triggers)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package modules
+
+import (
+	"fmt"
+	"main/modules/db"
+	"strings"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+// approvalTarget resolves the user an approval command is about.
+func approvalTarget(m *tg.NewMessage) (int64, string, error) {
+	user, _, err := GetUserFromContext(m)
+	if err != nil {
+		return 0, "", err
+	}
+	userID := m.Client.GetPeerID(user)
+
+	name := "User"
+	if u, err := m.Client.GetUser(userID); err == nil && u != nil {
+		name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+	}
+	return userID, name, nil
+}
+
+// ApproveHandler - /approve <user>
+func ApproveHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Approvals can only be used in groups")
+		return nil
+	}
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "ban") {
+		m.Reply("You need Ban Users permission to approve users")
+		return nil
+	}
+
+	userID, name, err := approvalTarget(m)
+	if err != nil {
+		m.Reply("Usage: /approve <user> or reply to a message with /approve")
+		return nil
+	}
+	if IsUserAdmin(m.Client, userID, m.ChatID(), "") {
+		m.Reply("Admins are already exempt from automated moderation")
+		return nil
+	}
+	if db.IsApproved(m.ChatID(), userID) {
+		m.Reply(fmt.Sprintf("%s is already approved", userMention(userID, name)))
+		return nil
+	}
+
+	if err := db.ApproveUser(&db.Approval{
+		ChatID:     m.ChatID(),
+		UserID:     userID,
+		Name:       name,
+		ApprovedBy: m.SenderID(),
+		ApprovedAt: time.Now(),
+	}); err != nil {
+		m.Reply("Failed to approve user")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("%s is now approved. Blacklists, flood control and locks will ignore them.", userMention(userID, name)))
+	return nil
+}
+
+// UnapproveHandler - /unapprove <user>
+func UnapproveHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Approvals can only be used in groups")
+		return nil
+	}
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "ban") {
+		m.Reply("You need Ban Users permission to unapprove users")
+		return nil
+	}
+
+	userID, name, err := approvalTarget(m)
+	if err != nil {
+		m.Reply("Usage: /unapprove <user> or reply to a message with /unapprove")
+		return nil
+	}
+
+	found, err := db.UnapproveUser(m.ChatID(), userID)
+	if err != nil {
+		m.Reply("Failed to unapprove user")
+		return nil
+	}
+	if !found {
+		m.Reply(fmt.Sprintf("%s isn't approved", userMention(userID, name)))
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("%s is no longer approved", userMention(userID, name)))
+	return nil
+}
+
+// ApprovedHandler - /approved, lists approved users
+func ApprovedHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Approvals can only be used in groups")
+		return nil
+	}
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "") {
+		m.Reply("Only admins can view approved users")
+		return nil
+	}
+
+	approvals, err := db.GetApprovals(m.ChatID())
+	if err != nil {
+		m.Reply("Failed to read approved users")
+		return nil
+	}
+	if len(approvals) == 0 {
+		m.Reply("No users are approved in this chat")
+		return nil
+	}
+
+	var resp strings.Builder
+	resp.WriteString("<b>Approved Users</b>\n\n")
+	for i, a := range approvals {
+		fmt.Fprintf(&resp, "%d. %s (<code>%d</code>)\n", i+1, userMention(a.UserID, a.Name), a.UserID)
+	}
+	fmt.Fprintf(&resp, "\nTotal: <b>%d</b>", len(approvals))
+	m.Reply(resp.String())
+	return nil
+}
+
+// UnapproveAllHandler - /unapproveall, chat creator only
+func UnapproveAllHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Approvals can only be used in groups")
+		return nil
+	}
+	if !isChatCreator(m.Client, m.ChatID(), m.SenderID()) {
+		m.Reply("Only the chat creator can unapprove everyone")
+		return nil
+	}
+
+	approvals, _ := db.GetApprovals(m.ChatID())
+	if len(approvals) == 0 {
+		m.Reply("No users are approved in this chat")
+		return nil
+	}
+
+	b := tg.Button
+	m.Reply(
+		fmt.Sprintf("<b>Are you sure you want to unapprove all %d users?</b>", len(approvals)),
+		&tg.SendOptions{
+			ReplyMarkup: tg.NewKeyboard().AddRow(
+				b.Data("Yes, unapprove all", fmt.Sprintf("unapproveall_%d", m.SenderID())).Danger(),
+				b.Data("Cancel", fmt.Sprintf("cancelunapproveall_%d", m.SenderID())),
+			).Build(),
+		},
+	)
+	return nil
+}
+
+func UnapproveAllCallback(c *tg.CallbackQuery) error {
+	data := c.DataString()
+
+	if userID, ok := strings.CutPrefix(data, "cancelunapproveall_"); ok {
+		if fmt.Sprint(c.SenderID) != userID {
+			c.Answer("This is not for you", &tg.CallbackOptions{Alert: true})
+			return nil
+		}
+		c.Edit("Operation cancelled")
+		return nil
+	}
+
+	if userID, ok := strings.CutPrefix(data, "unapproveall_"); ok {
+		if fmt.Sprint(c.SenderID) != userID {
+			c.Answer("This is not for you", &tg.CallbackOptions{Alert: true})
+			return nil
+		}
+
+		count, err := db.ClearApprovals(c.ChatID)
+		if err != nil {
+			c.Edit("Failed to unapprove users")
+			return nil
+		}
+		c.Edit(fmt.Sprintf("Unapproved <b>%d</b> users", count))
+	}
+	return nil
+}
+
+func registerApprovalHandlers() {
+	c := Client
+	c.On("cmd:approve", ApproveHandler)
+	c.On("cmd:unapprove", UnapproveHandler)
+	c.On("cmd:approved", ApprovedHandler)
+	c.On("cmd:unapproveall", UnapproveAllHandler)
+	c.On("callback:unapproveall_", UnapproveAllCallback)
+	c.On("callback:cancelunapproveall_", UnapproveAllCallback)
+}
+
+func init() {
+	QueueHandlerRegistration(registerApprovalHandlers)
+
+	Mods.AddModule("Approvals", `<b>Approvals</b>
+
+Approve trusted members so automated moderation leaves them alone.
+Approved users are skipped by blacklists, flood control and bot-enforced locks, but can still be warned, muted or banned by admins.
+
+<b>Commands:</b>
+/approve <user> - Approve a user
+/unapprove <user> - Remove a user's approval
+/approved - List approved users
+/unapproveall - Unapprove everyone (chat creator only)
+
+<b>Note:</b> /info shows whether a user is approved in the current chat.`)
+}
@@ -0,0 +1,280 @@
+package modules
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"main/modules/db"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+const (
+	defaultBackupInterval = 24 * time.Hour
+	defaultBackupKeep     = 7
+	minBackupInterval     = 10 * time.Minute
+)
+
+// backupConfig comes from BACKUP_CHAT (chat ID or @username), BACKUP_INTERVAL
+// (e.g. 6h, 1d) and BACKUP_KEEP (how many uploads to keep).
+type backupConfig struct {
+	chat     any
+	interval time.Duration
+	keep     int
+}
+
+// loadBackupConfig returns nil when BACKUP_CHAT is unset.
+func loadBackupConfig() *backupConfig {
+	raw := strings.TrimSpace(os.Getenv("BACKUP_CHAT"))
+	if raw == "" {
+		return nil
+	}
+
+	cfg := &backupConfig{chat: raw, interval: defaultBackupInterval, keep: defaultBackupKeep}
+	if id, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		cfg.chat = id
+	}
+	if v := os.Getenv("BACKUP_INTERVAL"); v != "" {
+		d, err := parseDuration(v)
+		switch {
+		case err != nil:
+			log.Printf("[backup] invalid BACKUP_INTERVAL=%q, using %s: %v", v, formatDuration(cfg.interval), err)
+		case d < minBackupInterval:
+			log.Printf("[backup] BACKUP_INTERVAL=%q is below %s, using that", v, formatDuration(minBackupInterval))
+			cfg.interval = minBackupInterval
+		default:
+			cfg.interval = d
+		}
+	}
+	if v := os.Getenv("BACKUP_KEEP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.keep = n
+		} else {
+			log.Printf("[backup] invalid BACKUP_KEEP=%q, keeping %d", v, cfg.keep)
+		}
+	}
+	return cfg
+}
+
+// startBackups uploads a backup every interval, counting from the last one
+// recorded so restarts don't trigger an extra upload.
+func startBackups(client *tg.Client) {
+	cfg := loadBackupConfig()
+	if cfg == nil {
+		return
+	}
+	if db.StoreBackend() != db.BackendBolt {
+		log.Printf("[backup] disabled: DB_BACKEND=%s keeps most data outside database.db", db.StoreBackend())
+		return
+	}
+	log.Printf("[backup] backing up to %v every %s, keeping %d", cfg.chat, formatDuration(cfg.interval), cfg.keep)
+
+	for {
+		next := time.Now().Add(time.Minute)
+		if records, _ := db.GetBackupRecords(); len(records) > 0 {
+			if due := records[len(records)-1].CreatedAt.Add(cfg.interval); due.After(next) {
+				next = due
+			}
+		}
+		time.Sleep(time.Until(next))
+
+		if _, err := runBackup(client, cfg); err != nil {
+			log.Printf("[backup] failed: %v", err)
+			// Don't retry in a tight loop if the chat is unreachable.
+			time.Sleep(min(cfg.interval, time.Hour))
+		}
+	}
+}
+
+// createBackup returns a gzipped snapshot of database.db and its raw size.
+func createBackup() ([]byte, int64, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	size, err := db.SnapshotDB(gz)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), size, nil
+}
+
+func backupCaption(size, compressed int64) string {
+	version, _ := db.SchemaVersion()
+	return fmt.Sprintf("#backup <code>database.db</code>\n<b>Size:</b> %s (%s gzipped)\n<b>Schema:</b> v%d\n<b>Taken:</b> %s\n\nReply <code>/restoredb confirm</code> to restore.",
+		formatBytes(size), formatBytes(compressed), version, time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
+}
+
+func backupFileName() string {
+	return fmt.Sprintf("database-%s.db.gz", time.Now().UTC().Format("20060102-150405"))
+}
+
+// runBackup uploads a snapshot to the backup chat and prunes old uploads.
+func runBackup(client *tg.Client, cfg *backupConfig) (*db.BackupRecord, error) {
+	data, size, err := createBackup()
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	msg, err := client.SendMedia(cfg.chat, data, &tg.MediaOptions{
+		FileName:      backupFileName(),
+		ForceDocument: true,
+		Caption:       backupCaption(size, int64(len(data))),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+
+	rec := &db.BackupRecord{
+		ChatID:    msg.ChatID(),
+		MessageID: msg.ID,
+		Size:      int64(len(data)),
+		CreatedAt: time.Now(),
+	}
+	if err := db.SaveBackupRecord(rec); err != nil {
+		log.Printf("[backup] uploaded but failed to record: %v", err)
+	}
+	log.Printf("[backup] uploaded %s to %v", formatBytes(rec.Size), cfg.chat)
+
+	pruneBackups(client, rec.ChatID, cfg.keep)
+	return rec, nil
+}
+
+// pruneBackups deletes all but the newest keep backups in chatID.
+func pruneBackups(client *tg.Client, chatID int64, keep int) {
+	records, err := db.GetBackupRecords()
+	if err != nil {
+		return
+	}
+
+	var inChat []*db.BackupRecord
+	for _, rec := range records {
+		if rec.ChatID == chatID {
+			inChat = append(inChat, rec)
+		}
+	}
+	if len(inChat) <= keep {
+		return
+	}
+
+	old := inChat[:len(inChat)-keep]
+	ids := make([]int32, 0, len(old))
+	for _, rec := range old {
+		ids = append(ids, rec.MessageID)
+	}
+	if _, err := client.DeleteMessages(chatID, ids); err != nil {
+		log.Printf("[backup] failed to delete %d old backups: %v", len(ids), err)
+		return
+	}
+	for _, rec := range old {
+		db.DeleteBackupRecord(rec)
+	}
+}
+
+// sqliteBackupNotice is the reply to /backup and /restoredb when notes,
+// filters and the rest live in SQLite, which these commands don't cover.
+const sqliteBackupNotice = "Backups only cover <code>database.db</code>, but <code>DB_BACKEND</code> is not bolt here. Back up the SQLite file at <code>SQLITE_PATH</code> alongside it instead."
+
+// BackupHandler - /backup, uploads a backup now
+func BackupHandler(m *tg.NewMessage) error {
+	if db.StoreBackend() != db.BackendBolt {
+		m.Reply(sqliteBackupNotice)
+		return nil
+	}
+
+	cfg := loadBackupConfig()
+	if cfg == nil {
+		// No backup chat: hand the file to the owner directly.
+		data, size, err := createBackup()
+		if err != nil {
+			m.Reply("Backup failed: " + err.Error())
+			return nil
+		}
+		m.ReplyMedia(data, &tg.MediaOptions{
+			FileName:      backupFileName(),
+			ForceDocument: true,
+			Caption:       backupCaption(size, int64(len(data))) + "\n\n<i>BACKUP_CHAT is not set, so this backup isn't scheduled or pruned.</i>",
+		})
+		return nil
+	}
+
+	rec, err := runBackup(m.Client, cfg)
+	if err != nil {
+		m.Reply("Backup failed: " + err.Error())
+		return nil
+	}
+	m.Reply(fmt.Sprintf("Backup uploaded (%s), keeping the latest %d", formatBytes(rec.Size), cfg.keep))
+	return nil
+}
+
+// RestoreDBHandler - /restoredb confirm, as a reply to a backup file
+func RestoreDBHandler(m *tg.NewMessage) error {
+	if db.StoreBackend() != db.BackendBolt {
+		m.Reply(sqliteBackupNotice)
+		return nil
+	}
+
+	usage := "Reply to a backup file with <code>/restoredb confirm</code>.\n\nThis replaces the whole database; the current file is kept as <code>database.db.pre-restore</code>."
+
+	if !m.IsReply() {
+		m.Reply(usage)
+		return nil
+	}
+	reply, err := m.GetReplyMessage()
+	if err != nil || reply.Document() == nil || reply.File == nil {
+		m.Reply(usage)
+		return nil
+	}
+	if strings.ToLower(strings.TrimSpace(m.Args())) != "confirm" {
+		m.Reply(fmt.Sprintf("<b>Restore %s</b> (%s)?\n\n%s", reply.File.Name, formatBytes(reply.File.Size), usage))
+		return nil
+	}
+
+	msg, _ := m.Reply("Downloading backup...")
+
+	var buf bytes.Buffer
+	if _, err := reply.Download(&tg.DownloadOptions{Buffer: &buf}); err != nil {
+		msg.Edit("Failed to download backup: " + err.Error())
+		return nil
+	}
+
+	var r io.Reader = &buf
+	if data := buf.Bytes(); len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(&buf)
+		if err != nil {
+			msg.Edit("Backup isn't a valid gzip file: " + err.Error())
+			return nil
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	msg.Edit("Restoring database...")
+	if err := db.RestoreDB(r); err != nil {
+		msg.Edit("Restore failed: " + err.Error())
+		return nil
+	}
+
+	version, _ := db.SchemaVersion()
+	msg.Edit(fmt.Sprintf("<b>Database restored</b> (schema v%d).\nThe previous file was kept as <code>database.db.pre-restore</code>.", version))
+	return nil
+}
+
+func registerBackupHandlers() {
+	c := Client
+	c.On("cmd:backup", BackupHandler, tg.CustomFilter(FilterOwner))
+	c.On("cmd:restoredb", RestoreDBHandler, tg.CustomFilter(FilterOwner))
+
+	go startBackups(c)
+}
+
+func init() {
+	QueueHandlerRegistration(registerBackupHandlers)
+}
@@ -2,14 +2,196 @@ package modules
 
 import (
 	"fmt"
+	"html"
+	"log"
 	"main/modules/db"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	tg "github.com/amarnathcjd/gogram/telegram"
 )
 
+// blacklistMatcher is a BlacklistEntry with its pattern compiled once.
+type blacklistMatcher struct {
+	entry *db.BlacklistEntry
+	re    *regexp.Regexp
+}
+
+func compileBlacklistEntry(entry *db.BlacklistEntry) (*blacklistMatcher, error) {
+	bm := &blacklistMatcher{entry: entry}
+
+	var pattern string
+	switch entry.MatchType {
+	case db.MatchWord:
+		pattern = `(?i)(^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(entry.Word) + `($|[^\p{L}\p{N}_])`
+	case db.MatchGlob:
+		var sb strings.Builder
+		for _, r := range entry.Word {
+			switch r {
+			case '*':
+				sb.WriteString(`\S*`)
+			case '?':
+				sb.WriteString(`\S`)
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		pattern = `(?i)(^|\s)` + sb.String() + `($|\s)`
+	case db.MatchRegex:
+		pattern = `(?i)` + entry.Word
+	default:
+		return bm, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	bm.re = re
+	return bm, nil
+}
+
+// match reports whether text hits this entry. lower is text lowercased.
+func (bm *blacklistMatcher) match(text, lower string) bool {
+	switch {
+	case bm.entry.FileID != "":
+		return false
+	case bm.re != nil:
+		return bm.re.MatchString(text)
+	case bm.entry.MatchType == db.MatchExact:
+		return strings.TrimSpace(lower) == bm.entry.Word
+	default:
+		return strings.Contains(lower, bm.entry.Word)
+	}
+}
+
+// blacklistCache holds compiled matchers per chat. It is filled lazily by the
+// watcher and dropped whenever the chat's blacklist changes.
+type blacklistCache struct {
+	mu    sync.RWMutex
+	chats map[int64][]*blacklistMatcher
+}
+
+var blacklistMatchers = &blacklistCache{chats: make(map[int64][]*blacklistMatcher)}
+
+func (bc *blacklistCache) get(chatID int64) ([]*blacklistMatcher, error) {
+	bc.mu.RLock()
+	matchers, ok := bc.chats[chatID]
+	bc.mu.RUnlock()
+	if ok {
+		return matchers, nil
+	}
+
+	entries, err := db.GetBlacklist(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	matchers = make([]*blacklistMatcher, 0, len(entries))
+	for _, entry := range entries {
+		bm, err := compileBlacklistEntry(entry)
+		if err != nil {
+			log.Printf("Skipping blacklist entry %q in %d: %v", entry.Word, chatID, err)
+			continue
+		}
+		matchers = append(matchers, bm)
+	}
+
+	bc.mu.Lock()
+	bc.chats[chatID] = matchers
+	bc.mu.Unlock()
+	return matchers, nil
+}
+
+func (bc *blacklistCache) invalidate(chatID int64) {
+	bc.mu.Lock()
+	delete(bc.chats, chatID)
+	bc.mu.Unlock()
+}
+
+func (bc *blacklistCache) reset() {
+	bc.mu.Lock()
+	clear(bc.chats)
+	bc.mu.Unlock()
+}
+
+var blacklistMatchTypes = []db.BlacklistMatchType{
+	db.MatchSubstring, db.MatchWord, db.MatchGlob, db.MatchRegex, db.MatchExact,
+}
+
+// splitBlacklistPattern splits an optional "type:" prefix off pattern.
+// Regex patterns keep their case; everything else is lowercased.
+func splitBlacklistPattern(pattern string) (db.BlacklistMatchType, string) {
+	pattern = strings.TrimSpace(pattern)
+	for _, mt := range blacklistMatchTypes {
+		if rest, ok := strings.CutPrefix(pattern, string(mt)+":"); ok {
+			if mt == db.MatchRegex {
+				return mt, strings.TrimSpace(rest)
+			}
+			return mt, strings.ToLower(strings.TrimSpace(rest))
+		}
+	}
+	return db.MatchSubstring, strings.ToLower(pattern)
+}
+
+// parseBlacklistAction parses "<action> [duration]". On failure it returns a
+// message suitable for replying to the user.
+func parseBlacklistAction(args []string, usage string) (db.BlacklistAction, string, string) {
+	action := args[0]
+	duration := ""
+	if len(args) > 1 {
+		duration = args[1]
+	}
+
+	switch action {
+	case "delete", "del":
+		return db.ActionDelete, "", ""
+	case "ban":
+		return db.ActionBan, "", ""
+	case "mute":
+		return db.ActionMute, "", ""
+	case "tban", "tmute":
+		if duration == "" {
+			return "", "", fmt.Sprintf("%s requires a duration. Example: %s %s 1h", action, usage, action)
+		}
+		if d, err := parseAdminDuration(duration); err != nil || d <= 0 {
+			return "", "", "Invalid duration. Examples: 30m, 1h, 2d"
+		}
+		return db.BlacklistAction(action), duration, ""
+	}
+	return "", "", "Unknown action. Use: delete, ban, mute, tban, tmute"
+}
+
+func formatBlacklistAction(action db.BlacklistAction, duration string) string {
+	if duration != "" {
+		return string(action) + " (" + duration + ")"
+	}
+	return string(action)
+}
+
+func formatBlacklistEntry(entry *db.BlacklistEntry) string {
+	return "<code>" + html.EscapeString(entry.Word) + "</code>" + blacklistEntrySuffix(entry)
+}
+
+// blacklistEntrySummary describes entry in plain text, for log entries.
+func blacklistEntrySummary(entry *db.BlacklistEntry) string {
+	return entry.Word + blacklistEntrySuffix(entry)
+}
+
+func blacklistEntrySuffix(entry *db.BlacklistEntry) string {
+	var text string
+	if entry.MatchType != "" && entry.MatchType != db.MatchSubstring {
+		text += " [" + string(entry.MatchType) + "]"
+	}
+	if entry.Action != "" {
+		text += " → " + formatBlacklistAction(entry.Action, entry.Duration)
+	}
+	return text
+}
+
 func AddBlacklistHandler(m *tg.NewMessage) error {
 	if m.IsPrivate() {
 		m.Reply("Blacklist can only be used in groups")
@@ -49,14 +231,16 @@ func AddBlacklistHandler(m *tg.NewMessage) error {
 				return nil
 			}
 
+			logMessageEvent(m, &LogEvent{Category: LogCategoryBlacklist, Action: "addbl", Details: "Media: " + msgLink})
 			m.Reply("Media added to blacklist. Any matching media will be deleted.")
 			return nil
 		}
 	}
 
-	word := strings.TrimSpace(strings.ToLower(m.Args()))
+	pattern, actionArgs, hasAction := strings.Cut(m.Args(), "--action")
+	matchType, word := splitBlacklistPattern(pattern)
 	if word == "" {
-		m.Reply("Usage: /addbl <word/phrase> or reply to media with /addbl")
+		m.Reply("Usage: /addbl [type:]<pattern> [--action <action> [duration]] or reply to media with /addbl")
 		return nil
 	}
 
@@ -71,8 +255,29 @@ func AddBlacklistHandler(m *tg.NewMessage) error {
 	}
 
 	entry := &db.BlacklistEntry{
-		Word:    word,
-		AddedBy: m.SenderID(),
+		Word:      word,
+		AddedBy:   m.SenderID(),
+		MatchType: matchType,
+	}
+
+	if hasAction {
+		args := strings.Fields(strings.ToLower(actionArgs))
+		if len(args) == 0 {
+			m.Reply("Usage: --action <delete|ban|mute|tban|tmute> [duration]")
+			return nil
+		}
+		action, duration, errMsg := parseBlacklistAction(args, "/addbl <pattern> --action")
+		if errMsg != "" {
+			m.Reply(errMsg)
+			return nil
+		}
+		entry.Action = action
+		entry.Duration = duration
+	}
+
+	if _, err := compileBlacklistEntry(entry); err != nil {
+		m.Reply(fmt.Sprintf("Invalid pattern: <code>%s</code>", err.Error()))
+		return nil
 	}
 
 	if err := db.AddBlacklist(m.ChatID(), entry); err != nil {
@@ -80,7 +285,8 @@ func AddBlacklistHandler(m *tg.NewMessage) error {
 		return nil
 	}
 
-	m.Reply(fmt.Sprintf("Added <code>%s</code> to the blacklist", word))
+	logMessageEvent(m, &LogEvent{Category: LogCategoryBlacklist, Action: "addbl", Details: blacklistEntrySummary(entry)})
+	m.Reply(fmt.Sprintf("Added %s to the blacklist", formatBlacklistEntry(entry)))
 	return nil
 }
 
@@ -114,17 +320,23 @@ func RemoveBlacklistHandler(m *tg.NewMessage) error {
 				return nil
 			}
 
+			logMessageEvent(m, &LogEvent{Category: LogCategoryBlacklist, Action: "rmbl", Details: "Media"})
 			m.Reply("Media removed from blacklist")
 			return nil
 		}
 	}
 
-	word := strings.TrimSpace(strings.ToLower(m.Args()))
+	_, word := splitBlacklistPattern(m.Args())
 	if word == "" {
 		m.Reply("Usage: /rmbl <word> or reply to media")
 		return nil
 	}
 
+	if raw := strings.TrimSpace(m.Args()); !db.IsBlacklisted(m.ChatID(), word) && db.IsBlacklisted(m.ChatID(), raw) {
+		// Regex entries keep their original case.
+		word = raw
+	}
+
 	if !db.IsBlacklisted(m.ChatID(), word) {
 		m.Reply(fmt.Sprintf("<code>%s</code> is not in the blacklist", word))
 		return nil
@@ -135,6 +347,7 @@ func RemoveBlacklistHandler(m *tg.NewMessage) error {
 		return nil
 	}
 
+	logMessageEvent(m, &LogEvent{Category: LogCategoryBlacklist, Action: "rmbl", Details: word})
 	m.Reply(fmt.Sprintf("Removed <code>%s</code> from the blacklist", word))
 	return nil
 }
@@ -156,10 +369,7 @@ func ListBlacklistHandler(m *tg.NewMessage) error {
 	})
 
 	settings, _ := db.GetBlacklistSettings(m.ChatID())
-	actionStr := string(settings.Action)
-	if settings.Duration != "" {
-		actionStr += " (" + settings.Duration + ")"
-	}
+	actionStr := formatBlacklistAction(settings.Action, settings.Duration)
 
 	var resp strings.Builder
 	resp.WriteString("<b>Blacklisted items:</b>\n\n")
@@ -171,7 +381,7 @@ func ListBlacklistHandler(m *tg.NewMessage) error {
 			resp.WriteString(fmt.Sprintf("%d. [Media File]\n", i+1))
 			mediaCount++
 		} else {
-			resp.WriteString(fmt.Sprintf("%d. <code>%s</code>\n", i+1, entry.Word))
+			resp.WriteString(fmt.Sprintf("%d. %s\n", i+1, formatBlacklistEntry(entry)))
 			wordCount++
 		}
 	}
@@ -200,10 +410,7 @@ func SetBlacklistActionHandler(m *tg.NewMessage) error {
 	args := strings.Fields(strings.ToLower(m.Args()))
 	if len(args) == 0 {
 		current, _ := db.GetBlacklistSettings(m.ChatID())
-		currentAction := string(current.Action)
-		if current.Duration != "" {
-			currentAction += " (" + current.Duration + ")"
-		}
+		currentAction := formatBlacklistAction(current.Action, current.Duration)
 
 		m.Reply(fmt.Sprintf(`<b>Blacklist Action Settings</b>
 
@@ -222,35 +429,9 @@ Usage: /setblaction <action> [duration]
 		return nil
 	}
 
-	action := args[0]
-	duration := ""
-
-	if len(args) > 1 {
-		duration = args[1]
-	}
-
-	var blAction db.BlacklistAction
-	switch action {
-	case "delete", "del":
-		blAction = db.ActionDelete
-	case "ban":
-		blAction = db.ActionBan
-	case "mute":
-		blAction = db.ActionMute
-	case "tban":
-		if duration == "" {
-			m.Reply("tban requires a duration. Example: /setblaction tban 1h")
-			return nil
-		}
-		blAction = db.ActionTBan
-	case "tmute":
-		if duration == "" {
-			m.Reply("tmute requires a duration. Example: /setblaction tmute 1d")
-			return nil
-		}
-		blAction = db.ActionTMute
-	default:
-		m.Reply("Unknown action. Use: delete, ban, mute, tban, tmute")
+	blAction, duration, errMsg := parseBlacklistAction(args, "/setblaction")
+	if errMsg != "" {
+		m.Reply(errMsg)
 		return nil
 	}
 
@@ -264,55 +445,56 @@ Usage: /setblaction <action> [duration]
 		return nil
 	}
 
-	actionMsg := string(blAction)
-	if duration != "" {
-		actionMsg += " (" + duration + ")"
-	}
-
-	m.Reply(fmt.Sprintf("Blacklist action set to: <b>%s</b>", actionMsg))
+	m.Reply(fmt.Sprintf("Blacklist action set to: <b>%s</b>", formatBlacklistAction(blAction, duration)))
 	return nil
 }
 
 func BlacklistWatcher(m *tg.NewMessage) error {
-	if m.IsPrivate() {
+	// Anonymous admins post as the chat itself.
+	if m.IsPrivate() || m.SenderID() == m.ChatID() {
 		return nil
 	}
 
-	entries, err := db.GetBlacklist(m.ChatID())
-	if err != nil || len(entries) == 0 {
+	matchers, err := blacklistMatchers.get(m.ChatID())
+	if err != nil || len(matchers) == 0 {
 		return nil
 	}
 
+	var matched *db.BlacklistEntry
 	var matchedWord string
 
 	// Check media files first
 	if m.IsMedia() && m.File != nil && m.File.FileID != "" {
-		for _, entry := range entries {
-			if entry.FileID != "" && entry.FileID == m.File.FileID {
-				matchedWord = "media"
+		for _, bm := range matchers {
+			if bm.entry.FileID != "" && bm.entry.FileID == m.File.FileID {
+				matched, matchedWord = bm.entry, "media"
 				break
 			}
 		}
 	}
 
 	// Check text if no media match
-	if matchedWord == "" && m.Text() != "" {
-		msgLower := strings.ToLower(m.Text())
-		for _, entry := range entries {
-			if entry.FileID == "" && strings.Contains(msgLower, entry.Word) {
-				matchedWord = entry.Word
+	if matched == nil && m.Text() != "" {
+		text := m.Text()
+		msgLower := strings.ToLower(text)
+		for _, bm := range matchers {
+			if bm.match(text, msgLower) {
+				matched, matchedWord = bm.entry, bm.entry.Word
 				break
 			}
 		}
 	}
 
-	if matchedWord == "" {
+	if matched == nil || IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "") || db.IsApproved(m.ChatID(), m.SenderID()) {
 		return nil
 	}
 
 	m.Delete()
 
 	settings, _ := db.GetBlacklistSettings(m.ChatID())
+	if matched.Action != "" {
+		settings = &db.BlacklistSettings{Action: matched.Action, Duration: matched.Duration}
+	}
 
 	user, err := m.Client.ResolvePeer(m.SenderID())
 	if err != nil {
@@ -349,6 +531,19 @@ func BlacklistWatcher(m *tg.NewMessage) error {
 	case db.ActionDelete:
 	}
 
+	details := "Matched " + matchedWord
+	if text := m.Text(); text != "" {
+		details += "\nMessage: " + trimString(text, 200)
+	}
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryBlacklist,
+		Action:   "blacklist " + string(settings.Action),
+		ActorID:  m.Client.Me().ID,
+		TargetID: m.SenderID(),
+		Duration: settings.Duration,
+		Details:  details,
+	})
+
 	return nil
 }
 
@@ -526,6 +721,7 @@ func HandleBlacklistMediaRemoval(c *tg.CallbackQuery) error {
 		return nil
 	}
 
+	logCallbackEvent(c, &LogEvent{Category: LogCategoryBlacklist, Action: "rmbl", Details: "Media"})
 	c.Answer("✓ Media removed from blacklist", &tg.CallbackOptions{Alert: false})
 	c.Edit("Media removed from blacklist!")
 
@@ -560,6 +756,7 @@ func ClearBlacklistCallback(c *tg.CallbackQuery) error {
 			return nil
 		}
 
+		logCallbackEvent(c, &LogEvent{Category: LogCategoryBlacklist, Action: "clearbl", Details: fmt.Sprintf("Cleared %d entries", count)})
 		c.Edit(fmt.Sprintf("Cleared <b>%d</b> blacklisted words", count))
 	}
 
@@ -587,13 +784,15 @@ func registerBlacklistHandlers() {
 
 func init() {
 	QueueHandlerRegistration(registerBlacklistHandlers)
+	db.OnBlacklistChange(blacklistMatchers.invalidate)
+	db.OnRestore(blacklistMatchers.reset)
 
 	Mods.AddModule("Blacklist", `<b>Blacklist Module</b>
 
 Block specific words/phrases or media in your group.
 
 <b>Commands:</b>
- - /addbl <word> - Add word to blacklist
+ - /addbl [type:]<pattern> [--action <action> [duration]] - Add pattern to blacklist
  - /addbl [reply to media] - Add media to blacklist
  - /addblacklist <word> - Same as above
  - /rmbl <word> - Remove word from blacklist
@@ -610,5 +809,20 @@ Block specific words/phrases or media in your group.
  - tban <duration> - Temporary ban
  - tmute <duration> - Temporary mute
 
-<b>Note:</b> Admins are exempt from blacklist checks.`)
+<b>Match types:</b>
+ - substring - Anywhere in the message (default)
+ - word - Whole word only
+ - glob - Wildcards, <code>*</code> and <code>?</code> match within a word
+ - regex - Regular expression, case-insensitive
+ - exact - The whole message
+
+<b>Examples:</b>
+<code>/addbl word:scam</code>
+<code>/addbl glob:*.xyz --action ban</code>
+<code>/addbl regex:fr[e3]{2}\s*cr[y7]pto --action tmute 1h</code>
+<code>/addbl exact:hi</code>
+
+A per-entry action overrides /setblaction for that entry.
+
+<b>Note:</b> Admins and approved users (see /approve) are exempt from blacklist checks.`)
 }
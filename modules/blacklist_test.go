@@ -0,0 +1,86 @@
+package modules
+
+import (
+	"main/modules/db"
+	"strings"
+	"testing"
+)
+
+func TestCompileBlacklistEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry db.BlacklistEntry
+		text  string
+		want  bool
+	}{
+		{"substring", db.BlacklistEntry{Word: "bad", MatchType: db.MatchSubstring}, "so BADly", true},
+		{"legacy entries match substrings", db.BlacklistEntry{Word: "bad"}, "badge", true},
+		{"substring miss", db.BlacklistEntry{Word: "bad", MatchType: db.MatchSubstring}, "good", false},
+
+		{"word", db.BlacklistEntry{Word: "spam", MatchType: db.MatchWord}, "no spam here", true},
+		{"word at edges", db.BlacklistEntry{Word: "spam", MatchType: db.MatchWord}, "Spam", true},
+		{"word next to punctuation", db.BlacklistEntry{Word: "spam", MatchType: db.MatchWord}, "(spam!)", true},
+		{"word inside a word", db.BlacklistEntry{Word: "spam", MatchType: db.MatchWord}, "spammer", false},
+		{"word glued to digit", db.BlacklistEntry{Word: "spam", MatchType: db.MatchWord}, "spam1", false},
+		{"word with regex characters", db.BlacklistEntry{Word: "c++", MatchType: db.MatchWord}, "i like c++ a lot", true},
+		{"unicode word", db.BlacklistEntry{Word: "плохо", MatchType: db.MatchWord}, "это ПЛОХО.", true},
+		{"unicode inside a word", db.BlacklistEntry{Word: "плохо", MatchType: db.MatchWord}, "неплохо", false},
+
+		{"glob star", db.BlacklistEntry{Word: "free*", MatchType: db.MatchGlob}, "get freestuff now", true},
+		{"glob star matches nothing", db.BlacklistEntry{Word: "free*", MatchType: db.MatchGlob}, "free", true},
+		{"glob question", db.BlacklistEntry{Word: "b?t", MatchType: db.MatchGlob}, "a BOT", true},
+		{"glob question needs one character", db.BlacklistEntry{Word: "b?t", MatchType: db.MatchGlob}, "bt", false},
+		{"glob whole token", db.BlacklistEntry{Word: "free*", MatchType: db.MatchGlob}, "carefree", false},
+		{"glob star stays in token", db.BlacklistEntry{Word: "a*z", MatchType: db.MatchGlob}, "a b z", false},
+		{"glob dot is literal", db.BlacklistEntry{Word: "*.ru", MatchType: db.MatchGlob}, "visit site.ru", true},
+		{"glob dot is not wildcard", db.BlacklistEntry{Word: "*.ru", MatchType: db.MatchGlob}, "visit siteXru", false},
+
+		{"regex", db.BlacklistEntry{Word: `t\.me/\w+`, MatchType: db.MatchRegex}, "join T.ME/chat", true},
+		{"regex miss", db.BlacklistEntry{Word: `^\d+$`, MatchType: db.MatchRegex}, "12a", false},
+
+		{"exact", db.BlacklistEntry{Word: "hi", MatchType: db.MatchExact}, "  HI ", true},
+		{"exact with extra text", db.BlacklistEntry{Word: "hi", MatchType: db.MatchExact}, "hi all", false},
+
+		{"sticker entries never match text", db.BlacklistEntry{Word: "pack", FileID: "abc"}, "pack", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := tt.entry
+			bm, err := compileBlacklistEntry(&entry)
+			if err != nil {
+				t.Fatalf("compileBlacklistEntry(%q): %v", entry.Word, err)
+			}
+			if got := bm.match(tt.text, strings.ToLower(tt.text)); got != tt.want {
+				t.Errorf("match(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileBlacklistEntryInvalidRegex(t *testing.T) {
+	if _, err := compileBlacklistEntry(&db.BlacklistEntry{Word: "(", MatchType: db.MatchRegex}); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
+
+func TestSplitBlacklistPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		mt      db.BlacklistMatchType
+		word    string
+	}{
+		{"Spam", db.MatchSubstring, "spam"},
+		{"word: Spam ", db.MatchWord, "spam"},
+		{"glob:Free*", db.MatchGlob, "free*"},
+		{"regex:Ab+C", db.MatchRegex, "Ab+C"},
+		{"exact:HI", db.MatchExact, "hi"},
+	}
+
+	for _, tt := range tests {
+		mt, word := splitBlacklistPattern(tt.pattern)
+		if mt != tt.mt || word != tt.word {
+			t.Errorf("splitBlacklistPattern(%q) = %q, %q, want %q, %q", tt.pattern, mt, word, tt.mt, tt.word)
+		}
+	}
+}
@@ -0,0 +1,551 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"main/modules/db"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+	"github.com/fogleman/gg"
+)
+
+const (
+	captchaMaxAttempts = 3
+	captchaCodeChars   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	captchaCodeLength  = 5
+)
+
+// startCaptcha mutes a new member and posts their challenge. It reports
+// whether a challenge was started, in which case the welcome is deferred.
+func startCaptcha(client *tg.Client, chatID int64, user *tg.UserObj) bool {
+	if user.Bot {
+		return false
+	}
+
+	settings, err := db.GetCaptchaSettings(chatID)
+	if err != nil || !settings.Enabled {
+		return false
+	}
+
+	peer, err := client.ResolvePeer(user.ID)
+	if err != nil {
+		return false
+	}
+
+	ch := &db.CaptchaChallenge{
+		ChatID:    chatID,
+		UserID:    user.ID,
+		Mode:      settings.Mode,
+		ExpiresAt: time.Now().Add(time.Duration(settings.TimeoutSec) * time.Second),
+	}
+	// Keep any restrictions the user already has, so passing the captcha
+	// doesn't lift them.
+	if member, err := client.GetChatMember(chatID, user.ID); err == nil {
+		if banned, ok := member.Participant.(*tg.ChannelParticipantBanned); ok && banned.BannedRights != nil {
+			ch.Restrictions, _ = json.Marshal(banned.BannedRights)
+		}
+	}
+
+	if _, err := client.EditBanned(chatID, peer, &tg.BannedOptions{Mute: true}); err != nil {
+		log.Printf("Failed to mute %d for captcha in %d: %v", user.ID, chatID, err)
+		return false
+	}
+
+	mention := userMention(user.ID, user.FirstName)
+	timeout := formatAdminDuration(time.Duration(settings.TimeoutSec) * time.Second)
+
+	var msg *tg.NewMessage
+	switch settings.Mode {
+	case db.CaptchaModeMath:
+		a, b := rand.Intn(20)+1, rand.Intn(20)+1
+		question := fmt.Sprintf("%d + %d", a, b)
+		if rand.Intn(2) == 0 && a > b {
+			question = fmt.Sprintf("%d - %d", a, b)
+			b = -b
+		}
+		ch.Answer = strconv.Itoa(a + b)
+		text := fmt.Sprintf("Welcome %s! Solve <b>%s</b> within %s to start chatting.", mention, question, timeout)
+		msg, err = client.SendMessage(chatID, text, &tg.SendOptions{ReplyMarkup: captchaKeyboard(user.ID, ch.Answer, mathDecoys(a+b))})
+
+	case db.CaptchaModeText:
+		ch.Answer = randomCaptchaCode()
+		img, imgErr := renderCaptchaImage(ch.Answer)
+		if imgErr != nil {
+			log.Printf("Failed to render captcha image: %v", imgErr)
+			releaseCaptcha(client, ch)
+			return false
+		}
+		decoys := make([]string, 3)
+		for i := range decoys {
+			decoys[i] = randomCaptchaCode()
+		}
+		text := fmt.Sprintf("Welcome %s! Tap the code shown in the image within %s to start chatting.", mention, timeout)
+		msg, err = client.SendMedia(chatID, img, &tg.MediaOptions{
+			FileName:    "captcha.png",
+			Caption:     text,
+			ReplyMarkup: captchaKeyboard(user.ID, ch.Answer, decoys),
+		})
+
+	default:
+		ch.Mode = db.CaptchaModeButton
+		ch.Answer = "ok"
+		text := fmt.Sprintf("Welcome %s! Tap the button below within %s to prove you're human.", mention, timeout)
+		msg, err = client.SendMessage(chatID, text, &tg.SendOptions{
+			ReplyMarkup: tg.NewKeyboard().AddRow(
+				tg.Button.Data("I'm human", fmt.Sprintf("captcha_%d_ok", user.ID)),
+			).Build(),
+		})
+	}
+
+	if err != nil {
+		log.Printf("Failed to send captcha in %d: %v", chatID, err)
+		releaseCaptcha(client, ch)
+		return false
+	}
+	ch.MessageID = msg.ID
+
+	if err := db.SaveCaptchaChallenge(ch); err != nil {
+		log.Printf("Failed to save captcha for %d in %d: %v", user.ID, chatID, err)
+	}
+	scheduleCaptchaExpiry(client, ch)
+	return true
+}
+
+// releaseCaptcha lifts the captcha mute, putting back the restrictions the
+// user had before the challenge, if any.
+func releaseCaptcha(client *tg.Client, ch *db.CaptchaChallenge) {
+	peer, err := client.ResolvePeer(ch.UserID)
+	if err != nil {
+		return
+	}
+
+	var rights tg.ChatBannedRights
+	if len(ch.Restrictions) > 0 && json.Unmarshal(ch.Restrictions, &rights) == nil &&
+		(rights.UntilDate == 0 || time.Unix(int64(rights.UntilDate), 0).After(time.Now())) {
+		client.EditBanned(ch.ChatID, peer, &tg.BannedOptions{Mute: rights.SendMessages, Rights: &rights})
+		return
+	}
+	client.EditBanned(ch.ChatID, peer, &tg.BannedOptions{Unmute: true})
+}
+
+func mathDecoys(answer int) []string {
+	seen := map[int]bool{answer: true}
+	var decoys []string
+	for len(decoys) < 3 {
+		d := answer + rand.Intn(11) - 5
+		if !seen[d] {
+			seen[d] = true
+			decoys = append(decoys, strconv.Itoa(d))
+		}
+	}
+	return decoys
+}
+
+// captchaKeyboard lays the answer and decoys out in a shuffled 2x2 grid.
+func captchaKeyboard(userID int64, answer string, decoys []string) tg.ReplyMarkup {
+	options := append([]string{answer}, decoys...)
+	rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+
+	b := tg.Button
+	kb := tg.NewKeyboard()
+	for i := 0; i < len(options); i += 2 {
+		row := []tg.KeyboardButton{b.Data(options[i], fmt.Sprintf("captcha_%d_%s", userID, options[i]))}
+		if i+1 < len(options) {
+			row = append(row, b.Data(options[i+1], fmt.Sprintf("captcha_%d_%s", userID, options[i+1])))
+		}
+		kb.AddRow(row...)
+	}
+	return kb.Build()
+}
+
+func randomCaptchaCode() string {
+	code := make([]byte, captchaCodeLength)
+	for i := range code {
+		code[i] = captchaCodeChars[rand.Intn(len(captchaCodeChars))]
+	}
+	return string(code)
+}
+
+// renderCaptchaImage draws code with jittered, rotated glyphs over noise lines.
+func renderCaptchaImage(code string) ([]byte, error) {
+	const width, height = 360, 140
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(0.95, 0.95, 0.95)
+	dc.Clear()
+
+	for range 10 {
+		dc.SetRGB(rand.Float64()*0.7, rand.Float64()*0.7, rand.Float64()*0.7)
+		dc.SetLineWidth(1 + rand.Float64()*2)
+		dc.DrawLine(rand.Float64()*width, rand.Float64()*height, rand.Float64()*width, rand.Float64()*height)
+		dc.Stroke()
+	}
+
+	if err := dc.LoadFontFace("./assets/"+getRandomFont(), 52); err != nil {
+		return nil, err
+	}
+
+	step := float64(width) / float64(len(code)+1)
+	for i, r := range code {
+		x := step * float64(i+1)
+		y := height/2 + rand.Float64()*20 - 10
+		dc.Push()
+		dc.RotateAbout(gg.Radians(rand.Float64()*40-20), x, y)
+		dc.SetRGB(rand.Float64()*0.5, rand.Float64()*0.5, rand.Float64()*0.5)
+		dc.DrawStringAnchored(string(r), x, y, 0.5, 0.5)
+		dc.Pop()
+	}
+
+	var buf bytes.Buffer
+	if err := dc.EncodePNG(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	// captchaExpiries maps "chatID:userID" to the challenge's expiry timer.
+	captchaExpiries   = make(map[string]*time.Timer)
+	captchaExpiriesMu sync.Mutex
+)
+
+func scheduleCaptchaExpiry(client *tg.Client, ch *db.CaptchaChallenge) {
+	key := fmt.Sprintf("%d:%d", ch.ChatID, ch.UserID)
+	captchaExpiriesMu.Lock()
+	defer captchaExpiriesMu.Unlock()
+
+	if old, ok := captchaExpiries[key]; ok {
+		old.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(ch.ExpiresAt), func() {
+		captchaExpiriesMu.Lock()
+		if captchaExpiries[key] == timer {
+			delete(captchaExpiries, key)
+		}
+		captchaExpiriesMu.Unlock()
+
+		current, err := db.GetCaptchaChallenge(ch.ChatID, ch.UserID)
+		if err != nil || current == nil || time.Now().Before(current.ExpiresAt) {
+			// Solved, or replaced by a newer challenge after a rejoin.
+			return
+		}
+		failCaptcha(client, current, "Captcha not solved in time")
+	})
+	captchaExpiries[key] = timer
+}
+
+// restoreCaptchas re-arms expiry timers for challenges that were pending when
+// the bot stopped, so nobody is left muted after a restart.
+func restoreCaptchas(client *tg.Client) {
+	challenges, err := db.GetCaptchaChallenges()
+	if err != nil {
+		log.Printf("Failed to load pending captchas: %v", err)
+		return
+	}
+	for _, ch := range challenges {
+		scheduleCaptchaExpiry(client, ch)
+	}
+}
+
+// rescheduleCaptchas stops every expiry timer and arms the ones for the
+// challenges in the database again, after a restore swapped it out.
+func rescheduleCaptchas(client *tg.Client) {
+	captchaExpiriesMu.Lock()
+	for key, t := range captchaExpiries {
+		t.Stop()
+		delete(captchaExpiries, key)
+	}
+	captchaExpiriesMu.Unlock()
+
+	restoreCaptchas(client)
+}
+
+func failCaptcha(client *tg.Client, ch *db.CaptchaChallenge, reason string) {
+	db.DeleteCaptchaChallenge(ch.ChatID, ch.UserID)
+	client.DeleteMessages(ch.ChatID, []int32{ch.MessageID})
+
+	peer, err := client.ResolvePeer(ch.UserID)
+	if err != nil {
+		return
+	}
+
+	settings, _ := db.GetCaptchaSettings(ch.ChatID)
+	botID := client.Me().ID
+	action := db.CaptchaFailKick
+	if settings != nil && settings.FailAction == db.CaptchaFailBan {
+		action = db.CaptchaFailBan
+	}
+
+	if action == db.CaptchaFailBan {
+		_, err = performBan(client, ch.ChatID, peer, reason, botID)
+	} else {
+		_, err = performKick(client, ch.ChatID, peer, reason, botID)
+	}
+	if err != nil {
+		log.Printf("Failed to %s %d after captcha in %d: %v", action, ch.UserID, ch.ChatID, err)
+		return
+	}
+
+	go sendLogEvent(client, &LogEvent{
+		Category: actionLogCategory(action),
+		Action:   "captcha " + action,
+		ChatID:   ch.ChatID,
+		ActorID:  botID,
+		TargetID: ch.UserID,
+		Reason:   reason,
+	})
+}
+
+// CaptchaCallback - captcha_<userID>_<answer>
+func CaptchaCallback(c *tg.CallbackQuery) error {
+	parts := strings.SplitN(strings.TrimPrefix(c.DataString(), "captcha_"), "_", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	if c.SenderID != userID {
+		c.Answer("This captcha is not for you", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	ch, err := db.GetCaptchaChallenge(c.ChatID, userID)
+	if err != nil || ch == nil {
+		c.Answer("This captcha has expired")
+		return nil
+	}
+
+	if parts[1] != ch.Answer {
+		ch.Attempts++
+		if ch.Attempts >= captchaMaxAttempts {
+			c.Answer("Wrong answer", &tg.CallbackOptions{Alert: true})
+			failCaptcha(c.Client, ch, "Failed captcha")
+			return nil
+		}
+		db.SaveCaptchaChallenge(ch)
+		c.Answer(fmt.Sprintf("Wrong answer, %d attempts left", captchaMaxAttempts-ch.Attempts), &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	db.DeleteCaptchaChallenge(ch.ChatID, ch.UserID)
+	c.Client.DeleteMessages(ch.ChatID, []int32{ch.MessageID})
+
+	releaseCaptcha(c.Client, ch)
+	c.Answer("Verified, welcome!")
+
+	if user, err := c.Client.GetUser(userID); err == nil && user != nil {
+		sendWelcome(c.Client, ch.ChatID, user)
+	}
+	return nil
+}
+
+func formatCaptchaSettings(settings *db.CaptchaSettings) string {
+	status := "disabled"
+	if settings.Enabled {
+		status = "enabled"
+	}
+	return fmt.Sprintf(`<b>Captcha</b>
+
+<b>Status:</b> %s
+<b>Mode:</b> %s
+<b>Timeout:</b> %s
+<b>On failure:</b> %s`, status, settings.Mode, formatAdminDuration(time.Duration(settings.TimeoutSec)*time.Second), settings.FailAction)
+}
+
+// CaptchaHandler - /captcha on|off
+func CaptchaHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Captcha can only be used in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "ban") {
+		m.Reply("You need Ban Users permission to change captcha settings")
+		return nil
+	}
+
+	settings, _ := db.GetCaptchaSettings(m.ChatID())
+
+	switch strings.ToLower(strings.TrimSpace(m.Args())) {
+	case "on", "yes", "enable":
+		if !CanBot(m.Client, m.Channel, "ban") {
+			m.Reply("I need Ban Users permission to mute new members")
+			return nil
+		}
+		settings.Enabled = true
+	case "off", "no", "disable":
+		settings.Enabled = false
+	default:
+		m.Reply(formatCaptchaSettings(settings) + "\n\nUsage: /captcha on/off")
+		return nil
+	}
+
+	if err := db.SetCaptchaSettings(m.ChatID(), settings); err != nil {
+		m.Reply("Failed to update captcha settings")
+		return nil
+	}
+
+	if settings.Enabled {
+		logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "captcha on"})
+		m.Reply(fmt.Sprintf("Captcha enabled. New members must solve a <b>%s</b> captcha before they can chat.", settings.Mode))
+	} else {
+		logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "captcha off"})
+		m.Reply("Captcha disabled")
+	}
+	return nil
+}
+
+// CaptchaModeHandler - /captchamode button|math|text
+func CaptchaModeHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Captcha can only be used in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "ban") {
+		m.Reply("You need Ban Users permission to change captcha settings")
+		return nil
+	}
+
+	settings, _ := db.GetCaptchaSettings(m.ChatID())
+
+	mode := strings.ToLower(strings.TrimSpace(m.Args()))
+	switch mode {
+	case db.CaptchaModeButton, db.CaptchaModeMath, db.CaptchaModeText:
+		settings.Mode = mode
+	default:
+		m.Reply(fmt.Sprintf("Current mode: <b>%s</b>\n\nUsage: /captchamode button|math|text", settings.Mode))
+		return nil
+	}
+
+	if err := db.SetCaptchaSettings(m.ChatID(), settings); err != nil {
+		m.Reply("Failed to update captcha settings")
+		return nil
+	}
+
+	logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "captchamode", Details: mode})
+	m.Reply(fmt.Sprintf("Captcha mode set to <b>%s</b>", mode))
+	return nil
+}
+
+// CaptchaTimeoutHandler - /captchatimeout <duration>
+func CaptchaTimeoutHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Captcha can only be used in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "ban") {
+		m.Reply("You need Ban Users permission to change captcha settings")
+		return nil
+	}
+
+	settings, _ := db.GetCaptchaSettings(m.ChatID())
+
+	arg := strings.ToLower(strings.TrimSpace(m.Args()))
+	if arg == "" {
+		m.Reply(fmt.Sprintf("Current timeout: <b>%s</b>\n\nUsage: /captchatimeout <duration>\nExample: /captchatimeout 90s",
+			formatAdminDuration(time.Duration(settings.TimeoutSec)*time.Second)))
+		return nil
+	}
+
+	var timeout time.Duration
+	if secs, err := strconv.Atoi(arg); err == nil {
+		timeout = time.Duration(secs) * time.Second
+	} else if timeout, err = parseAdminDuration(arg); err != nil {
+		m.Reply("Invalid timeout. Examples: 90, 90s, 5m")
+		return nil
+	}
+	if timeout < 30*time.Second || timeout > 24*time.Hour {
+		m.Reply("Captcha timeout must be between 30 seconds and 24 hours")
+		return nil
+	}
+
+	settings.TimeoutSec = int(timeout / time.Second)
+	if err := db.SetCaptchaSettings(m.ChatID(), settings); err != nil {
+		m.Reply("Failed to update captcha settings")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("New members now have <b>%s</b> to solve the captcha", formatAdminDuration(timeout)))
+	return nil
+}
+
+// CaptchaActionHandler - /captchaaction kick|ban
+func CaptchaActionHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Captcha can only be used in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "ban") {
+		m.Reply("You need Ban Users permission to change captcha settings")
+		return nil
+	}
+
+	settings, _ := db.GetCaptchaSettings(m.ChatID())
+
+	action := strings.ToLower(strings.TrimSpace(m.Args()))
+	switch action {
+	case db.CaptchaFailKick, db.CaptchaFailBan:
+		settings.FailAction = action
+	default:
+		m.Reply(fmt.Sprintf("Current action: <b>%s</b>\n\nUsage: /captchaaction kick|ban", settings.FailAction))
+		return nil
+	}
+
+	if err := db.SetCaptchaSettings(m.ChatID(), settings); err != nil {
+		m.Reply("Failed to update captcha settings")
+		return nil
+	}
+
+	if action == db.CaptchaFailBan {
+		m.Reply("Members who fail the captcha will be <b>banned</b>")
+	} else {
+		m.Reply("Members who fail the captcha will be <b>kicked</b>")
+	}
+	return nil
+}
+
+func registerCaptchaHandlers() {
+	c := Client
+	c.On("cmd:captcha", CaptchaHandler)
+	c.On("cmd:captchamode", CaptchaModeHandler)
+	c.On("cmd:captchatimeout", CaptchaTimeoutHandler)
+	c.On("cmd:captchaaction", CaptchaActionHandler)
+	c.On("callback:captcha_", CaptchaCallback)
+
+	go restoreCaptchas(c)
+	db.OnRestore(func() { rescheduleCaptchas(c) })
+}
+
+func init() {
+	QueueHandlerRegistration(registerCaptchaHandlers)
+
+	Mods.AddModule("Captcha", `<b>Captcha</b>
+
+Make new members prove they're human before they can chat.
+
+<b>Commands:</b>
+/captcha on/off - Toggle captcha for new members
+/captchamode <mode> - Choose the challenge type
+/captchatimeout <duration> - Time allowed to solve (default 2m)
+/captchaaction kick/ban - What happens on failure (default kick)
+
+<b>Modes:</b>
+ - button - Tap a single button (default)
+ - math - Pick the answer to a simple sum
+ - text - Pick the code shown in an image
+
+New members are muted until they solve the challenge. Three wrong answers or running out of time removes them. The welcome message is sent once they pass.`)
+}
@@ -15,6 +15,9 @@ var (
 	handlerQueue []func()
 )
 
+// commandPrefixes are the characters that can start a command.
+const commandPrefixes = "./!-?"
+
 func InitClient(c *tg.Client) {
 	Client = c
 }
@@ -29,7 +32,7 @@ func RegisterHandlers() {
 	}
 
 	_, _ = Client.UpdatesGetState()
-	Client.SetCommandPrefixes("./!-?")
+	Client.SetCommandPrefixes(commandPrefixes)
 
 	if !LoadModules {
 		return
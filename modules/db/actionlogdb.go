@@ -0,0 +1,226 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	DefaultActionLogMaxEntries = 500
+	DefaultActionLogMaxAgeDays = 90
+)
+
+type ActionLog struct {
+	ActionID  string                 `json:"action_id"`
+	Type      string                 `json:"type"`
+	UserID    int64                  `json:"user_id"`
+	ChatID    int64                  `json:"chat_id"`
+	AdminID   int64                  `json:"admin_id"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+type ActionLogSettings struct {
+	MaxEntries int `json:"max_entries"`
+	MaxAgeDays int `json:"max_age_days"`
+}
+
+// ActionLogQuery narrows GetActionLogs; zero values match everything.
+type ActionLogQuery struct {
+	UserID int64
+	Type   string
+	Since  time.Time
+}
+
+func (q *ActionLogQuery) matches(entry *ActionLog) bool {
+	if q.UserID != 0 && entry.UserID != q.UserID {
+		return false
+	}
+	if q.Type != "" && entry.Type != q.Type {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Timestamp.Before(q.Since) {
+		return false
+	}
+	return true
+}
+
+func getActionLogSettings(tx *bolt.Tx, chatID int64) *ActionLogSettings {
+	settings := &ActionLogSettings{
+		MaxEntries: DefaultActionLogMaxEntries,
+		MaxAgeDays: DefaultActionLogMaxAgeDays,
+	}
+	b := tx.Bucket([]byte("action_log_settings"))
+	if b == nil {
+		return settings
+	}
+	data := b.Get([]byte(strconv.FormatInt(chatID, 10)))
+	if data != nil {
+		json.Unmarshal(data, settings)
+	}
+	return settings
+}
+
+// pruneActionLog drops entries older than the chat's max age and then the
+// oldest entries beyond its max count. Keys are sequence numbers, so cursor
+// order is insertion order.
+func pruneActionLog(cb *bolt.Bucket, settings *ActionLogSettings) error {
+	var stale [][]byte
+
+	if settings.MaxAgeDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -settings.MaxAgeDays)
+		c := cb.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var entry ActionLog
+			if err := json.Unmarshal(v, &entry); err == nil && entry.Timestamp.After(cutoff) {
+				break
+			}
+			stale = append(stale, append([]byte(nil), k...))
+		}
+	}
+
+	if settings.MaxEntries > 0 {
+		total := 0
+		c := cb.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			total++
+		}
+
+		excess := total - len(stale) - settings.MaxEntries
+		k, _ := c.First()
+		for i := 0; i < len(stale) && k != nil; i++ {
+			k, _ = c.Next()
+		}
+		for ; excess > 0 && k != nil; k, _ = c.Next() {
+			stale = append(stale, append([]byte(nil), k...))
+			excess--
+		}
+	}
+
+	for _, k := range stale {
+		if err := cb.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func AddActionLog(chatID int64, entry *ActionLog) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("action_log"))
+		cb, err := b.CreateBucketIfNotExists([]byte(strconv.FormatInt(chatID, 10)))
+		if err != nil {
+			return err
+		}
+
+		id, _ := cb.NextSequence()
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := cb.Put(itob(int(id)), data); err != nil {
+			return err
+		}
+
+		return pruneActionLog(cb, getActionLogSettings(tx, chatID))
+	})
+}
+
+// GetActionLogs returns the chat's matching entries, newest first.
+func GetActionLogs(chatID int64, query *ActionLogQuery) ([]*ActionLog, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	if query == nil {
+		query = &ActionLogQuery{}
+	}
+
+	var entries []*ActionLog
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("action_log"))
+		if b == nil {
+			return nil
+		}
+		cb := b.Bucket([]byte(strconv.FormatInt(chatID, 10)))
+		if cb == nil {
+			return nil
+		}
+
+		c := cb.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var entry ActionLog
+			if err := json.Unmarshal(v, &entry); err != nil {
+				continue
+			}
+			if !query.Since.IsZero() && entry.Timestamp.Before(query.Since) {
+				break
+			}
+			if query.matches(&entry) {
+				entries = append(entries, &entry)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// FindRecentAction returns the newest entry of the given type against userID
+// recorded within the window, or nil if there is none.
+func FindRecentAction(chatID, userID int64, actionType string, within time.Duration) (*ActionLog, error) {
+	entries, err := GetActionLogs(chatID, &ActionLogQuery{
+		UserID: userID,
+		Type:   actionType,
+		Since:  time.Now().Add(-within),
+	})
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+func SetActionLogSettings(chatID int64, settings *ActionLogSettings) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket([]byte("action_log_settings")).Put([]byte(strconv.FormatInt(chatID, 10)), data); err != nil {
+			return err
+		}
+
+		cb := tx.Bucket([]byte("action_log")).Bucket([]byte(strconv.FormatInt(chatID, 10)))
+		if cb == nil {
+			return nil
+		}
+		return pruneActionLog(cb, settings)
+	})
+}
+
+func GetActionLogSettings(chatID int64) (*ActionLogSettings, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var settings *ActionLogSettings
+	err = db.View(func(tx *bolt.Tx) error {
+		settings = getActionLogSettings(tx, chatID)
+		return nil
+	})
+	return settings, err
+}
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// MaxAFKMentions caps how many mentions are kept for the digest; the count
+// keeps going past it.
+const MaxAFKMentions = 50
+
+type AFKStatus struct {
+	UserID       int64        `json:"user_id"`
+	Name         string       `json:"name"`
+	Username     string       `json:"username,omitempty"`
+	Reason       string       `json:"reason,omitempty"`
+	Media        string       `json:"media,omitempty"`
+	Since        time.Time    `json:"since"`
+	MentionCount int          `json:"mention_count,omitempty"`
+	Mentions     []AFKMention `json:"mentions,omitempty"`
+}
+
+type AFKMention struct {
+	ChatID    int64  `json:"chat_id"`
+	ChatTitle string `json:"chat_title,omitempty"`
+	MessageID int32  `json:"message_id"`
+	FromID    int64  `json:"from_id"`
+	FromName  string `json:"from_name,omitempty"`
+	// Link points at the message; only supergroups have one.
+	Link string    `json:"link,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Buckets:
+//
+//	afk            userID           -> AFKStatus
+//	afk_usernames  lower(username)  -> userID
+//	afk_digest     userID           -> "1" when the digest DM is wanted
+
+// afkUsers mirrors the keys of the afk bucket, so RemoveAFK, which runs for
+// every message, only opens a write transaction for users who are AFK. It is
+// loaded on first use and dropped after a restore.
+var afkUsers struct {
+	sync.RWMutex
+	ids map[int64]bool
+}
+
+func init() {
+	OnRestore(func() {
+		afkUsers.Lock()
+		afkUsers.ids = nil
+		afkUsers.Unlock()
+	})
+}
+
+func isAFK(db *DB, userID int64) (bool, error) {
+	afkUsers.RLock()
+	ids := afkUsers.ids
+	afk := ids[userID]
+	afkUsers.RUnlock()
+	if ids != nil {
+		return afk, nil
+	}
+
+	afkUsers.Lock()
+	defer afkUsers.Unlock()
+	if afkUsers.ids == nil {
+		ids := make(map[int64]bool)
+		err := db.View(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("afk")).ForEach(func(k, _ []byte) error {
+				if id, err := strconv.ParseInt(string(k), 10, 64); err == nil {
+					ids[id] = true
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return false, err
+		}
+		afkUsers.ids = ids
+	}
+	return afkUsers.ids[userID], nil
+}
+
+// markAFK updates afkUsers after a committed change; an unloaded set picks
+// the change up when it loads.
+func markAFK(userID int64, afk bool) {
+	afkUsers.Lock()
+	if afkUsers.ids != nil {
+		if afk {
+			afkUsers.ids[userID] = true
+		} else {
+			delete(afkUsers.ids, userID)
+		}
+	}
+	afkUsers.Unlock()
+}
+
+func getAFK(tx *bolt.Tx, userID int64) (*AFKStatus, error) {
+	data := tx.Bucket([]byte("afk")).Get([]byte(strconv.FormatInt(userID, 10)))
+	if data == nil {
+		return nil, nil
+	}
+	status := &AFKStatus{}
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func putAFK(tx *bolt.Tx, status *AFKStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("afk")).Put([]byte(strconv.FormatInt(status.UserID, 10)), data)
+}
+
+func SetAFK(status *AFKStatus) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if old, _ := getAFK(tx, status.UserID); old != nil && old.Username != "" {
+			tx.Bucket([]byte("afk_usernames")).Delete([]byte(strings.ToLower(old.Username)))
+		}
+		if status.Username != "" {
+			if err := tx.Bucket([]byte("afk_usernames")).Put([]byte(strings.ToLower(status.Username)), []byte(strconv.FormatInt(status.UserID, 10))); err != nil {
+				return err
+			}
+		}
+		return putAFK(tx, status)
+	})
+	if err == nil {
+		markAFK(status.UserID, true)
+	}
+	return err
+}
+
+// GetAFK returns the user's AFK status, or nil if they are not AFK.
+func GetAFK(userID int64) (*AFKStatus, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	if afk, err := isAFK(db, userID); err != nil || !afk {
+		return nil, err
+	}
+
+	var status *AFKStatus
+	err = db.View(func(tx *bolt.Tx) error {
+		status, err = getAFK(tx, userID)
+		return err
+	})
+	return status, err
+}
+
+// GetAFKByUsername looks up an AFK user by @username (without the @).
+func GetAFKByUsername(username string) (*AFKStatus, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var status *AFKStatus
+	err = db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket([]byte("afk_usernames")).Get([]byte(strings.ToLower(username)))
+		if id == nil {
+			return nil
+		}
+		userID, err := strconv.ParseInt(string(id), 10, 64)
+		if err != nil {
+			return nil
+		}
+		status, err = getAFK(tx, userID)
+		return err
+	})
+	return status, err
+}
+
+// RemoveAFK clears the user's AFK status and returns what it was, or nil if
+// they were not AFK.
+func RemoveAFK(userID int64) (*AFKStatus, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	if afk, err := isAFK(db, userID); err != nil || !afk {
+		return nil, err
+	}
+
+	var status *AFKStatus
+	err = db.Update(func(tx *bolt.Tx) error {
+		status, err = getAFK(tx, userID)
+		if err != nil || status == nil {
+			return err
+		}
+		if status.Username != "" {
+			tx.Bucket([]byte("afk_usernames")).Delete([]byte(strings.ToLower(status.Username)))
+		}
+		return tx.Bucket([]byte("afk")).Delete([]byte(strconv.FormatInt(userID, 10)))
+	})
+	if err == nil {
+		markAFK(userID, false)
+	}
+	return status, err
+}
+
+// AddAFKMention records that userID was mentioned while AFK.
+func AddAFKMention(userID int64, mention AFKMention) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		status, err := getAFK(tx, userID)
+		if err != nil || status == nil {
+			return err
+		}
+		status.MentionCount++
+		if len(status.Mentions) < MaxAFKMentions {
+			status.Mentions = append(status.Mentions, mention)
+		}
+		return putAFK(tx, status)
+	})
+}
+
+func SetAFKDigest(userID int64, enabled bool) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("afk_digest"))
+		key := []byte(strconv.FormatInt(userID, 10))
+		if !enabled {
+			return b.Delete(key)
+		}
+		return b.Put(key, []byte("1"))
+	})
+}
+
+func GetAFKDigest(userID int64) bool {
+	db, err := GetDB()
+	if err != nil {
+		return false
+	}
+
+	enabled := false
+	db.View(func(tx *bolt.Tx) error {
+		enabled = tx.Bucket([]byte("afk_digest")).Get([]byte(strconv.FormatInt(userID, 10))) != nil
+		return nil
+	})
+	return enabled
+}
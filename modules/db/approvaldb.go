@@ -0,0 +1,140 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Approval marks a member as trusted in a chat; approved users are skipped
+// by blacklist, flood and lock enforcement.
+type Approval struct {
+	ChatID     int64     `json:"chat_id"`
+	UserID     int64     `json:"user_id"`
+	Name       string    `json:"name,omitempty"`
+	ApprovedBy int64     `json:"approved_by"`
+	ApprovedAt time.Time `json:"approved_at"`
+}
+
+// Buckets:
+//
+//	approvals  chat:user  -> Approval
+func approvalKey(chatID, userID int64) []byte {
+	return []byte(fmt.Sprintf("%d:%d", chatID, userID))
+}
+
+func approvalPrefix(chatID int64) []byte {
+	return []byte(fmt.Sprintf("%d:", chatID))
+}
+
+func ApproveUser(a *Approval) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("approvals")).Put(approvalKey(a.ChatID, a.UserID), data)
+	})
+}
+
+// UnapproveUser reports whether userID was approved in chatID.
+func UnapproveUser(chatID, userID int64) (bool, error) {
+	db, err := GetDB()
+	if err != nil {
+		return false, err
+	}
+
+	var found bool
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("approvals"))
+		key := approvalKey(chatID, userID)
+		found = b.Get(key) != nil
+		return b.Delete(key)
+	})
+	return found, err
+}
+
+// GetApproval returns nil if userID isn't approved in chatID.
+func GetApproval(chatID, userID int64) (*Approval, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var a *Approval
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("approvals"))
+		if b == nil {
+			return nil
+		}
+		data := b.Get(approvalKey(chatID, userID))
+		if data == nil {
+			return nil
+		}
+		a = &Approval{}
+		return json.Unmarshal(data, a)
+	})
+	return a, err
+}
+
+func IsApproved(chatID, userID int64) bool {
+	a, err := GetApproval(chatID, userID)
+	return err == nil && a != nil
+}
+
+// GetApprovals returns chatID's approved users, oldest first.
+func GetApprovals(chatID int64) ([]*Approval, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var approvals []*Approval
+	prefix := approvalPrefix(chatID)
+	err = db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("approvals")).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var a Approval
+			if err := json.Unmarshal(v, &a); err == nil {
+				approvals = append(approvals, &a)
+			}
+		}
+		return nil
+	})
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].ApprovedAt.Before(approvals[j].ApprovedAt)
+	})
+	return approvals, err
+}
+
+// ClearApprovals removes every approval in chatID and returns how many there
+// were.
+func ClearApprovals(chatID int64) (int, error) {
+	db, err := GetDB()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	prefix := approvalPrefix(chatID)
+	err = db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte("approvals")).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
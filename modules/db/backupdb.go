@@ -0,0 +1,120 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BackupRecord is a database backup uploaded to the backup chat.
+type BackupRecord struct {
+	ChatID    int64     `json:"chat_id"`
+	MessageID int32     `json:"message_id"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var restoreHooks []func()
+
+// OnRestore registers fn to run after RestoreDB swaps in a new database, for
+// caches that need dropping.
+func OnRestore(fn func()) {
+	restoreHooks = append(restoreHooks, fn)
+}
+
+// Buckets:
+//
+//	backups  chatID:messageID -> BackupRecord
+func backupKey(chatID int64, messageID int32) []byte {
+	return []byte(strconv.FormatInt(chatID, 10) + ":" + strconv.FormatInt(int64(messageID), 10))
+}
+
+// SnapshotDB writes a consistent copy of database.db to w.
+func SnapshotDB(w io.Writer) (int64, error) {
+	db, err := GetDB()
+	if err != nil {
+		return 0, err
+	}
+	return db.Snapshot(w)
+}
+
+// RestoreDB replaces database.db with the bbolt file read from r. Records of
+// backups made since the snapshot was taken are carried over so they still
+// get pruned.
+func RestoreDB(r io.Reader) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	records, _ := GetBackupRecords()
+	if err := db.Restore(r); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		SaveBackupRecord(rec)
+	}
+	_, migrateErr := RunMigrations(false)
+	for _, fn := range restoreHooks {
+		fn()
+	}
+	if migrateErr != nil {
+		return fmt.Errorf("restored, but schema migrations failed: %w", migrateErr)
+	}
+	return nil
+}
+
+func SaveBackupRecord(rec *BackupRecord) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("backups")).Put(backupKey(rec.ChatID, rec.MessageID), data)
+	})
+}
+
+func DeleteBackupRecord(rec *BackupRecord) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("backups")).Delete(backupKey(rec.ChatID, rec.MessageID))
+	})
+}
+
+// GetBackupRecords returns every recorded backup, oldest first.
+func GetBackupRecords() ([]*BackupRecord, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*BackupRecord
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("backups")).ForEach(func(k, v []byte) error {
+			var rec BackupRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return nil
+			}
+			records = append(records, &rec)
+			return nil
+		})
+	})
+	slices.SortFunc(records, func(a, b *BackupRecord) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return records, err
+}
@@ -17,6 +17,16 @@ const (
 	ActionTMute  BlacklistAction = "tmute"
 )
 
+type BlacklistMatchType string
+
+const (
+	MatchSubstring BlacklistMatchType = "substring"
+	MatchWord      BlacklistMatchType = "word"
+	MatchGlob      BlacklistMatchType = "glob"
+	MatchRegex     BlacklistMatchType = "regex"
+	MatchExact     BlacklistMatchType = "exact"
+)
+
 type BlacklistSettings struct {
 	Action   BlacklistAction `json:"action"`
 	Duration string          `json:"duration,omitempty"`
@@ -27,29 +37,30 @@ type BlacklistEntry struct {
 	FileID      string `json:"file_id,omitempty"`
 	MessageLink string `json:"message_link,omitempty"`
 	AddedBy     int64  `json:"added_by"`
+	// MatchType is empty for entries saved before match types existed; those
+	// behave as MatchSubstring.
+	MatchType BlacklistMatchType `json:"match_type,omitempty"`
+	// Action and Duration override the chat's BlacklistSettings when set.
+	Action   BlacklistAction `json:"action,omitempty"`
+	Duration string          `json:"duration,omitempty"`
 }
 
-func ensureBlacklistBuckets(db *bolt.DB) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("blacklist"))
-		if err != nil {
-			return err
-		}
-		_, err = tx.CreateBucketIfNotExists([]byte("blacklist_settings"))
-		return err
-	})
+var blacklistHooks []func(chatID int64)
+
+// OnBlacklistChange registers fn to run after a chat's blacklist entries are
+// added or removed.
+func OnBlacklistChange(fn func(chatID int64)) {
+	blacklistHooks = append(blacklistHooks, fn)
 }
 
-func AddBlacklist(chatID int64, entry *BlacklistEntry) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureBlacklistBuckets(db); err != nil {
-		return err
+func notifyBlacklistChange(chatID int64) {
+	for _, fn := range blacklistHooks {
+		fn(chatID)
 	}
+}
 
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) AddBlacklist(chatID int64, entry *BlacklistEntry) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		chatsBucket := tx.Bucket([]byte("blacklist"))
 		chatBucket, err := chatsBucket.CreateBucketIfNotExists([]byte(strconv.FormatInt(chatID, 10)))
 		if err != nil {
@@ -65,16 +76,8 @@ func AddBlacklist(chatID int64, entry *BlacklistEntry) error {
 	})
 }
 
-func RemoveBlacklist(chatID int64, word string) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureBlacklistBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) RemoveBlacklist(chatID int64, word string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		chatsBucket := tx.Bucket([]byte("blacklist"))
 		if chatsBucket == nil {
 			return nil
@@ -88,17 +91,9 @@ func RemoveBlacklist(chatID int64, word string) error {
 	})
 }
 
-func GetBlacklist(chatID int64) ([]*BlacklistEntry, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureBlacklistBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetBlacklist(chatID int64) ([]*BlacklistEntry, error) {
 	var entries []*BlacklistEntry
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		chatsBucket := tx.Bucket([]byte("blacklist"))
 		if chatsBucket == nil {
 			return nil
@@ -122,17 +117,9 @@ func GetBlacklist(chatID int64) ([]*BlacklistEntry, error) {
 	return entries, err
 }
 
-func IsBlacklisted(chatID int64, word string) bool {
-	db, err := GetDB()
-	if err != nil {
-		return false
-	}
-	if err := ensureBlacklistBuckets(db); err != nil {
-		return false
-	}
-
+func (s *boltStore) IsBlacklisted(chatID int64, word string) bool {
 	found := false
-	db.View(func(tx *bolt.Tx) error {
+	s.db.View(func(tx *bolt.Tx) error {
 		chatsBucket := tx.Bucket([]byte("blacklist"))
 		if chatsBucket == nil {
 			return nil
@@ -151,16 +138,8 @@ func IsBlacklisted(chatID int64, word string) bool {
 	return found
 }
 
-func SetBlacklistSettings(chatID int64, settings *BlacklistSettings) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureBlacklistBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) SetBlacklistSettings(chatID int64, settings *BlacklistSettings) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		settingsBucket := tx.Bucket([]byte("blacklist_settings"))
 		data, err := json.Marshal(settings)
 		if err != nil {
@@ -171,17 +150,9 @@ func SetBlacklistSettings(chatID int64, settings *BlacklistSettings) error {
 	})
 }
 
-func GetBlacklistSettings(chatID int64) (*BlacklistSettings, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureBlacklistBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetBlacklistSettings(chatID int64) (*BlacklistSettings, error) {
 	settings := &BlacklistSettings{Action: ActionDelete}
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		settingsBucket := tx.Bucket([]byte("blacklist_settings"))
 		data := settingsBucket.Get([]byte(strconv.FormatInt(chatID, 10)))
 		if data == nil {
@@ -194,17 +165,9 @@ func GetBlacklistSettings(chatID int64) (*BlacklistSettings, error) {
 	return settings, err
 }
 
-func GetBlacklistCount(chatID int64) (int, error) {
-	db, err := GetDB()
-	if err != nil {
-		return 0, err
-	}
-	if err := ensureBlacklistBuckets(db); err != nil {
-		return 0, err
-	}
-
+func (s *boltStore) GetBlacklistCount(chatID int64) (int, error) {
 	count := 0
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		chatsBucket := tx.Bucket([]byte("blacklist"))
 		if chatsBucket == nil {
 			return nil
@@ -224,16 +187,8 @@ func GetBlacklistCount(chatID int64) (int, error) {
 	return count, err
 }
 
-func ClearBlacklist(chatID int64) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureBlacklistBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) ClearBlacklist(chatID int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		chatsBucket := tx.Bucket([]byte("blacklist"))
 		if chatsBucket == nil {
 			return nil
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	CaptchaModeButton = "button"
+	CaptchaModeMath   = "math"
+	CaptchaModeText   = "text"
+
+	CaptchaFailKick = "kick"
+	CaptchaFailBan  = "ban"
+
+	DefaultCaptchaTimeoutSec = 120
+)
+
+type CaptchaSettings struct {
+	Enabled    bool   `json:"enabled"`
+	Mode       string `json:"mode"`
+	TimeoutSec int    `json:"timeout_sec"`
+	FailAction string `json:"fail_action"`
+}
+
+// CaptchaChallenge is a new member who has been muted until they answer.
+type CaptchaChallenge struct {
+	ChatID    int64     `json:"chat_id"`
+	UserID    int64     `json:"user_id"`
+	Mode      string    `json:"mode"`
+	Answer    string    `json:"answer"`
+	MessageID int32     `json:"message_id"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Restrictions are the user's banned rights from before the challenge,
+	// restored once it is solved.
+	Restrictions json.RawMessage `json:"restrictions,omitempty"`
+}
+
+// Buckets:
+//
+//	captcha_settings  chatID      -> CaptchaSettings
+//	captcha_pending   chat:user   -> CaptchaChallenge
+func captchaKey(chatID, userID int64) []byte {
+	return []byte(fmt.Sprintf("%d:%d", chatID, userID))
+}
+
+func SetCaptchaSettings(chatID int64, settings *CaptchaSettings) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("captcha_settings")).Put([]byte(strconv.FormatInt(chatID, 10)), data)
+	})
+}
+
+func GetCaptchaSettings(chatID int64) (*CaptchaSettings, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &CaptchaSettings{
+		Mode:       CaptchaModeButton,
+		TimeoutSec: DefaultCaptchaTimeoutSec,
+		FailAction: CaptchaFailKick,
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("captcha_settings")).Get([]byte(strconv.FormatInt(chatID, 10)))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, settings)
+	})
+	return settings, err
+}
+
+func SaveCaptchaChallenge(ch *CaptchaChallenge) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(ch)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("captcha_pending")).Put(captchaKey(ch.ChatID, ch.UserID), data)
+	})
+}
+
+// GetCaptchaChallenge returns the pending challenge for userID, or nil.
+func GetCaptchaChallenge(chatID, userID int64) (*CaptchaChallenge, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var ch *CaptchaChallenge
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("captcha_pending")).Get(captchaKey(chatID, userID))
+		if data == nil {
+			return nil
+		}
+		ch = &CaptchaChallenge{}
+		return json.Unmarshal(data, ch)
+	})
+	return ch, err
+}
+
+func DeleteCaptchaChallenge(chatID, userID int64) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("captcha_pending")).Delete(captchaKey(chatID, userID))
+	})
+}
+
+// GetCaptchaChallenges returns every pending challenge across all chats.
+func GetCaptchaChallenges() ([]*CaptchaChallenge, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var challenges []*CaptchaChallenge
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("captcha_pending")).ForEach(func(k, v []byte) error {
+			var ch CaptchaChallenge
+			if err := json.Unmarshal(v, &ch); err == nil {
+				challenges = append(challenges, &ch)
+			}
+			return nil
+		})
+	})
+	return challenges, err
+}
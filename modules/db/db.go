@@ -2,21 +2,251 @@ package db
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"sync"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
 var (
-	sharedDB     *bolt.DB
+	sharedDB     *DB
 	sharedDBOnce sync.Once
 	sharedDBPath = "database.db"
 )
 
-func GetDB() (*bolt.DB, error) {
+// buckets are created whenever the file is opened or swapped, so readers can
+// use a plain View and rely on them existing.
+var buckets = []string{
+	"notes",
+	"filters",
+	"warns", "warns_settings",
+	"welcome",
+	"rules",
+	"blacklist", "blacklist_settings",
+	"sticker_users", "sticker_packs", "sticker_active", "sticker_next",
+	"action_log", "action_log_settings",
+	"log_channels",
+	"feds", "fed_chats", "fed_bans",
+	"flood_settings",
+	"captcha_settings", "captcha_pending",
+	"afk", "afk_usernames", "afk_digest",
+	"timers",
+	"disabled_commands",
+	"approvals",
+	"chat_locks",
+	"reports", "report_settings",
+	"backups",
+}
+
+func createBuckets(b *bolt.DB) error {
+	return b.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("create bucket %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// DB is the shared database handle returned by GetDB. Transactions go
+// through it rather than a cached *bolt.DB so Compact can swap the file
+// underneath every caller.
+type DB struct {
+	mu   sync.RWMutex
+	bolt *bolt.DB
+}
+
+func (d *DB) View(fn func(tx *bolt.Tx) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.bolt.View(fn)
+}
+
+func (d *DB) Update(fn func(tx *bolt.Tx) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.bolt.Update(fn)
+}
+
+// Stats returns bbolt's page and freelist counters.
+func (d *DB) Stats() bolt.Stats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.bolt.Stats()
+}
+
+func (d *DB) Path() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.bolt.Path()
+}
+
+// Compact rewrites the database into a fresh file and swaps it in, returning
+// the file size before and after. New transactions wait until it is done. If
+// anything fails before the swap the original file is left untouched.
+func (d *DB) Compact() (before, after int64, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := d.bolt.Path()
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath)
+
+	if info, err := os.Stat(path); err == nil {
+		before = info.Size()
+	}
+
+	dst, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return before, 0, err
+	}
+	if err := bolt.Compact(dst, d.bolt, 64<<20); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return before, 0, fmt.Errorf("compact: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return before, 0, err
+	}
+
+	if err := d.swapLocked(tmpPath, ""); err != nil {
+		return before, before, err
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		after = info.Size()
+	}
+	return before, after, nil
+}
+
+// Snapshot writes a consistent copy of the whole database to w.
+func (d *DB) Snapshot(w io.Writer) (int64, error) {
+	var n int64
+	err := d.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// Restore replaces the database with the bbolt file read from r. The file is
+// checked before anything is swapped, and the current one is kept next to it
+// with a .pre-restore suffix.
+func (d *DB) Restore(r io.Reader) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := d.bolt.Path()
+	tmpPath := path + ".restore"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = checkBoltFile(tmpPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return d.swapLocked(tmpPath, path+".pre-restore")
+}
+
+// checkBoltFile opens path read-only and walks every bucket, so a truncated
+// or foreign file is rejected before it replaces the live database.
+func checkBoltFile(path string) error {
+	check, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("not a valid database: %w", err)
+	}
+	defer check.Close()
+	return check.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return b.ForEach(func(k, v []byte) error { return nil })
+		})
+	})
+}
+
+// swapLocked closes the live file, moves newPath over it (keeping the old
+// file at backupPath if set) and reopens it. If the new file won't open, the
+// old one is moved back and reopened so the database stays usable. d.mu must
+// be held for writing.
+func (d *DB) swapLocked(newPath, backupPath string) error {
+	path := d.bolt.Path()
+	keepPath := backupPath
+	if keepPath == "" {
+		keepPath = path + ".pre-swap"
+	}
+	if err := d.bolt.Close(); err != nil {
+		os.Remove(newPath)
+		return err
+	}
+
+	os.Remove(keepPath)
+	swapErr := os.Rename(path, keepPath)
+	if swapErr == nil {
+		if swapErr = os.Rename(newPath, path); swapErr != nil {
+			os.Rename(keepPath, path)
+		}
+	}
+	if swapErr != nil {
+		os.Remove(newPath)
+	}
+
+	// Reopen whichever file is now at path, the new one unless a rename
+	// failed.
+	reopened, err := openBolt(path)
+	if err != nil && swapErr == nil {
+		if os.Rename(keepPath, path) == nil {
+			swapErr = err
+			reopened, err = openBolt(path)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("reopen %s: %w", path, err)
+	}
+	d.bolt = reopened
+	if backupPath == "" {
+		os.Remove(keepPath)
+	}
+	if swapErr != nil {
+		return fmt.Errorf("swap: %w", swapErr)
+	}
+	return nil
+}
+
+// openBolt opens path and creates any missing buckets.
+func openBolt(path string) (*bolt.DB, error) {
+	bdb, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := createBuckets(bdb); err != nil {
+		bdb.Close()
+		return nil, err
+	}
+	return bdb, nil
+}
+
+func GetDB() (*DB, error) {
 	var err error
 	sharedDBOnce.Do(func() {
-		sharedDB, err = bolt.Open(sharedDBPath, 0600, nil)
+		var bdb *bolt.DB
+		bdb, err = openBolt(sharedDBPath)
+		if err != nil {
+			return
+		}
+		sharedDB = &DB{bolt: bdb}
 	})
 	if err != nil {
 		return nil, err
@@ -28,8 +258,13 @@ func GetDB() (*bolt.DB, error) {
 }
 
 func CloseDB() error {
+	if sharedStore != nil {
+		sharedStore.Close()
+	}
 	if sharedDB != nil {
-		return sharedDB.Close()
+		sharedDB.mu.Lock()
+		defer sharedDB.mu.Unlock()
+		return sharedDB.bolt.Close()
 	}
 	return nil
 }
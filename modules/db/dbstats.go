@@ -0,0 +1,107 @@
+package db
+
+import (
+	"os"
+	"slices"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BucketStats totals one bucket including everything nested below it. Bytes
+// counts key and value data; Alloc is what bbolt has allocated in pages.
+type BucketStats struct {
+	Name     string
+	Keys     int
+	Buckets  int
+	Bytes    int64
+	Alloc    int64
+	Children []*BucketStats
+}
+
+type DBStats struct {
+	Path      string
+	FileSize  int64
+	PageSize  int
+	FreePages int
+	FreeBytes int64
+	Buckets   []*BucketStats
+}
+
+// FreeRatio is the share of the file sitting on the freelist, which is what
+// compaction reclaims.
+func (s *DBStats) FreeRatio() float64 {
+	if s.FileSize == 0 {
+		return 0
+	}
+	return float64(s.FreeBytes) / float64(s.FileSize)
+}
+
+// GetDBStats walks every bucket in database.db. Children are sorted largest
+// first.
+func GetDBStats() (*DBStats, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &DBStats{Path: db.Path()}
+	if info, err := os.Stat(stats.Path); err == nil {
+		stats.FileSize = info.Size()
+	}
+	boltStats := db.Stats()
+	stats.FreePages = boltStats.FreePageN + boltStats.PendingPageN
+	stats.FreeBytes = int64(boltStats.FreeAlloc)
+
+	err = db.View(func(tx *bolt.Tx) error {
+		stats.PageSize = tx.DB().Info().PageSize
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			bs := walkBucket(string(name), b)
+			st := b.Stats()
+			bs.Alloc = int64(st.BranchAlloc + st.LeafAlloc)
+			stats.Buckets = append(stats.Buckets, bs)
+			return nil
+		})
+	})
+	sortBucketStats(stats.Buckets)
+	return stats, err
+}
+
+func walkBucket(name string, b *bolt.Bucket) *BucketStats {
+	bs := &BucketStats{Name: name}
+	b.ForEach(func(k, v []byte) error {
+		if v != nil {
+			bs.Keys++
+			bs.Bytes += int64(len(k) + len(v))
+			return nil
+		}
+		child := walkBucket(string(k), b.Bucket(k))
+		bs.Keys += child.Keys
+		bs.Buckets += child.Buckets + 1
+		bs.Bytes += child.Bytes + int64(len(k))
+		bs.Children = append(bs.Children, child)
+		return nil
+	})
+	sortBucketStats(bs.Children)
+	return bs
+}
+
+func sortBucketStats(list []*BucketStats) {
+	slices.SortFunc(list, func(a, b *BucketStats) int {
+		switch {
+		case a.Bytes > b.Bytes:
+			return -1
+		case a.Bytes < b.Bytes:
+			return 1
+		}
+		return 0
+	})
+}
+
+// CompactDB rewrites database.db without its free pages.
+func CompactDB() (before, after int64, err error) {
+	db, err := GetDB()
+	if err != nil {
+		return 0, 0, err
+	}
+	return db.Compact()
+}
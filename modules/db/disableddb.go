@@ -0,0 +1,59 @@
+package db
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type DisabledCommands struct {
+	Commands []string `json:"commands,omitempty"`
+	// Delete removes messages using a disabled command instead of just
+	// ignoring them.
+	Delete bool `json:"delete,omitempty"`
+}
+
+func (d *DisabledCommands) IsDisabled(command string) bool {
+	return slices.Contains(d.Commands, command)
+}
+
+func SetDisabledCommands(chatID int64, disabled *DisabledCommands) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	slices.Sort(disabled.Commands)
+	disabled.Commands = slices.Compact(disabled.Commands)
+
+	return db.Update(func(tx *bolt.Tx) error {
+		key := []byte(strconv.FormatInt(chatID, 10))
+		if len(disabled.Commands) == 0 && !disabled.Delete {
+			return tx.Bucket([]byte("disabled_commands")).Delete(key)
+		}
+		data, err := json.Marshal(disabled)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("disabled_commands")).Put(key, data)
+	})
+}
+
+func GetDisabledCommands(chatID int64) (*DisabledCommands, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	disabled := &DisabledCommands{}
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("disabled_commands")).Get([]byte(strconv.FormatInt(chatID, 10)))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, disabled)
+	})
+	return disabled, err
+}
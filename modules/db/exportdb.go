@@ -0,0 +1,127 @@
+package db
+
+import (
+	"time"
+)
+
+// ChatExportVersion is bumped whenever ChatExport changes in a way older
+// importers can't read.
+const ChatExportVersion = 1
+
+const (
+	ExportSectionNotes             = "notes"
+	ExportSectionFilters           = "filters"
+	ExportSectionRules             = "rules"
+	ExportSectionWelcome           = "welcome"
+	ExportSectionGoodbye           = "goodbye"
+	ExportSectionBlacklist         = "blacklist"
+	ExportSectionBlacklistSettings = "blacklist_settings"
+	ExportSectionWarnSettings      = "warn_settings"
+	ExportSectionFloodSettings     = "flood_settings"
+	ExportSectionCaptchaSettings   = "captcha_settings"
+)
+
+var ExportSections = []string{
+	ExportSectionNotes,
+	ExportSectionFilters,
+	ExportSectionRules,
+	ExportSectionWelcome,
+	ExportSectionGoodbye,
+	ExportSectionBlacklist,
+	ExportSectionBlacklistSettings,
+	ExportSectionWarnSettings,
+	ExportSectionFloodSettings,
+	ExportSectionCaptchaSettings,
+}
+
+// ChatExport is a chat's whole configuration as written by /export.
+type ChatExport struct {
+	Version    int       `json:"version"`
+	ChatID     int64     `json:"chat_id"`
+	ChatTitle  string    `json:"chat_title,omitempty"`
+	ExportedAt time.Time `json:"exported_at"`
+
+	Notes             []*Note            `json:"notes,omitempty"`
+	Filters           []*Filter          `json:"filters,omitempty"`
+	Rules             *Rules             `json:"rules,omitempty"`
+	Welcome           *WelcomeMessage    `json:"welcome,omitempty"`
+	Goodbye           *WelcomeMessage    `json:"goodbye,omitempty"`
+	Blacklist         []*BlacklistEntry  `json:"blacklist,omitempty"`
+	BlacklistSettings *BlacklistSettings `json:"blacklist_settings,omitempty"`
+	WarnSettings      *WarnSettings      `json:"warn_settings,omitempty"`
+	FloodSettings     *FloodSettings     `json:"flood_settings,omitempty"`
+	CaptchaSettings   *CaptchaSettings   `json:"captcha_settings,omitempty"`
+
+	// FileIDs lists every media file referenced above. Bot file IDs only work
+	// for the bot that issued them.
+	FileIDs []string `json:"file_ids,omitempty"`
+}
+
+// ExportChat collects every per-chat record for chatID.
+func ExportChat(chatID int64) (*ChatExport, error) {
+	exp := &ChatExport{
+		Version:    ChatExportVersion,
+		ChatID:     chatID,
+		ExportedAt: time.Now().UTC(),
+	}
+
+	var err error
+	if exp.Notes, err = GetAllNotes(chatID); err != nil {
+		return nil, err
+	}
+	if exp.Filters, err = GetAllFilters(chatID); err != nil {
+		return nil, err
+	}
+	if exp.Rules, err = GetRulesWithMedia(chatID); err != nil {
+		return nil, err
+	}
+	if exp.Welcome, err = GetWelcome(chatID); err != nil {
+		return nil, err
+	}
+	if exp.Goodbye, err = GetGoodbye(chatID); err != nil {
+		return nil, err
+	}
+	if exp.Blacklist, err = GetBlacklist(chatID); err != nil {
+		return nil, err
+	}
+	if exp.BlacklistSettings, err = GetBlacklistSettings(chatID); err != nil {
+		return nil, err
+	}
+	if exp.WarnSettings, err = GetWarnSettings(chatID); err != nil {
+		return nil, err
+	}
+	if exp.FloodSettings, err = GetFloodSettings(chatID); err != nil {
+		return nil, err
+	}
+	if exp.CaptchaSettings, err = GetCaptchaSettings(chatID); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	addFile := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			exp.FileIDs = append(exp.FileIDs, id)
+		}
+	}
+	for _, n := range exp.Notes {
+		addFile(n.FileID)
+	}
+	for _, f := range exp.Filters {
+		addFile(f.FileID)
+	}
+	if exp.Rules != nil {
+		addFile(exp.Rules.FileID)
+	}
+	if exp.Welcome != nil {
+		addFile(exp.Welcome.FileID)
+	}
+	if exp.Goodbye != nil {
+		addFile(exp.Goodbye.FileID)
+	}
+	for _, b := range exp.Blacklist {
+		addFile(b.FileID)
+	}
+
+	return exp, nil
+}
@@ -0,0 +1,317 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrFedNotFound   = errors.New("federation not found")
+	ErrAlreadyOwnFed = errors.New("user already owns a federation")
+)
+
+type Federation struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   int64     `json:"owner_id"`
+	Admins    []int64   `json:"admins,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IsAdmin reports whether userID can issue fed bans (owner included).
+func (f *Federation) IsAdmin(userID int64) bool {
+	return f.OwnerID == userID || slices.Contains(f.Admins, userID)
+}
+
+type FedBan struct {
+	UserID    int64     `json:"user_id"`
+	Reason    string    `json:"reason,omitempty"`
+	BannedBy  int64     `json:"banned_by"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Buckets:
+//
+//	feds          fedID  -> Federation
+//	fed_chats     chatID -> fedID
+//	fed_bans      fedID/ -> userID -> FedBan
+func newFedID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func getFed(tx *bolt.Tx, fedID string) (*Federation, error) {
+	data := tx.Bucket([]byte("feds")).Get([]byte(fedID))
+	if data == nil {
+		return nil, ErrFedNotFound
+	}
+	fed := &Federation{}
+	if err := json.Unmarshal(data, fed); err != nil {
+		return nil, err
+	}
+	return fed, nil
+}
+
+func putFed(tx *bolt.Tx, fed *Federation) error {
+	data, err := json.Marshal(fed)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("feds")).Put([]byte(fed.ID), data)
+}
+
+func CreateFed(ownerID int64, name string) (*Federation, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	fed := &Federation{
+		ID:        newFedID(),
+		Name:      name,
+		OwnerID:   ownerID,
+		CreatedAt: time.Now(),
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		owned := false
+		tx.Bucket([]byte("feds")).ForEach(func(k, v []byte) error {
+			var f Federation
+			if json.Unmarshal(v, &f) == nil && f.OwnerID == ownerID {
+				owned = true
+			}
+			return nil
+		})
+		if owned {
+			return ErrAlreadyOwnFed
+		}
+
+		if _, err := tx.Bucket([]byte("fed_bans")).CreateBucketIfNotExists([]byte(fed.ID)); err != nil {
+			return err
+		}
+		return putFed(tx, fed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fed, nil
+}
+
+func GetFed(fedID string) (*Federation, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var fed *Federation
+	err = db.View(func(tx *bolt.Tx) error {
+		fed, err = getFed(tx, fedID)
+		return err
+	})
+	return fed, err
+}
+
+// GetFedByOwner returns the federation owned by ownerID, or nil.
+func GetFedByOwner(ownerID int64) (*Federation, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var fed *Federation
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("feds")).ForEach(func(k, v []byte) error {
+			var f Federation
+			if json.Unmarshal(v, &f) == nil && f.OwnerID == ownerID {
+				fed = &f
+			}
+			return nil
+		})
+	})
+	return fed, err
+}
+
+// GetChatFed returns the federation chatID belongs to, or nil.
+func GetChatFed(chatID int64) (*Federation, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var fed *Federation
+	err = db.View(func(tx *bolt.Tx) error {
+		fedID := tx.Bucket([]byte("fed_chats")).Get([]byte(strconv.FormatInt(chatID, 10)))
+		if fedID == nil {
+			return nil
+		}
+		fed, err = getFed(tx, string(fedID))
+		if errors.Is(err, ErrFedNotFound) {
+			return nil
+		}
+		return err
+	})
+	return fed, err
+}
+
+func JoinFed(chatID int64, fedID string) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		if _, err := getFed(tx, fedID); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("fed_chats")).Put([]byte(strconv.FormatInt(chatID, 10)), []byte(fedID))
+	})
+}
+
+func LeaveFed(chatID int64) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("fed_chats")).Delete([]byte(strconv.FormatInt(chatID, 10)))
+	})
+}
+
+func GetFedChats(fedID string) ([]int64, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var chats []int64
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("fed_chats")).ForEach(func(k, v []byte) error {
+			if string(v) == fedID {
+				if chatID, err := strconv.ParseInt(string(k), 10, 64); err == nil {
+					chats = append(chats, chatID)
+				}
+			}
+			return nil
+		})
+	})
+	return chats, err
+}
+
+func AddFedAdmin(fedID string, userID int64) error {
+	return updateFed(fedID, func(fed *Federation) {
+		if !slices.Contains(fed.Admins, userID) {
+			fed.Admins = append(fed.Admins, userID)
+		}
+	})
+}
+
+func RemoveFedAdmin(fedID string, userID int64) error {
+	return updateFed(fedID, func(fed *Federation) {
+		fed.Admins = slices.DeleteFunc(fed.Admins, func(id int64) bool { return id == userID })
+	})
+}
+
+func updateFed(fedID string, fn func(fed *Federation)) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		fed, err := getFed(tx, fedID)
+		if err != nil {
+			return err
+		}
+		fn(fed)
+		return putFed(tx, fed)
+	})
+}
+
+func AddFedBan(fedID string, ban *FedBan) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket([]byte("fed_bans")).CreateBucketIfNotExists([]byte(fedID))
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(ban)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(strconv.FormatInt(ban.UserID, 10)), data)
+	})
+}
+
+func RemoveFedBan(fedID string, userID int64) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("fed_bans")).Bucket([]byte(fedID))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(strconv.FormatInt(userID, 10)))
+	})
+}
+
+// GetFedBan returns the ban record for userID in the federation, or nil.
+func GetFedBan(fedID string, userID int64) (*FedBan, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var ban *FedBan
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("fed_bans")).Bucket([]byte(fedID))
+		if b == nil {
+			return nil
+		}
+		data := b.Get([]byte(strconv.FormatInt(userID, 10)))
+		if data == nil {
+			return nil
+		}
+		ban = &FedBan{}
+		return json.Unmarshal(data, ban)
+	})
+	return ban, err
+}
+
+func GetFedBans(fedID string) ([]*FedBan, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var bans []*FedBan
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("fed_bans")).Bucket([]byte(fedID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var ban FedBan
+			if err := json.Unmarshal(v, &ban); err == nil {
+				bans = append(bans, &ban)
+			}
+			return nil
+		})
+	})
+	return bans, err
+}
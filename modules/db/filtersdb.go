@@ -3,36 +3,77 @@ package db
 import (
 	"encoding/json"
 	"strconv"
+	"strings"
 
 	bolt "go.etcd.io/bbolt"
 )
 
+const (
+	FilterModeWord   = "word"
+	FilterModeRegex  = "regex"
+	FilterModeExact  = "exact"
+	FilterModePrefix = "prefix"
+
+	// FilterReplySeparator splits Content into alternative replies.
+	FilterReplySeparator = "%%%"
+)
+
 type Filter struct {
-	Keyword   string `json:"keyword"`
-	Content   string `json:"content"`
-	MediaType string `json:"media_type,omitempty"`
-	FileID    string `json:"file_id,omitempty"`
-	AddedBy   int64  `json:"added_by"`
-	Buttons   string `json:"buttons,omitempty"`
+	// Keyword is the trigger as the admin typed it and the key it is stored
+	// under; Triggers holds what is actually matched.
+	Keyword   string   `json:"keyword"`
+	Triggers  []string `json:"triggers,omitempty"`
+	Mode      string   `json:"mode,omitempty"`
+	Content   string   `json:"content"`
+	Replies   []string `json:"replies,omitempty"`
+	MediaType string   `json:"media_type,omitempty"`
+	FileID    string   `json:"file_id,omitempty"`
+	AddedBy   int64    `json:"added_by"`
+	Buttons   string   `json:"buttons,omitempty"`
 }
 
-func ensureFiltersBuckets(db *bolt.DB) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("filters"))
-		return err
-	})
+// SplitFilterReplies splits content on FilterReplySeparator, dropping empty
+// alternatives.
+func SplitFilterReplies(content string) []string {
+	var replies []string
+	for reply := range strings.SplitSeq(content, FilterReplySeparator) {
+		if reply = strings.TrimSpace(reply); reply != "" {
+			replies = append(replies, reply)
+		}
+	}
+	return replies
 }
 
-func SaveFilter(chatID int64, filter *Filter) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
+// migrateFilter fills in fields added after a filter was saved. Filters
+// from before trigger modes existed matched Keyword as a whole word.
+func migrateFilter(filter *Filter) {
+	if filter.Mode == "" {
+		filter.Mode = FilterModeWord
+	}
+	if len(filter.Triggers) == 0 {
+		filter.Triggers = []string{filter.Keyword}
+	}
+	if len(filter.Replies) == 0 && filter.Content != "" {
+		filter.Replies = SplitFilterReplies(filter.Content)
 	}
-	if err := ensureFiltersBuckets(db); err != nil {
-		return err
+}
+
+var filterHooks []func(chatID int64)
+
+// OnFilterChange registers fn to run after a chat's filters are saved or
+// deleted.
+func OnFilterChange(fn func(chatID int64)) {
+	filterHooks = append(filterHooks, fn)
+}
+
+func notifyFilterChange(chatID int64) {
+	for _, fn := range filterHooks {
+		fn(chatID)
 	}
+}
 
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) SaveFilter(chatID int64, filter *Filter) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		filtersBucket := tx.Bucket([]byte("filters"))
 		chatBucket, err := filtersBucket.CreateBucketIfNotExists([]byte(strconv.FormatInt(chatID, 10)))
 		if err != nil {
@@ -48,17 +89,9 @@ func SaveFilter(chatID int64, filter *Filter) error {
 	})
 }
 
-func GetFilter(chatID int64, keyword string) (*Filter, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureFiltersBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetFilter(chatID int64, keyword string) (*Filter, error) {
 	var filter *Filter
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		filtersBucket := tx.Bucket([]byte("filters"))
 		if filtersBucket == nil {
 			return nil
@@ -74,23 +107,18 @@ func GetFilter(chatID int64, keyword string) (*Filter, error) {
 		}
 
 		filter = &Filter{}
-		return json.Unmarshal(data, filter)
+		if err := json.Unmarshal(data, filter); err != nil {
+			return err
+		}
+		return nil
 	})
 
 	return filter, err
 }
 
-func GetAllFilters(chatID int64) ([]*Filter, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureFiltersBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetAllFilters(chatID int64) ([]*Filter, error) {
 	var filters []*Filter
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		filtersBucket := tx.Bucket([]byte("filters"))
 		if filtersBucket == nil {
 			return nil
@@ -114,16 +142,8 @@ func GetAllFilters(chatID int64) ([]*Filter, error) {
 	return filters, err
 }
 
-func DeleteFilter(chatID int64, keyword string) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureFiltersBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) DeleteFilter(chatID int64, keyword string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		filtersBucket := tx.Bucket([]byte("filters"))
 		if filtersBucket == nil {
 			return nil
@@ -137,16 +157,8 @@ func DeleteFilter(chatID int64, keyword string) error {
 	})
 }
 
-func DeleteAllFilters(chatID int64) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureFiltersBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) DeleteAllFilters(chatID int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		filtersBucket := tx.Bucket([]byte("filters"))
 		if filtersBucket == nil {
 			return nil
@@ -155,17 +167,9 @@ func DeleteAllFilters(chatID int64) error {
 	})
 }
 
-func GetFiltersCount(chatID int64) (int, error) {
-	db, err := GetDB()
-	if err != nil {
-		return 0, err
-	}
-	if err := ensureFiltersBuckets(db); err != nil {
-		return 0, err
-	}
-
+func (s *boltStore) GetFiltersCount(chatID int64) (int, error) {
 	count := 0
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		filtersBucket := tx.Bucket([]byte("filters"))
 		if filtersBucket == nil {
 			return nil
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	FloodActionBan   = "ban"
+	FloodActionMute  = "mute"
+	FloodActionKick  = "kick"
+	FloodActionTBan  = "tban"
+	FloodActionTMute = "tmute"
+
+	DefaultFloodWindowSec = 10
+)
+
+type FloodSettings struct {
+	// Limit is the number of messages allowed inside the window; 0 disables
+	// flood control.
+	Limit     int    `json:"limit"`
+	WindowSec int    `json:"window_sec"`
+	Action    string `json:"action"`
+	Duration  string `json:"duration,omitempty"`
+}
+
+func SetFloodSettings(chatID int64, settings *FloodSettings) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("flood_settings")).Put([]byte(strconv.FormatInt(chatID, 10)), data)
+	})
+}
+
+func GetFloodSettings(chatID int64) (*FloodSettings, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &FloodSettings{
+		WindowSec: DefaultFloodWindowSec,
+		Action:    FloodActionMute,
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("flood_settings")).Get([]byte(strconv.FormatInt(chatID, 10)))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, settings)
+	})
+	return settings, err
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	LockActionDelete = "delete"
+	LockActionWarn   = "warn"
+	LockActionMute   = "mute"
+	LockActionKick   = "kick"
+	LockActionBan    = "ban"
+	LockActionTMute  = "tmute"
+	LockActionTBan   = "tban"
+)
+
+// LockSetting is what happens to a message that breaks a bot-enforced lock.
+// The message is always deleted; Action is applied to the sender on top.
+type LockSetting struct {
+	Action   string `json:"action"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// ChatLocks holds the bot-enforced locks of a chat, keyed by lock name. Native
+// locks live in the chat's default banned rights instead.
+type ChatLocks struct {
+	Locks map[string]*LockSetting `json:"locks,omitempty"`
+}
+
+func SetChatLocks(chatID int64, locks *ChatLocks) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		key := []byte(strconv.FormatInt(chatID, 10))
+		if len(locks.Locks) == 0 {
+			return tx.Bucket([]byte("chat_locks")).Delete(key)
+		}
+		data, err := json.Marshal(locks)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("chat_locks")).Put(key, data)
+	})
+}
+
+func GetChatLocks(chatID int64) (*ChatLocks, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	locks := &ChatLocks{}
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("chat_locks")).Get([]byte(strconv.FormatInt(chatID, 10)))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, locks)
+	})
+	if locks.Locks == nil {
+		locks.Locks = make(map[string]*LockSetting)
+	}
+	return locks, err
+}
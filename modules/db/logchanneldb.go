@@ -0,0 +1,109 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrNoLogChannel = errors.New("no log channel set")
+
+type LogChannel struct {
+	ChannelID int64 `json:"channel_id"`
+	SetBy     int64 `json:"set_by"`
+	// Disabled holds the categories that should not be posted; anything not
+	// listed is logged.
+	Disabled map[string]bool `json:"disabled,omitempty"`
+}
+
+func (l *LogChannel) Enabled(category string) bool {
+	return !l.Disabled[category]
+}
+
+func SetLogChannel(chatID int64, logChannel *LogChannel) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(logChannel)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("log_channels")).Put([]byte(strconv.FormatInt(chatID, 10)), data)
+	})
+}
+
+// GetLogChannel returns the chat's log channel binding, or nil if none is set.
+func GetLogChannel(chatID int64) (*LogChannel, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var logChannel *LogChannel
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("log_channels")).Get([]byte(strconv.FormatInt(chatID, 10)))
+		if data == nil {
+			return nil
+		}
+		logChannel = &LogChannel{}
+		return json.Unmarshal(data, logChannel)
+	})
+	return logChannel, err
+}
+
+func DeleteLogChannel(chatID int64) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("log_channels")).Delete([]byte(strconv.FormatInt(chatID, 10)))
+	})
+}
+
+// ToggleLogCategory flips a category for the chat's log channel and returns
+// whether it is now enabled.
+func ToggleLogCategory(chatID int64, category string) (bool, error) {
+	db, err := GetDB()
+	if err != nil {
+		return false, err
+	}
+
+	enabled := false
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("log_channels"))
+		key := []byte(strconv.FormatInt(chatID, 10))
+		data := b.Get(key)
+		if data == nil {
+			return ErrNoLogChannel
+		}
+
+		logChannel := &LogChannel{}
+		if err := json.Unmarshal(data, logChannel); err != nil {
+			return err
+		}
+		if logChannel.Disabled == nil {
+			logChannel.Disabled = make(map[string]bool)
+		}
+
+		if logChannel.Disabled[category] {
+			delete(logChannel.Disabled, category)
+			enabled = true
+		} else {
+			logChannel.Disabled[category] = true
+		}
+
+		data, err := json.Marshal(logChannel)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+	return enabled, err
+}
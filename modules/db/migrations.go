@@ -0,0 +1,272 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets:
+//
+//	meta  "schema_version"  -> version of the last migration applied
+const schemaVersionKey = "schema_version"
+
+// Migration rewrites stored records from the previous schema version to
+// Version. Apply runs inside the migration transaction and returns how many
+// records it changed.
+type Migration struct {
+	Version int
+	Name    string
+	Apply   func(tx *bolt.Tx) (int, error)
+}
+
+type MigrationResult struct {
+	Version int
+	Name    string
+	Changed int
+}
+
+// migrations must stay in Version order; append new ones at the end and never
+// renumber or edit one that has shipped.
+var migrations = []Migration{
+	{1, "fill filter trigger, mode and reply fields", migrateFilterFields},
+	{2, "wrap plain-text rules in JSON", migrateRulesJSON},
+	{3, "enable greetings saved before the on/off toggle", migrateGreetingEnabled},
+	{4, "set missing sticker pack types", migrateStickerPackTypes},
+}
+
+var errDryRun = errors.New("dry run")
+
+// LatestSchemaVersion is the version the database is at once every migration
+// has been applied.
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func getSchemaVersion(tx *bolt.Tx) int {
+	b := tx.Bucket([]byte("meta"))
+	if b == nil {
+		return 0
+	}
+	v, _ := strconv.Atoi(string(b.Get([]byte(schemaVersionKey))))
+	return v
+}
+
+// SchemaVersion returns the version stored in the meta bucket, 0 if unset.
+func SchemaVersion() (int, error) {
+	db, err := GetDB()
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+	err = db.View(func(tx *bolt.Tx) error {
+		version = getSchemaVersion(tx)
+		return nil
+	})
+	return version, err
+}
+
+// RunMigrations applies every pending migration in one transaction, bumping
+// the stored schema version as it goes. If any migration fails nothing is
+// written. With dryRun set the transaction is always rolled back, so the
+// results only report what would change.
+func RunMigrations(dryRun bool) ([]MigrationResult, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	return runMigrations(db, migrations, dryRun)
+}
+
+func runMigrations(db *DB, list []Migration, dryRun bool) ([]MigrationResult, error) {
+	var results []MigrationResult
+	err := db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists([]byte("meta"))
+		if err != nil {
+			return err
+		}
+
+		current := getSchemaVersion(tx)
+		if len(list) > 0 && current > list[len(list)-1].Version {
+			return fmt.Errorf("database schema v%d is newer than this build (v%d)", current, list[len(list)-1].Version)
+		}
+
+		for _, m := range list {
+			if m.Version <= current {
+				continue
+			}
+			changed, err := m.Apply(tx)
+			if err != nil {
+				return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+			}
+			if err := meta.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(m.Version))); err != nil {
+				return err
+			}
+			results = append(results, MigrationResult{Version: m.Version, Name: m.Name, Changed: changed})
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// forEachChatRecord calls fn for every value in bucket/<chat>/<key>. When fn
+// returns non-nil data the record is replaced with it.
+func forEachChatRecord(tx *bolt.Tx, bucket string, fn func(key, value []byte) ([]byte, error)) (int, error) {
+	b := tx.Bucket([]byte(bucket))
+	if b == nil {
+		return 0, nil
+	}
+
+	changed := 0
+	err := b.ForEachBucket(func(chatKey []byte) error {
+		cb := b.Bucket(chatKey)
+		type update struct{ k, v []byte }
+		var updates []update
+		err := cb.ForEach(func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			data, err := fn(k, v)
+			if err != nil || data == nil {
+				return err
+			}
+			updates = append(updates, update{append([]byte(nil), k...), data})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Writing while iterating invalidates the cursor, so apply afterwards.
+		for _, u := range updates {
+			if err := cb.Put(u.k, u.v); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	return changed, err
+}
+
+func migrateFilterFields(tx *bolt.Tx) (int, error) {
+	return forEachChatRecord(tx, "filters", func(_, v []byte) ([]byte, error) {
+		var filter Filter
+		if json.Unmarshal(v, &filter) != nil {
+			return nil, nil
+		}
+		if filter.Mode != "" && len(filter.Triggers) > 0 && (len(filter.Replies) > 0 || filter.Content == "") {
+			return nil, nil
+		}
+		migrateFilter(&filter)
+		return json.Marshal(&filter)
+	})
+}
+
+func migrateRulesJSON(tx *bolt.Tx) (int, error) {
+	b := tx.Bucket([]byte("rules"))
+	if b == nil {
+		return 0, nil
+	}
+
+	updates := make(map[string][]byte)
+	err := b.ForEach(func(k, v []byte) error {
+		if json.Valid(v) {
+			return nil
+		}
+		data, err := json.Marshal(&Rules{Content: string(v)})
+		if err != nil {
+			return err
+		}
+		updates[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range updates {
+		if err := b.Put([]byte(k), v); err != nil {
+			return 0, err
+		}
+	}
+	return len(updates), nil
+}
+
+// Greetings saved before WelcomeMessage.Enabled existed were always sent, but
+// now decode as disabled.
+func migrateGreetingEnabled(tx *bolt.Tx) (int, error) {
+	return forEachChatRecord(tx, "welcome", func(k, v []byte) ([]byte, error) {
+		if key := string(k); key != "msg" && key != "bye" {
+			return nil, nil
+		}
+		var raw map[string]json.RawMessage
+		if json.Unmarshal(v, &raw) != nil {
+			return nil, nil
+		}
+		if _, ok := raw["enabled"]; ok {
+			return nil, nil
+		}
+		var msg WelcomeMessage
+		if json.Unmarshal(v, &msg) != nil {
+			return nil, nil
+		}
+		msg.Enabled = true
+		return json.Marshal(&msg)
+	})
+}
+
+func migrateStickerPackTypes(tx *bolt.Tx) (int, error) {
+	users := tx.Bucket([]byte("sticker_users"))
+	if users == nil {
+		return 0, nil
+	}
+
+	changed := 0
+	err := users.ForEachBucket(func(userKey []byte) error {
+		ub := users.Bucket(userKey)
+		return ub.ForEachBucket(func(typeKey []byte) error {
+			tb := ub.Bucket(typeKey)
+			updates := make(map[string][]byte)
+			err := tb.ForEach(func(k, v []byte) error {
+				var pack PackInfo
+				if json.Unmarshal(v, &pack) != nil || pack.Type != "" {
+					return nil
+				}
+				pack.Type = string(typeKey)
+				data, err := json.Marshal(&pack)
+				if err != nil {
+					return err
+				}
+				updates[string(k)] = data
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for k, v := range updates {
+				if err := tb.Put([]byte(k), v); err != nil {
+					return err
+				}
+				changed++
+			}
+			return nil
+		})
+	})
+	return changed, err
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	bdb, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bdb.Close() })
+	return &DB{bolt: bdb}
+}
+
+// seedLegacyRecords writes one record per migration in the shape it had
+// before that migration existed.
+func seedLegacyRecords(t *testing.T, db *DB) {
+	t.Helper()
+	err := db.Update(func(tx *bolt.Tx) error {
+		put := func(path []string, key, value string) error {
+			b, err := tx.CreateBucketIfNotExists([]byte(path[0]))
+			if err != nil {
+				return err
+			}
+			for _, name := range path[1:] {
+				if b, err = b.CreateBucketIfNotExists([]byte(name)); err != nil {
+					return err
+				}
+			}
+			return b.Put([]byte(key), []byte(value))
+		}
+		records := []struct {
+			path       []string
+			key, value string
+		}{
+			{[]string{"filters", "-100"}, "hello", `{"keyword":"hello","content":"hi there","added_by":1}`},
+			{[]string{"filters", "-100"}, "bye", `{"keyword":"bye","triggers":["bye"],"mode":"word","content":"cya","replies":["cya"],"added_by":1}`},
+			{[]string{"rules"}, "-100", "Be nice"},
+			{[]string{"rules"}, "-200", `{"content":"Already JSON"}`},
+			{[]string{"welcome", "-100"}, "msg", `{"content":"Welcome!","delete_previous":false,"auto_delete_sec":0}`},
+			{[]string{"welcome", "-100"}, "bye", `{"content":"Bye!","enabled":false}`},
+			{[]string{"sticker_users", "42", "static"}, "1", `{"short_name":"a_by_bot","title":"A","sticker_count":3,"pack_number":1}`},
+			{[]string{"sticker_users", "42", "video"}, "1", `{"short_name":"b_by_bot","title":"B","type":"video","sticker_count":1,"pack_number":1}`},
+		}
+		for _, r := range records {
+			if err := put(r.path, r.key, r.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dump returns every value in db keyed by its slash-joined bucket path.
+func dump(t *testing.T, db *DB) map[string]string {
+	t.Helper()
+	out := make(map[string]string)
+	var walk func(prefix string, b *bolt.Bucket) error
+	walk = func(prefix string, b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			path := prefix + "/" + string(k)
+			if v == nil {
+				return walk(path, b.Bucket(k))
+			}
+			out[path] = string(v)
+			return nil
+		})
+	}
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return walk(string(name), b)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func get(t *testing.T, db *DB, path ...string) []byte {
+	t.Helper()
+	var data []byte
+	db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(path[0]))
+		for _, name := range path[1 : len(path)-1] {
+			if b == nil {
+				return nil
+			}
+			b = b.Bucket([]byte(name))
+		}
+		if b != nil {
+			data = append([]byte(nil), b.Get([]byte(path[len(path)-1]))...)
+		}
+		return nil
+	})
+	if data == nil {
+		t.Fatalf("%s: not found", strings.Join(path, "/"))
+	}
+	return data
+}
+
+func TestRunMigrations(t *testing.T) {
+	db := openTestDB(t)
+	seedLegacyRecords(t, db)
+
+	results, err := runMigrations(db, migrations, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := make(map[int]int)
+	for _, r := range results {
+		changed[r.Version] = r.Changed
+	}
+	if want := map[int]int{1: 1, 2: 1, 3: 1, 4: 1}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+
+	var filter Filter
+	if err := json.Unmarshal(get(t, db, "filters", "-100", "hello"), &filter); err != nil {
+		t.Fatal(err)
+	}
+	if filter.Mode != FilterModeWord || !reflect.DeepEqual(filter.Triggers, []string{"hello"}) || !reflect.DeepEqual(filter.Replies, []string{"hi there"}) {
+		t.Errorf("filter = %+v", filter)
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(get(t, db, "rules", "-100"), &rules); err != nil {
+		t.Fatal(err)
+	}
+	if rules.Content != "Be nice" {
+		t.Errorf("rules content = %q, want %q", rules.Content, "Be nice")
+	}
+
+	var welcome, bye WelcomeMessage
+	if err := json.Unmarshal(get(t, db, "welcome", "-100", "msg"), &welcome); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(get(t, db, "welcome", "-100", "bye"), &bye); err != nil {
+		t.Fatal(err)
+	}
+	if !welcome.Enabled || welcome.Content != "Welcome!" {
+		t.Errorf("welcome = %+v, want enabled", welcome)
+	}
+	if bye.Enabled {
+		t.Error("goodbye that was explicitly disabled got enabled")
+	}
+
+	var pack PackInfo
+	if err := json.Unmarshal(get(t, db, "sticker_users", "42", "static", "1"), &pack); err != nil {
+		t.Fatal(err)
+	}
+	if pack.Type != "static" || pack.ShortName != "a_by_bot" {
+		t.Errorf("pack = %+v, want type static", pack)
+	}
+
+	if v := string(get(t, db, "meta", schemaVersionKey)); v != "4" {
+		t.Errorf("schema version = %s, want 4", v)
+	}
+
+	// A second run has nothing left to do.
+	before := dump(t, db)
+	results, err = runMigrations(db, migrations, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("second run applied %v", results)
+	}
+	if after := dump(t, db); !reflect.DeepEqual(before, after) {
+		t.Error("second run changed the database")
+	}
+}
+
+func TestRunMigrationsDryRun(t *testing.T) {
+	db := openTestDB(t)
+	seedLegacyRecords(t, db)
+	before := dump(t, db)
+
+	results, err := runMigrations(db, migrations, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(migrations) {
+		t.Errorf("got %d results, want %d", len(results), len(migrations))
+	}
+	for _, r := range results {
+		if r.Changed != 1 {
+			t.Errorf("migration %d reports %d changes, want 1", r.Version, r.Changed)
+		}
+	}
+
+	if after := dump(t, db); !reflect.DeepEqual(before, after) {
+		t.Errorf("dry run changed the database:\nbefore %v\nafter  %v", before, after)
+	}
+}
+
+func TestRunMigrationsNewerSchema(t *testing.T) {
+	db := openTestDB(t)
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("meta"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(schemaVersionKey), []byte("99"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runMigrations(db, migrations, false); err == nil {
+		t.Fatal("expected an error for a schema newer than the build")
+	}
+}
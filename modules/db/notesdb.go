@@ -19,24 +19,8 @@ type Note struct {
 	Buttons   string    `json:"buttons,omitempty"`
 }
 
-func ensureNotesBuckets(db *bolt.DB) error {
-	return db.Update(func(tx *bolt.Tx) error {
-
-		_, err := tx.CreateBucketIfNotExists([]byte("notes"))
-		return err
-	})
-}
-
-func SaveNote(chatID int64, note *Note) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureNotesBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) SaveNote(chatID int64, note *Note) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		notesBucket := tx.Bucket([]byte("notes"))
 		chatBucket, err := notesBucket.CreateBucketIfNotExists([]byte(strconv.FormatInt(chatID, 10)))
 		if err != nil {
@@ -52,17 +36,9 @@ func SaveNote(chatID int64, note *Note) error {
 	})
 }
 
-func GetNote(chatID int64, name string) (*Note, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureNotesBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetNote(chatID int64, name string) (*Note, error) {
 	var note *Note
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		notesBucket := tx.Bucket([]byte("notes"))
 		if notesBucket == nil {
 			return nil
@@ -95,17 +71,9 @@ func GetNote(chatID int64, name string) (*Note, error) {
 	return note, err
 }
 
-func GetAllNotes(chatID int64) ([]*Note, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureNotesBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetAllNotes(chatID int64) ([]*Note, error) {
 	var notes []*Note
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		notesBucket := tx.Bucket([]byte("notes"))
 		if notesBucket == nil {
 			return nil
@@ -129,16 +97,8 @@ func GetAllNotes(chatID int64) ([]*Note, error) {
 	return notes, err
 }
 
-func DeleteNote(chatID int64, name string) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureNotesBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) DeleteNote(chatID int64, name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		notesBucket := tx.Bucket([]byte("notes"))
 		if notesBucket == nil {
 			return nil
@@ -152,16 +112,8 @@ func DeleteNote(chatID int64, name string) error {
 	})
 }
 
-func DeleteAllNotes(chatID int64) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureNotesBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) DeleteAllNotes(chatID int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		notesBucket := tx.Bucket([]byte("notes"))
 		if notesBucket == nil {
 			return nil
@@ -170,17 +122,9 @@ func DeleteAllNotes(chatID int64) error {
 	})
 }
 
-func GetNotesCount(chatID int64) (int, error) {
-	db, err := GetDB()
-	if err != nil {
-		return 0, err
-	}
-	if err := ensureNotesBuckets(db); err != nil {
-		return 0, err
-	}
-
+func (s *boltStore) GetNotesCount(chatID int64) (int, error) {
 	count := 0
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		notesBucket := tx.Bucket([]byte("notes"))
 		if notesBucket == nil {
 			return nil
@@ -200,6 +144,55 @@ func GetNotesCount(chatID int64) (int, error) {
 	return count, err
 }
 
+// ExpiredNote is a temporary note removed by DeleteExpiredNotes.
+type ExpiredNote struct {
+	ChatID int64
+	Name   string
+}
+
+// DeleteExpiredNotes removes up to limit notes whose ExpiresAt has passed.
+func (s *boltStore) DeleteExpiredNotes(now time.Time, limit int) ([]*ExpiredNote, error) {
+	var expired []*ExpiredNote
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		notesBucket := tx.Bucket([]byte("notes"))
+
+		chats := notesBucket.Cursor()
+		for chatKey, v := chats.First(); chatKey != nil && len(expired) < limit; chatKey, v = chats.Next() {
+			if v != nil {
+				continue
+			}
+			chatID, err := strconv.ParseInt(string(chatKey), 10, 64)
+			if err != nil {
+				continue
+			}
+
+			chatBucket := notesBucket.Bucket(chatKey)
+			var keys [][]byte
+			c := chatBucket.Cursor()
+			for k, v := c.First(); k != nil && len(expired) < limit; k, v = c.Next() {
+				var note Note
+				if err := json.Unmarshal(v, &note); err != nil {
+					continue
+				}
+				if !note.ExpiresAt.IsZero() && now.After(note.ExpiresAt) {
+					keys = append(keys, k)
+					expired = append(expired, &ExpiredNote{ChatID: chatID, Name: note.Name})
+				}
+			}
+			for _, k := range keys {
+				if err := chatBucket.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
 func CloseNotesDB() error {
 	return nil
 }
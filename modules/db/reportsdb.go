@@ -0,0 +1,197 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	ReportOpen      = "open"
+	ReportDeleted   = "deleted"
+	ReportWarned    = "warned"
+	ReportMuted     = "muted"
+	ReportBanned    = "banned"
+	ReportDismissed = "dismissed"
+)
+
+// ReportNotice is a message the bot sent about a report, edited once the
+// report is resolved.
+type ReportNotice struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int32 `json:"message_id"`
+}
+
+type Report struct {
+	ID           uint64         `json:"id"`
+	ChatID       int64          `json:"chat_id"`
+	ChatTitle    string         `json:"chat_title,omitempty"`
+	MessageID    int32          `json:"message_id"`
+	TargetID     int64          `json:"target_id"`
+	TargetName   string         `json:"target_name,omitempty"`
+	ReporterID   int64          `json:"reporter_id"`
+	ReporterName string         `json:"reporter_name,omitempty"`
+	Reason       string         `json:"reason,omitempty"`
+	Status       string         `json:"status"`
+	ResolvedBy   int64          `json:"resolved_by,omitempty"`
+	ResolvedAt   time.Time      `json:"resolved_at,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	Notices      []ReportNotice `json:"notices,omitempty"`
+}
+
+type ReportSettings struct {
+	// Disabled turns off /report and @admins; reports are on by default.
+	Disabled bool `json:"disabled"`
+}
+
+// Buckets:
+//
+//	reports          chat:id  -> Report
+//	report_settings  chatID   -> ReportSettings
+func reportKey(chatID int64, id uint64) []byte {
+	return []byte(fmt.Sprintf("%d:%d", chatID, id))
+}
+
+// SaveReport stores r, assigning it an ID first if it has none.
+func SaveReport(r *Report) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("reports"))
+		if r.ID == 0 {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			r.ID = id
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return b.Put(reportKey(r.ChatID, r.ID), data)
+	})
+}
+
+// GetReport returns nil if there is no such report.
+func GetReport(chatID int64, id uint64) (*Report, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var r *Report
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("reports")).Get(reportKey(chatID, id))
+		if data == nil {
+			return nil
+		}
+		r = &Report{}
+		return json.Unmarshal(data, r)
+	})
+	return r, err
+}
+
+// AddReportNotices records messages sent about a report without touching its
+// status, which an admin may already have changed.
+func AddReportNotices(chatID int64, id uint64, notices []ReportNotice) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("reports"))
+		data := b.Get(reportKey(chatID, id))
+		if data == nil {
+			return nil
+		}
+		var r Report
+		if err := json.Unmarshal(data, &r); err != nil {
+			return err
+		}
+		r.Notices = append(r.Notices, notices...)
+		data, err := json.Marshal(&r)
+		if err != nil {
+			return err
+		}
+		return b.Put(reportKey(chatID, id), data)
+	})
+}
+
+// ResolveReport marks an open report as status. It returns the stored report
+// and false, without changing it, when someone else resolved it first.
+// Passing ReportOpen reopens a report whose action failed.
+func ResolveReport(chatID int64, id uint64, status string, by int64) (*Report, bool, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var r *Report
+	var resolved bool
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("reports"))
+		data := b.Get(reportKey(chatID, id))
+		if data == nil {
+			return nil
+		}
+		r = &Report{}
+		if err := json.Unmarshal(data, r); err != nil {
+			return err
+		}
+		if (r.Status == ReportOpen) == (status == ReportOpen) {
+			return nil
+		}
+
+		r.Status, r.ResolvedBy, r.ResolvedAt = status, by, time.Now()
+		if status == ReportOpen {
+			r.ResolvedBy, r.ResolvedAt = 0, time.Time{}
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		resolved = true
+		return b.Put(reportKey(chatID, id), data)
+	})
+	return r, resolved, err
+}
+
+func SetReportSettings(chatID int64, settings *ReportSettings) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("report_settings")).Put([]byte(strconv.FormatInt(chatID, 10)), data)
+	})
+}
+
+func GetReportSettings(chatID int64) (*ReportSettings, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &ReportSettings{}
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("report_settings")).Get([]byte(strconv.FormatInt(chatID, 10)))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, settings)
+	})
+	return settings, err
+}
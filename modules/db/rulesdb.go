@@ -14,23 +14,8 @@ type Rules struct {
 	Buttons   string `json:"buttons,omitempty"`
 }
 
-func ensureRulesBuckets(db *bolt.DB) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("rules"))
-		return err
-	})
-}
-
-func SetRulesWithMedia(chatID int64, rules *Rules) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureRulesBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) SetRulesWithMedia(chatID int64, rules *Rules) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte("rules"))
 		data, err := json.Marshal(rules)
 		if err != nil {
@@ -44,17 +29,9 @@ func SetRules(chatID int64, rulesText string) error {
 	return SetRulesWithMedia(chatID, &Rules{Content: rulesText})
 }
 
-func GetRulesWithMedia(chatID int64) (*Rules, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureRulesBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetRulesWithMedia(chatID int64) (*Rules, error) {
 	var rules *Rules
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte("rules"))
 		data := bucket.Get([]byte(strconv.FormatInt(chatID, 10)))
 		if data == nil {
@@ -82,16 +59,8 @@ func GetRules(chatID int64) (string, error) {
 	return rules.Content, nil
 }
 
-func DeleteRules(chatID int64) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureRulesBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) DeleteRules(chatID int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte("rules"))
 		return bucket.Delete([]byte(strconv.FormatInt(chatID, 10)))
 	})
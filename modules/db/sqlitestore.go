@@ -0,0 +1,596 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Records are kept as JSON in a data column, keyed the same way as the bolt
+// buckets, so both backends round-trip the same structs. Warns get real
+// columns since they are the data most worth querying.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS notes (
+	chat_id INTEGER NOT NULL,
+	name    TEXT    NOT NULL,
+	data    TEXT    NOT NULL,
+	PRIMARY KEY (chat_id, name)
+);
+CREATE TABLE IF NOT EXISTS filters (
+	chat_id INTEGER NOT NULL,
+	keyword TEXT    NOT NULL,
+	data    TEXT    NOT NULL,
+	PRIMARY KEY (chat_id, keyword)
+);
+CREATE TABLE IF NOT EXISTS warns (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	chat_id    INTEGER NOT NULL,
+	user_id    INTEGER NOT NULL,
+	reason     TEXT    NOT NULL DEFAULT '',
+	admin_id   INTEGER NOT NULL DEFAULT 0,
+	warned_at  TEXT    NOT NULL,
+	expires_at TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS warns_chat_user ON warns (chat_id, user_id);
+CREATE TABLE IF NOT EXISTS warn_settings (
+	chat_id INTEGER PRIMARY KEY,
+	data    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS welcome (
+	chat_id     INTEGER PRIMARY KEY,
+	welcome     TEXT,
+	goodbye     TEXT,
+	last_msg_id INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS rules (
+	chat_id INTEGER PRIMARY KEY,
+	data    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS blacklist (
+	chat_id INTEGER NOT NULL,
+	word    TEXT    NOT NULL,
+	data    TEXT    NOT NULL,
+	PRIMARY KEY (chat_id, word)
+);
+CREATE TABLE IF NOT EXISTS blacklist_settings (
+	chat_id INTEGER PRIMARY KEY,
+	data    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS sticker_packs (
+	user_id     INTEGER NOT NULL,
+	type        TEXT    NOT NULL,
+	pack_number INTEGER NOT NULL,
+	short_name  TEXT    NOT NULL,
+	data        TEXT    NOT NULL,
+	PRIMARY KEY (user_id, type, pack_number)
+);
+CREATE TABLE IF NOT EXISTS sticker_active (
+	user_id     INTEGER NOT NULL,
+	type        TEXT    NOT NULL,
+	pack_number INTEGER NOT NULL,
+	PRIMARY KEY (user_id, type)
+);
+CREATE TABLE IF NOT EXISTS sticker_next (
+	user_id     INTEGER NOT NULL,
+	type        TEXT    NOT NULL,
+	pack_number INTEGER NOT NULL,
+	PRIMARY KEY (user_id, type)
+);
+`
+
+// sqliteStore keeps the Store data in SQLite. It is meant for a single bot
+// process: the per-chat caches in modules (filters, blacklist, flood, locks,
+// disabled commands, admins) are never told about writes made by another
+// process sharing the file. /dbstats, /backup, /restoredb and the schema
+// migrations only cover database.db and refuse to run, or are skipped, here.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens (creating if needed) the SQLite database at path.
+func OpenSQLiteStore(path string) (Store, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite schema: %w", err)
+	}
+	// Columns added after a table first shipped; CREATE TABLE IF NOT EXISTS
+	// leaves existing tables alone.
+	if err := sqliteAddColumn(db, "warns", "expires_at", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite schema: %w", err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func sqliteAddColumn(db *sql.DB, table, column, def string) error {
+	rows, err := db.Query(fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, def))
+	return err
+}
+
+func formatSQLiteTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseSQLiteTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteStore) putJSON(query string, v any, args ...any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(query, append(args, string(data))...)
+	return err
+}
+
+// getJSON scans a single data column into v and reports whether a row was found.
+func (s *sqliteStore) getJSON(query string, v any, args ...any) (bool, error) {
+	var data string
+	err := s.db.QueryRow(query, args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(data), v)
+}
+
+// listJSON decodes every data column returned by query, skipping rows that
+// fail to decode like the bolt cursors do.
+func listJSON[T any](s *sqliteStore, query string, args ...any) ([]*T, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*T
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return items, err
+		}
+		var item T
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			continue
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+func (s *sqliteStore) count(query string, args ...any) (int, error) {
+	var n int
+	err := s.db.QueryRow(query, args...).Scan(&n)
+	return n, err
+}
+
+func (s *sqliteStore) SaveNote(chatID int64, note *Note) error {
+	return s.putJSON(`INSERT OR REPLACE INTO notes (chat_id, name, data) VALUES (?, ?, ?)`, note, chatID, note.Name)
+}
+
+func (s *sqliteStore) GetNote(chatID int64, name string) (*Note, error) {
+	note := &Note{}
+	found, err := s.getJSON(`SELECT data FROM notes WHERE chat_id = ? AND name = ?`, note, chatID, name)
+	if err != nil || !found {
+		return nil, err
+	}
+	if !note.ExpiresAt.IsZero() && time.Now().After(note.ExpiresAt) {
+		s.DeleteNote(chatID, name)
+		return nil, nil
+	}
+	return note, nil
+}
+
+func (s *sqliteStore) GetAllNotes(chatID int64) ([]*Note, error) {
+	return listJSON[Note](s, `SELECT data FROM notes WHERE chat_id = ? ORDER BY name`, chatID)
+}
+
+func (s *sqliteStore) DeleteNote(chatID int64, name string) error {
+	_, err := s.db.Exec(`DELETE FROM notes WHERE chat_id = ? AND name = ?`, chatID, name)
+	return err
+}
+
+func (s *sqliteStore) DeleteAllNotes(chatID int64) error {
+	_, err := s.db.Exec(`DELETE FROM notes WHERE chat_id = ?`, chatID)
+	return err
+}
+
+func (s *sqliteStore) GetNotesCount(chatID int64) (int, error) {
+	return s.count(`SELECT COUNT(*) FROM notes WHERE chat_id = ?`, chatID)
+}
+
+func (s *sqliteStore) DeleteExpiredNotes(now time.Time, limit int) ([]*ExpiredNote, error) {
+	rows, err := s.db.Query(`SELECT rowid, chat_id, data FROM notes
+		WHERE json_extract(data, '$.expires_at') NOT LIKE '0001-01-01%'`)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	var expired []*ExpiredNote
+	for len(expired) < limit && rows.Next() {
+		var id, chatID int64
+		var data string
+		if err := rows.Scan(&id, &chatID, &data); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var note Note
+		if json.Unmarshal([]byte(data), &note) != nil || note.ExpiresAt.IsZero() || !now.After(note.ExpiresAt) {
+			continue
+		}
+		ids = append(ids, id)
+		expired = append(expired, &ExpiredNote{ChatID: chatID, Name: note.Name})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return expired, s.deleteIDs("notes", "rowid", ids)
+}
+
+func (s *sqliteStore) SaveFilter(chatID int64, filter *Filter) error {
+	return s.putJSON(`INSERT OR REPLACE INTO filters (chat_id, keyword, data) VALUES (?, ?, ?)`, filter, chatID, filter.Keyword)
+}
+
+func (s *sqliteStore) GetFilter(chatID int64, keyword string) (*Filter, error) {
+	filter := &Filter{}
+	found, err := s.getJSON(`SELECT data FROM filters WHERE chat_id = ? AND keyword = ?`, filter, chatID, keyword)
+	if err != nil || !found {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func (s *sqliteStore) GetAllFilters(chatID int64) ([]*Filter, error) {
+	return listJSON[Filter](s, `SELECT data FROM filters WHERE chat_id = ? ORDER BY keyword`, chatID)
+}
+
+func (s *sqliteStore) DeleteFilter(chatID int64, keyword string) error {
+	_, err := s.db.Exec(`DELETE FROM filters WHERE chat_id = ? AND keyword = ?`, chatID, keyword)
+	return err
+}
+
+func (s *sqliteStore) DeleteAllFilters(chatID int64) error {
+	_, err := s.db.Exec(`DELETE FROM filters WHERE chat_id = ?`, chatID)
+	return err
+}
+
+func (s *sqliteStore) GetFiltersCount(chatID int64) (int, error) {
+	return s.count(`SELECT COUNT(*) FROM filters WHERE chat_id = ?`, chatID)
+}
+
+func (s *sqliteStore) AddWarn(chatID, userID int64, warn *Warn) (int, error) {
+	_, err := s.db.Exec(`INSERT INTO warns (chat_id, user_id, reason, admin_id, warned_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		chatID, userID, warn.Reason, warn.AdminID, formatSQLiteTime(warn.Timestamp), formatSQLiteTime(warn.ExpiresAt))
+	if err != nil {
+		return 0, err
+	}
+	return s.count(`SELECT COUNT(*) FROM warns WHERE chat_id = ? AND user_id = ?`, chatID, userID)
+}
+
+func (s *sqliteStore) GetWarns(chatID, userID int64) ([]*Warn, error) {
+	rows, err := s.db.Query(`SELECT reason, admin_id, warned_at, expires_at FROM warns WHERE chat_id = ? AND user_id = ? ORDER BY id`, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var warns []*Warn
+	for rows.Next() {
+		var w Warn
+		var at, expires string
+		if err := rows.Scan(&w.Reason, &w.AdminID, &at, &expires); err != nil {
+			return warns, err
+		}
+		w.Timestamp = parseSQLiteTime(at)
+		w.ExpiresAt = parseSQLiteTime(expires)
+		warns = append(warns, &w)
+	}
+	return warns, rows.Err()
+}
+
+func (s *sqliteStore) ResetWarns(chatID, userID int64) error {
+	_, err := s.db.Exec(`DELETE FROM warns WHERE chat_id = ? AND user_id = ?`, chatID, userID)
+	return err
+}
+
+func (s *sqliteStore) SetWarnSettings(chatID int64, settings *WarnSettings) error {
+	return s.putJSON(`INSERT OR REPLACE INTO warn_settings (chat_id, data) VALUES (?, ?)`, settings, chatID)
+}
+
+func (s *sqliteStore) GetWarnSettings(chatID int64) (*WarnSettings, error) {
+	settings := &WarnSettings{
+		MaxWarns: 3,
+		Action:   WarnActionBan,
+	}
+	_, err := s.getJSON(`SELECT data FROM warn_settings WHERE chat_id = ?`, settings, chatID)
+	return settings, err
+}
+
+func (s *sqliteStore) DeleteExpiredWarns(now time.Time, limit int) ([]*ExpiredWarn, error) {
+	rows, err := s.db.Query(`SELECT w.id, w.chat_id, w.user_id, w.reason, w.warned_at, w.expires_at,
+			COALESCE(json_extract(ws.data, '$.decay_days'), 0)
+		FROM warns w LEFT JOIN warn_settings ws ON ws.chat_id = w.chat_id
+		WHERE w.expires_at != '' OR COALESCE(json_extract(ws.data, '$.decay_days'), 0) > 0
+		ORDER BY w.id`)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	var expired []*ExpiredWarn
+	for len(expired) < limit && rows.Next() {
+		var id int64
+		var w Warn
+		var ew ExpiredWarn
+		var at, expires string
+		var decayDays int
+		if err := rows.Scan(&id, &ew.ChatID, &ew.UserID, &w.Reason, &at, &expires, &decayDays); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		w.Timestamp = parseSQLiteTime(at)
+		w.ExpiresAt = parseSQLiteTime(expires)
+		if !w.Expired(now, decayDays) {
+			continue
+		}
+		ew.Reason = w.Reason
+		ew.Decayed = w.ExpiresAt.IsZero() || now.Before(w.ExpiresAt)
+		ids = append(ids, id)
+		expired = append(expired, &ew)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return expired, s.deleteIDs("warns", "id", ids)
+}
+
+// deleteIDs removes the rows whose column matches one of ids in a single
+// transaction.
+func (s *sqliteStore) deleteIDs(table, column string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf(`DELETE FROM %s WHERE %s = ?`, table, column))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, id := range ids {
+		if _, err := stmt.Exec(id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// setGreeting stores msg in the welcome or goodbye column of the chat's row.
+func (s *sqliteStore) setGreeting(column string, chatID int64, msg *WelcomeMessage) error {
+	return s.putJSON(fmt.Sprintf(`INSERT INTO welcome (chat_id, %[1]s) VALUES (?, ?)
+		ON CONFLICT (chat_id) DO UPDATE SET %[1]s = excluded.%[1]s`, column), msg, chatID)
+}
+
+func (s *sqliteStore) getGreeting(column string, chatID int64) (*WelcomeMessage, error) {
+	var data sql.NullString
+	err := s.db.QueryRow(fmt.Sprintf(`SELECT %s FROM welcome WHERE chat_id = ?`, column), chatID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !data.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	msg := &WelcomeMessage{}
+	return msg, json.Unmarshal([]byte(data.String), msg)
+}
+
+func (s *sqliteStore) SetWelcome(chatID int64, msg *WelcomeMessage) error {
+	return s.setGreeting("welcome", chatID, msg)
+}
+
+func (s *sqliteStore) GetWelcome(chatID int64) (*WelcomeMessage, error) {
+	return s.getGreeting("welcome", chatID)
+}
+
+func (s *sqliteStore) SetGoodbye(chatID int64, msg *WelcomeMessage) error {
+	return s.setGreeting("goodbye", chatID, msg)
+}
+
+func (s *sqliteStore) GetGoodbye(chatID int64) (*WelcomeMessage, error) {
+	return s.getGreeting("goodbye", chatID)
+}
+
+func (s *sqliteStore) SetLastWelcomeID(chatID int64, msgID int) error {
+	_, err := s.db.Exec(`INSERT INTO welcome (chat_id, last_msg_id) VALUES (?, ?)
+		ON CONFLICT (chat_id) DO UPDATE SET last_msg_id = excluded.last_msg_id`, chatID, msgID)
+	return err
+}
+
+func (s *sqliteStore) GetLastWelcomeID(chatID int64) (int, error) {
+	var msgID int
+	err := s.db.QueryRow(`SELECT last_msg_id FROM welcome WHERE chat_id = ?`, chatID).Scan(&msgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return msgID, err
+}
+
+func (s *sqliteStore) SetRulesWithMedia(chatID int64, rules *Rules) error {
+	return s.putJSON(`INSERT OR REPLACE INTO rules (chat_id, data) VALUES (?, ?)`, rules, chatID)
+}
+
+func (s *sqliteStore) GetRulesWithMedia(chatID int64) (*Rules, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM rules WHERE chat_id = ?`, chatID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &Rules{}
+	if err := json.Unmarshal([]byte(data), rules); err != nil {
+		rules = &Rules{Content: data}
+	}
+	return rules, nil
+}
+
+func (s *sqliteStore) DeleteRules(chatID int64) error {
+	_, err := s.db.Exec(`DELETE FROM rules WHERE chat_id = ?`, chatID)
+	return err
+}
+
+func (s *sqliteStore) AddBlacklist(chatID int64, entry *BlacklistEntry) error {
+	return s.putJSON(`INSERT OR REPLACE INTO blacklist (chat_id, word, data) VALUES (?, ?, ?)`, entry, chatID, entry.Word)
+}
+
+func (s *sqliteStore) RemoveBlacklist(chatID int64, word string) error {
+	_, err := s.db.Exec(`DELETE FROM blacklist WHERE chat_id = ? AND word = ?`, chatID, word)
+	return err
+}
+
+func (s *sqliteStore) GetBlacklist(chatID int64) ([]*BlacklistEntry, error) {
+	return listJSON[BlacklistEntry](s, `SELECT data FROM blacklist WHERE chat_id = ? ORDER BY word`, chatID)
+}
+
+func (s *sqliteStore) IsBlacklisted(chatID int64, word string) bool {
+	n, err := s.count(`SELECT COUNT(*) FROM blacklist WHERE chat_id = ? AND word = ?`, chatID, word)
+	return err == nil && n > 0
+}
+
+func (s *sqliteStore) SetBlacklistSettings(chatID int64, settings *BlacklistSettings) error {
+	return s.putJSON(`INSERT OR REPLACE INTO blacklist_settings (chat_id, data) VALUES (?, ?)`, settings, chatID)
+}
+
+func (s *sqliteStore) GetBlacklistSettings(chatID int64) (*BlacklistSettings, error) {
+	settings := &BlacklistSettings{Action: ActionDelete}
+	_, err := s.getJSON(`SELECT data FROM blacklist_settings WHERE chat_id = ?`, settings, chatID)
+	return settings, err
+}
+
+func (s *sqliteStore) GetBlacklistCount(chatID int64) (int, error) {
+	return s.count(`SELECT COUNT(*) FROM blacklist WHERE chat_id = ?`, chatID)
+}
+
+func (s *sqliteStore) ClearBlacklist(chatID int64) error {
+	_, err := s.db.Exec(`DELETE FROM blacklist WHERE chat_id = ?`, chatID)
+	return err
+}
+
+func (s *sqliteStore) GetUserPacks(userID int64) (map[string][]*PackInfo, error) {
+	packs := make(map[string][]*PackInfo)
+	for _, packType := range []string{"normal", "webm", "tgs"} {
+		list, err := listJSON[PackInfo](s, `SELECT data FROM sticker_packs WHERE user_id = ? AND type = ? ORDER BY pack_number`, userID, packType)
+		if err != nil {
+			return packs, err
+		}
+		if list == nil {
+			list = []*PackInfo{}
+		}
+		packs[packType] = list
+	}
+	return packs, nil
+}
+
+func (s *sqliteStore) GetActivePack(userID int64, packType string) (*PackInfo, error) {
+	pack := &PackInfo{}
+	found, err := s.getJSON(`SELECT p.data FROM sticker_packs p
+		JOIN sticker_active a ON a.user_id = p.user_id AND a.type = p.type AND a.pack_number = p.pack_number
+		WHERE p.user_id = ? AND p.type = ?`, pack, userID, packType)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return pack, nil
+	}
+	found, err = s.getJSON(`SELECT data FROM sticker_packs WHERE user_id = ? AND type = ? ORDER BY pack_number DESC LIMIT 1`, pack, userID, packType)
+	if err != nil || !found {
+		return nil, err
+	}
+	return pack, nil
+}
+
+func (s *sqliteStore) SetActivePack(userID int64, packType string, packNumber int) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO sticker_active (user_id, type, pack_number) VALUES (?, ?, ?)`, userID, packType, packNumber)
+	return err
+}
+
+func (s *sqliteStore) DeletePack(userID int64, packType string, packNumber int) error {
+	if _, err := s.db.Exec(`DELETE FROM sticker_active WHERE user_id = ? AND type = ? AND pack_number = ?`, userID, packType, packNumber); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM sticker_packs WHERE user_id = ? AND type = ? AND pack_number = ?`, userID, packType, packNumber)
+	return err
+}
+
+func (s *sqliteStore) SavePack(userID int64, pack *PackInfo) error {
+	return s.putJSON(`INSERT OR REPLACE INTO sticker_packs (user_id, type, pack_number, short_name, data) VALUES (?, ?, ?, ?, ?)`,
+		pack, userID, pack.Type, pack.PackNumber, pack.ShortName)
+}
+
+func (s *sqliteStore) ReservePackNumber(userID int64, packType string) (int, error) {
+	var next int
+	err := s.db.QueryRow(`INSERT INTO sticker_next (user_id, type, pack_number)
+		VALUES (?, ?, COALESCE((SELECT MAX(pack_number) FROM sticker_packs WHERE user_id = ? AND type = ?), 0) + 1)
+		ON CONFLICT (user_id, type) DO UPDATE SET pack_number = MAX(pack_number + 1, excluded.pack_number)
+		RETURNING pack_number`, userID, packType, userID, packType).Scan(&next)
+	return next, err
+}
+
+func (s *sqliteStore) GetPackByShortName(userID int64, shortName string) (*PackInfo, error) {
+	pack := &PackInfo{}
+	found, err := s.getJSON(`SELECT data FROM sticker_packs WHERE user_id = ? AND short_name = ?`, pack, userID, shortName)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("pack not found")
+	}
+	return pack, nil
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
 
 	bolt "go.etcd.io/bbolt"
@@ -16,33 +17,22 @@ type PackInfo struct {
 	PackNumber   int    `json:"pack_number"`
 }
 
-func ensureStickerBuckets(db *bolt.DB) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("sticker_users"))
-		if err != nil {
-			return err
-		}
-		_, err = tx.CreateBucketIfNotExists([]byte("sticker_packs"))
-		return err
-	})
+// Buckets:
+//
+//	sticker_users/<userID>/<type>/<packNumber> -> PackInfo
+//	sticker_active/<userID>:<type>             -> packNumber picked with /mypacks
+//	sticker_next/<userID>:<type>               -> highest packNumber ever handed out
+func activePackKey(userID int64, packType string) []byte {
+	return []byte(strconv.FormatInt(userID, 10) + ":" + packType)
 }
 
-func GetUserPacks(userID int64) (map[string][]*PackInfo, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-
-	if err := ensureStickerBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetUserPacks(userID int64) (map[string][]*PackInfo, error) {
 	packs := make(map[string][]*PackInfo)
 	packs["normal"] = []*PackInfo{}
 	packs["webm"] = []*PackInfo{}
 	packs["tgs"] = []*PackInfo{}
 
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		usersBucket := tx.Bucket([]byte("sticker_users"))
 		if usersBucket == nil {
 			return nil
@@ -66,6 +56,10 @@ func GetUserPacks(userID int64) (map[string][]*PackInfo, error) {
 				}
 				packs[packType] = append(packs[packType], &pack)
 			}
+			// Keys sort as strings, so "10" would come before "2".
+			slices.SortFunc(packs[packType], func(a, b *PackInfo) int {
+				return a.PackNumber - b.PackNumber
+			})
 		}
 		return nil
 	})
@@ -73,18 +67,11 @@ func GetUserPacks(userID int64) (map[string][]*PackInfo, error) {
 	return packs, err
 }
 
-func GetActivePack(userID int64, packType string) (*PackInfo, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-
-	if err := ensureStickerBuckets(db); err != nil {
-		return nil, err
-	}
-
+// GetActivePack returns the pack picked with SetActivePack, falling back to
+// the highest numbered pack of that type.
+func (s *boltStore) GetActivePack(userID int64, packType string) (*PackInfo, error) {
 	var pack *PackInfo
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		usersBucket := tx.Bucket([]byte("sticker_users"))
 		if usersBucket == nil {
 			return nil
@@ -100,36 +87,59 @@ func GetActivePack(userID int64, packType string) (*PackInfo, error) {
 			return nil
 		}
 
-		c := typeBucket.Cursor()
-		var lastKey []byte
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			lastKey = k
-		}
-
-		if lastKey != nil {
-			v := typeBucket.Get(lastKey)
-			if v != nil {
+		if active := tx.Bucket([]byte("sticker_active")).Get(activePackKey(userID, packType)); active != nil {
+			if v := typeBucket.Get(active); v != nil {
 				pack = &PackInfo{}
 				return json.Unmarshal(v, pack)
 			}
 		}
+
+		c := typeBucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var p PackInfo
+			if err := json.Unmarshal(v, &p); err != nil {
+				continue
+			}
+			if pack == nil || p.PackNumber > pack.PackNumber {
+				pack = &p
+			}
+		}
 		return nil
 	})
 
 	return pack, err
 }
 
-func SavePack(userID int64, pack *PackInfo) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
+func (s *boltStore) SetActivePack(userID int64, packType string, packNumber int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("sticker_active")).Put(activePackKey(userID, packType), []byte(strconv.Itoa(packNumber)))
+	})
+}
 
-	if err := ensureStickerBuckets(db); err != nil {
-		return err
-	}
+func (s *boltStore) DeletePack(userID int64, packType string, packNumber int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(strconv.Itoa(packNumber))
+		active := tx.Bucket([]byte("sticker_active"))
+		if string(active.Get(activePackKey(userID, packType))) == string(key) {
+			if err := active.Delete(activePackKey(userID, packType)); err != nil {
+				return err
+			}
+		}
+
+		userBucket := tx.Bucket([]byte("sticker_users")).Bucket([]byte(strconv.FormatInt(userID, 10)))
+		if userBucket == nil {
+			return nil
+		}
+		typeBucket := userBucket.Bucket([]byte(packType))
+		if typeBucket == nil {
+			return nil
+		}
+		return typeBucket.Delete(key)
+	})
+}
 
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) SavePack(userID int64, pack *PackInfo) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		usersBucket := tx.Bucket([]byte("sticker_users"))
 		userBucket, err := usersBucket.CreateBucketIfNotExists([]byte(strconv.FormatInt(userID, 10)))
 		if err != nil {
@@ -151,6 +161,31 @@ func SavePack(userID int64, pack *PackInfo) error {
 	})
 }
 
+func (s *boltStore) ReservePackNumber(userID int64, packType string) (int, error) {
+	var next int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		counters := tx.Bucket([]byte("sticker_next"))
+		key := activePackKey(userID, packType)
+		last, _ := strconv.Atoi(string(counters.Get(key)))
+
+		// Packs made before the counter existed still hold their numbers.
+		if userBucket := tx.Bucket([]byte("sticker_users")).Bucket([]byte(strconv.FormatInt(userID, 10))); userBucket != nil {
+			if typeBucket := userBucket.Bucket([]byte(packType)); typeBucket != nil {
+				typeBucket.ForEach(func(k, _ []byte) error {
+					if n, err := strconv.Atoi(string(k)); err == nil && n > last {
+						last = n
+					}
+					return nil
+				})
+			}
+		}
+
+		next = last + 1
+		return counters.Put(key, []byte(strconv.Itoa(next)))
+	})
+	return next, err
+}
+
 func IncrementPackCount(userID int64, pack *PackInfo) error {
 	pack.StickerCount++
 	return SavePack(userID, pack)
@@ -163,18 +198,9 @@ func DecrementPackCount(userID int64, pack *PackInfo) error {
 	return SavePack(userID, pack)
 }
 
-func GetPackByShortName(userID int64, shortName string) (*PackInfo, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-
-	if err := ensureStickerBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetPackByShortName(userID int64, shortName string) (*PackInfo, error) {
 	var pack *PackInfo
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		usersBucket := tx.Bucket([]byte("sticker_users"))
 		if usersBucket == nil {
 			return fmt.Errorf("no packs found")
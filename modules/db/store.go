@@ -0,0 +1,512 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BackendBolt   = "bolt"
+	BackendSQLite = "sqlite"
+
+	defaultSQLitePath = "database.sqlite"
+)
+
+// Store is the storage backend for per-chat moderation data. DB_BACKEND picks
+// the implementation: "bolt" (default, database.db) or "sqlite" (SQLITE_PATH,
+// default database.sqlite). Everything else still lives in bbolt.
+type Store interface {
+	SaveNote(chatID int64, note *Note) error
+	GetNote(chatID int64, name string) (*Note, error)
+	GetAllNotes(chatID int64) ([]*Note, error)
+	DeleteNote(chatID int64, name string) error
+	DeleteAllNotes(chatID int64) error
+	GetNotesCount(chatID int64) (int, error)
+	DeleteExpiredNotes(now time.Time, limit int) ([]*ExpiredNote, error)
+
+	SaveFilter(chatID int64, filter *Filter) error
+	GetFilter(chatID int64, keyword string) (*Filter, error)
+	GetAllFilters(chatID int64) ([]*Filter, error)
+	DeleteFilter(chatID int64, keyword string) error
+	DeleteAllFilters(chatID int64) error
+	GetFiltersCount(chatID int64) (int, error)
+
+	AddWarn(chatID, userID int64, warn *Warn) (int, error)
+	GetWarns(chatID, userID int64) ([]*Warn, error)
+	ResetWarns(chatID, userID int64) error
+	SetWarnSettings(chatID int64, settings *WarnSettings) error
+	GetWarnSettings(chatID int64) (*WarnSettings, error)
+	DeleteExpiredWarns(now time.Time, limit int) ([]*ExpiredWarn, error)
+
+	SetWelcome(chatID int64, msg *WelcomeMessage) error
+	GetWelcome(chatID int64) (*WelcomeMessage, error)
+	SetGoodbye(chatID int64, msg *WelcomeMessage) error
+	GetGoodbye(chatID int64) (*WelcomeMessage, error)
+	SetLastWelcomeID(chatID int64, msgID int) error
+	GetLastWelcomeID(chatID int64) (int, error)
+
+	SetRulesWithMedia(chatID int64, rules *Rules) error
+	GetRulesWithMedia(chatID int64) (*Rules, error)
+	DeleteRules(chatID int64) error
+
+	AddBlacklist(chatID int64, entry *BlacklistEntry) error
+	RemoveBlacklist(chatID int64, word string) error
+	GetBlacklist(chatID int64) ([]*BlacklistEntry, error)
+	IsBlacklisted(chatID int64, word string) bool
+	SetBlacklistSettings(chatID int64, settings *BlacklistSettings) error
+	GetBlacklistSettings(chatID int64) (*BlacklistSettings, error)
+	GetBlacklistCount(chatID int64) (int, error)
+	ClearBlacklist(chatID int64) error
+
+	GetUserPacks(userID int64) (map[string][]*PackInfo, error)
+	GetActivePack(userID int64, packType string) (*PackInfo, error)
+	SetActivePack(userID int64, packType string, packNumber int) error
+	DeletePack(userID int64, packType string, packNumber int) error
+	SavePack(userID int64, pack *PackInfo) error
+	GetPackByShortName(userID int64, shortName string) (*PackInfo, error)
+	ReservePackNumber(userID int64, packType string) (int, error)
+
+	Close() error
+}
+
+var (
+	sharedStore     Store
+	sharedStoreErr  error
+	sharedStoreOnce sync.Once
+)
+
+// GetStore returns the backend chosen by DB_BACKEND, opening it on first use.
+func GetStore() (Store, error) {
+	sharedStoreOnce.Do(func() {
+		sharedStore, sharedStoreErr = OpenStore(os.Getenv("DB_BACKEND"))
+	})
+	return sharedStore, sharedStoreErr
+}
+
+// OpenStore opens the named backend; an empty name means bolt.
+func OpenStore(backend string) (Store, error) {
+	switch strings.ToLower(strings.TrimSpace(backend)) {
+	case "", BackendBolt:
+		db, err := GetDB()
+		if err != nil {
+			return nil, err
+		}
+		return newBoltStore(db)
+	case BackendSQLite:
+		return OpenSQLiteStore(SQLitePath())
+	default:
+		return nil, fmt.Errorf("unknown DB_BACKEND %q (want %s or %s)", backend, BackendBolt, BackendSQLite)
+	}
+}
+
+// StoreBackend returns the backend DB_BACKEND selects.
+func StoreBackend() string {
+	if backend := strings.ToLower(strings.TrimSpace(os.Getenv("DB_BACKEND"))); backend != "" {
+		return backend
+	}
+	return BackendBolt
+}
+
+func SQLitePath() string {
+	if path := os.Getenv("SQLITE_PATH"); path != "" {
+		return path
+	}
+	return defaultSQLitePath
+}
+
+// boltStore keeps the original bbolt layout. Its buckets are created when the
+// file is opened, see createBuckets.
+type boltStore struct {
+	db *DB
+}
+
+func newBoltStore(db *DB) (*boltStore, error) {
+	return &boltStore{db: db}, nil
+}
+
+// Close is a no-op; the shared bolt handle is closed by CloseDB.
+func (s *boltStore) Close() error {
+	return nil
+}
+
+func SaveNote(chatID int64, note *Note) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.SaveNote(chatID, note)
+}
+
+func GetNote(chatID int64, name string) (*Note, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetNote(chatID, name)
+}
+
+func GetAllNotes(chatID int64) ([]*Note, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetAllNotes(chatID)
+}
+
+func DeleteNote(chatID int64, name string) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.DeleteNote(chatID, name)
+}
+
+func DeleteAllNotes(chatID int64) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.DeleteAllNotes(chatID)
+}
+
+func GetNotesCount(chatID int64) (int, error) {
+	s, err := GetStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.GetNotesCount(chatID)
+}
+
+func DeleteExpiredNotes(now time.Time, limit int) ([]*ExpiredNote, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.DeleteExpiredNotes(now, limit)
+}
+
+func SaveFilter(chatID int64, filter *Filter) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	err = s.SaveFilter(chatID, filter)
+	if err == nil {
+		notifyFilterChange(chatID)
+	}
+	return err
+}
+
+func GetFilter(chatID int64, keyword string) (*Filter, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	filter, err := s.GetFilter(chatID, keyword)
+	if filter != nil {
+		migrateFilter(filter)
+	}
+	return filter, err
+}
+
+func GetAllFilters(chatID int64) ([]*Filter, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	filters, err := s.GetAllFilters(chatID)
+	for _, filter := range filters {
+		migrateFilter(filter)
+	}
+	return filters, err
+}
+
+func DeleteFilter(chatID int64, keyword string) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	err = s.DeleteFilter(chatID, keyword)
+	if err == nil {
+		notifyFilterChange(chatID)
+	}
+	return err
+}
+
+func DeleteAllFilters(chatID int64) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	err = s.DeleteAllFilters(chatID)
+	if err == nil {
+		notifyFilterChange(chatID)
+	}
+	return err
+}
+
+func GetFiltersCount(chatID int64) (int, error) {
+	s, err := GetStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.GetFiltersCount(chatID)
+}
+
+func AddWarn(chatID, userID int64, warn *Warn) (int, error) {
+	s, err := GetStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.AddWarn(chatID, userID, warn)
+}
+
+func GetWarns(chatID, userID int64) ([]*Warn, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetWarns(chatID, userID)
+}
+
+func ResetWarns(chatID, userID int64) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.ResetWarns(chatID, userID)
+}
+
+func SetWarnSettings(chatID int64, settings *WarnSettings) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.SetWarnSettings(chatID, settings)
+}
+
+func GetWarnSettings(chatID int64) (*WarnSettings, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetWarnSettings(chatID)
+}
+
+func DeleteExpiredWarns(now time.Time, limit int) ([]*ExpiredWarn, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.DeleteExpiredWarns(now, limit)
+}
+
+func SetWelcome(chatID int64, msg *WelcomeMessage) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.SetWelcome(chatID, msg)
+}
+
+func GetWelcome(chatID int64) (*WelcomeMessage, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetWelcome(chatID)
+}
+
+func SetGoodbye(chatID int64, msg *WelcomeMessage) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.SetGoodbye(chatID, msg)
+}
+
+func GetGoodbye(chatID int64) (*WelcomeMessage, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGoodbye(chatID)
+}
+
+func SetLastWelcomeID(chatID int64, msgID int) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.SetLastWelcomeID(chatID, msgID)
+}
+
+func GetLastWelcomeID(chatID int64) (int, error) {
+	s, err := GetStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.GetLastWelcomeID(chatID)
+}
+
+func SetRulesWithMedia(chatID int64, rules *Rules) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.SetRulesWithMedia(chatID, rules)
+}
+
+func GetRulesWithMedia(chatID int64) (*Rules, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetRulesWithMedia(chatID)
+}
+
+func DeleteRules(chatID int64) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.DeleteRules(chatID)
+}
+
+func AddBlacklist(chatID int64, entry *BlacklistEntry) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	err = s.AddBlacklist(chatID, entry)
+	if err == nil {
+		notifyBlacklistChange(chatID)
+	}
+	return err
+}
+
+func RemoveBlacklist(chatID int64, word string) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	err = s.RemoveBlacklist(chatID, word)
+	if err == nil {
+		notifyBlacklistChange(chatID)
+	}
+	return err
+}
+
+func GetBlacklist(chatID int64) ([]*BlacklistEntry, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBlacklist(chatID)
+}
+
+func IsBlacklisted(chatID int64, word string) bool {
+	s, err := GetStore()
+	if err != nil {
+		return false
+	}
+	return s.IsBlacklisted(chatID, word)
+}
+
+func SetBlacklistSettings(chatID int64, settings *BlacklistSettings) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.SetBlacklistSettings(chatID, settings)
+}
+
+func GetBlacklistSettings(chatID int64) (*BlacklistSettings, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBlacklistSettings(chatID)
+}
+
+func GetBlacklistCount(chatID int64) (int, error) {
+	s, err := GetStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.GetBlacklistCount(chatID)
+}
+
+func ClearBlacklist(chatID int64) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	err = s.ClearBlacklist(chatID)
+	if err == nil {
+		notifyBlacklistChange(chatID)
+	}
+	return err
+}
+
+func GetUserPacks(userID int64) (map[string][]*PackInfo, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetUserPacks(userID)
+}
+
+func GetActivePack(userID int64, packType string) (*PackInfo, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetActivePack(userID, packType)
+}
+
+func SetActivePack(userID int64, packType string, packNumber int) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.SetActivePack(userID, packType, packNumber)
+}
+
+func DeletePack(userID int64, packType string, packNumber int) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.DeletePack(userID, packType, packNumber)
+}
+
+func SavePack(userID int64, pack *PackInfo) error {
+	s, err := GetStore()
+	if err != nil {
+		return err
+	}
+	return s.SavePack(userID, pack)
+}
+
+func GetPackByShortName(userID int64, shortName string) (*PackInfo, error) {
+	s, err := GetStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPackByShortName(userID, shortName)
+}
+
+// ReservePackNumber hands out the number for a user's next pack of packType.
+// Numbers are never reused, even after the pack holding one is deleted, since
+// the short name built from it stays taken on Telegram's side.
+func ReservePackNumber(userID int64, packType string) (int, error) {
+	s, err := GetStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.ReservePackNumber(userID, packType)
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// MigrateBoltStore copies every record covered by Store out of database.db
+// into dst and returns how many were copied per kind. Records that already
+// exist in dst are overwritten and warns are replaced per user, so it is safe
+// to run again after a partial copy.
+func MigrateBoltStore(dst Store) (map[string]int, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	err = db.View(func(tx *bolt.Tx) error {
+		// eachChat walks bucket/<chatID>/<key> -> value.
+		eachChat := func(bucket string, fn func(chatID int64, k, v []byte) error) error {
+			b := tx.Bucket([]byte(bucket))
+			if b == nil {
+				return nil
+			}
+			return b.ForEachBucket(func(chatKey []byte) error {
+				chatID, err := strconv.ParseInt(string(chatKey), 10, 64)
+				if err != nil {
+					return nil
+				}
+				return b.Bucket(chatKey).ForEach(func(k, v []byte) error {
+					if v == nil {
+						return nil
+					}
+					return fn(chatID, k, v)
+				})
+			})
+		}
+		// eachSetting walks bucket/<chatID> -> value.
+		eachSetting := func(bucket string, fn func(chatID int64, v []byte) error) error {
+			b := tx.Bucket([]byte(bucket))
+			if b == nil {
+				return nil
+			}
+			return b.ForEach(func(k, v []byte) error {
+				chatID, err := strconv.ParseInt(string(k), 10, 64)
+				if err != nil || v == nil {
+					return nil
+				}
+				return fn(chatID, v)
+			})
+		}
+
+		if err := eachChat("notes", func(chatID int64, k, v []byte) error {
+			var note Note
+			if json.Unmarshal(v, &note) != nil {
+				return nil
+			}
+			counts["notes"]++
+			return dst.SaveNote(chatID, &note)
+		}); err != nil {
+			return err
+		}
+
+		if err := eachChat("filters", func(chatID int64, k, v []byte) error {
+			var filter Filter
+			if json.Unmarshal(v, &filter) != nil {
+				return nil
+			}
+			counts["filters"]++
+			return dst.SaveFilter(chatID, &filter)
+		}); err != nil {
+			return err
+		}
+
+		if err := eachChat("blacklist", func(chatID int64, k, v []byte) error {
+			var entry BlacklistEntry
+			if json.Unmarshal(v, &entry) != nil {
+				return nil
+			}
+			counts["blacklist"]++
+			return dst.AddBlacklist(chatID, &entry)
+		}); err != nil {
+			return err
+		}
+
+		if err := eachSetting("blacklist_settings", func(chatID int64, v []byte) error {
+			var settings BlacklistSettings
+			if json.Unmarshal(v, &settings) != nil {
+				return nil
+			}
+			counts["blacklist_settings"]++
+			return dst.SetBlacklistSettings(chatID, &settings)
+		}); err != nil {
+			return err
+		}
+
+		if err := eachSetting("warns_settings", func(chatID int64, v []byte) error {
+			var settings WarnSettings
+			if json.Unmarshal(v, &settings) != nil {
+				return nil
+			}
+			counts["warn_settings"]++
+			return dst.SetWarnSettings(chatID, &settings)
+		}); err != nil {
+			return err
+		}
+
+		if err := eachSetting("rules", func(chatID int64, v []byte) error {
+			rules := &Rules{}
+			if json.Unmarshal(v, rules) != nil {
+				rules = &Rules{Content: string(v)}
+			}
+			counts["rules"]++
+			return dst.SetRulesWithMedia(chatID, rules)
+		}); err != nil {
+			return err
+		}
+
+		if err := eachChat("welcome", func(chatID int64, k, v []byte) error {
+			switch string(k) {
+			case "msg", "bye":
+				msg := &WelcomeMessage{}
+				if json.Unmarshal(v, msg) != nil {
+					return nil
+				}
+				if string(k) == "bye" {
+					counts["goodbye"]++
+					return dst.SetGoodbye(chatID, msg)
+				}
+				counts["welcome"]++
+				return dst.SetWelcome(chatID, msg)
+			case "last_msg_id":
+				msgID, _ := strconv.Atoi(string(v))
+				return dst.SetLastWelcomeID(chatID, msgID)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if warns := tx.Bucket([]byte("warns")); warns != nil {
+			if err := warns.ForEachBucket(func(chatKey []byte) error {
+				chatID, err := strconv.ParseInt(string(chatKey), 10, 64)
+				if err != nil {
+					return nil
+				}
+				cb := warns.Bucket(chatKey)
+				return cb.ForEachBucket(func(userKey []byte) error {
+					userID, err := strconv.ParseInt(string(userKey), 10, 64)
+					if err != nil {
+						return nil
+					}
+					if err := dst.ResetWarns(chatID, userID); err != nil {
+						return err
+					}
+					return cb.Bucket(userKey).ForEach(func(k, v []byte) error {
+						var w Warn
+						if json.Unmarshal(v, &w) != nil {
+							return nil
+						}
+						counts["warns"]++
+						_, err := dst.AddWarn(chatID, userID, &w)
+						return err
+					})
+				})
+			}); err != nil {
+				return err
+			}
+		}
+
+		if users := tx.Bucket([]byte("sticker_users")); users != nil {
+			if err := users.ForEachBucket(func(userKey []byte) error {
+				userID, err := strconv.ParseInt(string(userKey), 10, 64)
+				if err != nil {
+					return nil
+				}
+				ub := users.Bucket(userKey)
+				return ub.ForEachBucket(func(typeKey []byte) error {
+					return ub.Bucket(typeKey).ForEach(func(k, v []byte) error {
+						var pack PackInfo
+						if json.Unmarshal(v, &pack) != nil {
+							return nil
+						}
+						if pack.Type == "" {
+							pack.Type = string(typeKey)
+						}
+						counts["sticker_packs"]++
+						return dst.SavePack(userID, &pack)
+					})
+				})
+			}); err != nil {
+				return err
+			}
+		}
+
+		if active := tx.Bucket([]byte("sticker_active")); active != nil {
+			if err := active.ForEach(func(k, v []byte) error {
+				user, packType, ok := strings.Cut(string(k), ":")
+				if !ok {
+					return nil
+				}
+				userID, err := strconv.ParseInt(user, 10, 64)
+				if err != nil {
+					return nil
+				}
+				packNumber, err := strconv.Atoi(string(v))
+				if err != nil {
+					return nil
+				}
+				counts["active_packs"]++
+				return dst.SetActivePack(userID, packType, packNumber)
+			}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return counts, err
+}
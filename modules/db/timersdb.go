@@ -0,0 +1,153 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type Timer struct {
+	ID          string `json:"id"`
+	ChatID      int64  `json:"chat_id"`
+	UserID      int64  `json:"user_id"`
+	Message     string `json:"message,omitempty"`
+	MediaFileID string `json:"media_file_id,omitempty"`
+	// FireAt is the next regular run. One-off timers keep their FireAt after
+	// going off, with Fired set, so their buttons keep working until dismissed.
+	FireAt      time.Time `json:"fire_at"`
+	IntervalSec int64     `json:"interval_sec,omitempty"`
+	Fired       bool      `json:"fired,omitempty"`
+	// SnoozeUntil is set while a fired notification is snoozed.
+	SnoozeUntil time.Time `json:"snooze_until,omitzero"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Interval is how often a recurring timer repeats; zero for one-off timers.
+func (t *Timer) Interval() time.Duration {
+	return time.Duration(t.IntervalSec) * time.Second
+}
+
+// NextRun returns when the timer should next go off, or the zero time if it
+// is only waiting to be dismissed.
+func (t *Timer) NextRun() time.Time {
+	next := time.Time{}
+	if !t.Fired {
+		next = t.FireAt
+	}
+	if !t.SnoozeUntil.IsZero() && (next.IsZero() || t.SnoozeUntil.Before(next)) {
+		next = t.SnoozeUntil
+	}
+	return next
+}
+
+// SaveTimer stores t, assigning it an ID if it doesn't have one yet.
+func SaveTimer(t *Timer) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("timers"))
+		if t.ID == "" {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			t.ID = strconv.FormatUint(id, 10)
+		}
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(t.ID), data)
+	})
+}
+
+// GetTimer returns the timer with the given ID, or nil.
+func GetTimer(id string) (*Timer, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var t *Timer
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte("timers")).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		t = &Timer{}
+		return json.Unmarshal(data, t)
+	})
+	return t, err
+}
+
+// UpdateTimer applies fn to the stored timer and saves it if fn returns true,
+// all in one transaction so concurrent updates can't overwrite each other. It
+// returns the timer as fn left it, or nil if there is no such timer.
+func UpdateTimer(id string, fn func(t *Timer) bool) (*Timer, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var t *Timer
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("timers"))
+		data := b.Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		t = &Timer{}
+		if err := json.Unmarshal(data, t); err != nil {
+			return err
+		}
+		if !fn(t) {
+			return nil
+		}
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), data)
+	})
+	return t, err
+}
+
+func DeleteTimer(id string) error {
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("timers")).Delete([]byte(id))
+	})
+}
+
+// GetTimers returns all timers, or only those for chatID/userID when they are
+// non-zero.
+func GetTimers(chatID, userID int64) ([]*Timer, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	var timers []*Timer
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("timers")).ForEach(func(k, v []byte) error {
+			var t Timer
+			if err := json.Unmarshal(v, &t); err != nil {
+				return nil
+			}
+			if (chatID == 0 || t.ChatID == chatID) && (userID == 0 || t.UserID == userID) {
+				timers = append(timers, &t)
+			}
+			return nil
+		})
+	})
+	return timers, err
+}
@@ -20,6 +20,26 @@ type Warn struct {
 	Reason    string    `json:"reason"`
 	AdminID   int64     `json:"admin_id"`
 	Timestamp time.Time `json:"timestamp"`
+	// ExpiresAt is set for /twarn warnings; the janitor removes them after it.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// Expired reports whether w should be removed at now, either because it was
+// a temporary warning or because it is older than decayDays.
+func (w *Warn) Expired(now time.Time, decayDays int) bool {
+	if !w.ExpiresAt.IsZero() && now.After(w.ExpiresAt) {
+		return true
+	}
+	return decayDays > 0 && now.Sub(w.Timestamp) > time.Duration(decayDays)*24*time.Hour
+}
+
+// ExpiredWarn is a warning removed by DeleteExpiredWarns. Decayed is set when
+// it aged out under WarnSettings.DecayDays rather than hitting its ExpiresAt.
+type ExpiredWarn struct {
+	ChatID  int64
+	UserID  int64
+	Reason  string
+	Decayed bool
 }
 
 type WarnSettings struct {
@@ -28,29 +48,10 @@ type WarnSettings struct {
 	DecayDays int        `json:"decay_days"`
 }
 
-func ensureWarnsBuckets(db *bolt.DB) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("warns"))
-		if err != nil {
-			return err
-		}
-		_, err = tx.CreateBucketIfNotExists([]byte("warns_settings"))
-		return err
-	})
-}
-
-func AddWarn(chatID, userID int64, warn *Warn) (int, error) {
-	db, err := GetDB()
-	if err != nil {
-		return 0, err
-	}
-	if err := ensureWarnsBuckets(db); err != nil {
-		return 0, err
-	}
-
+func (s *boltStore) AddWarn(chatID, userID int64, warn *Warn) (int, error) {
 	var count int
 
-	err = db.Update(func(tx *bolt.Tx) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("warns"))
 		cb, err := b.CreateBucketIfNotExists([]byte(strconv.FormatInt(chatID, 10)))
 		if err != nil {
@@ -71,24 +72,20 @@ func AddWarn(chatID, userID int64, warn *Warn) (int, error) {
 			return err
 		}
 
-		count = ub.Stats().KeyN
+		// Stats() only reflects committed pages, so count the keys directly.
+		c := ub.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			count++
+		}
 		return nil
 	})
 
 	return count, err
 }
 
-func GetWarns(chatID, userID int64) ([]*Warn, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureWarnsBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetWarns(chatID, userID int64) ([]*Warn, error) {
 	var warns []*Warn
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("warns"))
 		if b == nil {
 			return nil
@@ -115,16 +112,8 @@ func GetWarns(chatID, userID int64) ([]*Warn, error) {
 	return warns, err
 }
 
-func ResetWarns(chatID, userID int64) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureWarnsBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) ResetWarns(chatID, userID int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("warns"))
 		if b == nil {
 			return nil
@@ -137,16 +126,8 @@ func ResetWarns(chatID, userID int64) error {
 	})
 }
 
-func SetWarnSettings(chatID int64, settings *WarnSettings) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureWarnsBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) SetWarnSettings(chatID int64, settings *WarnSettings) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("warns_settings"))
 		data, err := json.Marshal(settings)
 		if err != nil {
@@ -156,21 +137,13 @@ func SetWarnSettings(chatID int64, settings *WarnSettings) error {
 	})
 }
 
-func GetWarnSettings(chatID int64) (*WarnSettings, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureWarnsBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetWarnSettings(chatID int64) (*WarnSettings, error) {
 	settings := &WarnSettings{
 		MaxWarns: 3,
 		Action:   WarnActionBan,
 	}
 
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("warns_settings"))
 		if b == nil {
 			return nil
@@ -184,6 +157,108 @@ func GetWarnSettings(chatID int64) (*WarnSettings, error) {
 	return settings, err
 }
 
+// DeleteExpiredWarns removes up to limit expired warnings across all chats.
+// The scan runs in a read transaction, so the write lock is only taken when
+// there is something to delete.
+func (s *boltStore) DeleteExpiredWarns(now time.Time, limit int) ([]*ExpiredWarn, error) {
+	type target struct {
+		chatKey, userKey, key []byte
+		decayDays             int
+		warn                  *ExpiredWarn
+	}
+	var targets []*target
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("warns"))
+		settingsBucket := tx.Bucket([]byte("warns_settings"))
+
+		chats := b.Cursor()
+		for chatKey, v := chats.First(); chatKey != nil && len(targets) < limit; chatKey, v = chats.Next() {
+			if v != nil {
+				continue
+			}
+			chatID, err := strconv.ParseInt(string(chatKey), 10, 64)
+			if err != nil {
+				continue
+			}
+			settings := &WarnSettings{}
+			if data := settingsBucket.Get(chatKey); data != nil {
+				json.Unmarshal(data, settings)
+			}
+
+			cb := b.Bucket(chatKey)
+			users := cb.Cursor()
+			for userKey, v := users.First(); userKey != nil && len(targets) < limit; userKey, v = users.Next() {
+				if v != nil {
+					continue
+				}
+				userID, _ := strconv.ParseInt(string(userKey), 10, 64)
+
+				c := cb.Bucket(userKey).Cursor()
+				for k, v := c.First(); k != nil && len(targets) < limit; k, v = c.Next() {
+					var w Warn
+					if err := json.Unmarshal(v, &w); err != nil || !w.Expired(now, settings.DecayDays) {
+						continue
+					}
+					// Keys are only valid inside the transaction, so copy them.
+					targets = append(targets, &target{
+						chatKey:   append([]byte(nil), chatKey...),
+						userKey:   append([]byte(nil), userKey...),
+						key:       append([]byte(nil), k...),
+						decayDays: settings.DecayDays,
+						warn: &ExpiredWarn{
+							ChatID:  chatID,
+							UserID:  userID,
+							Reason:  w.Reason,
+							Decayed: w.ExpiresAt.IsZero() || now.Before(w.ExpiresAt),
+						},
+					})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil || len(targets) == 0 {
+		return nil, err
+	}
+
+	var expired []*ExpiredWarn
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		expired = nil
+		b := tx.Bucket([]byte("warns"))
+		for _, t := range targets {
+			cb := b.Bucket(t.chatKey)
+			if cb == nil {
+				continue
+			}
+			ub := cb.Bucket(t.userKey)
+			if ub == nil {
+				continue
+			}
+			// The warn may have been removed or reset since the scan.
+			var w Warn
+			v := ub.Get(t.key)
+			if v == nil || json.Unmarshal(v, &w) != nil || !w.Expired(now, t.decayDays) {
+				continue
+			}
+			if err := ub.Delete(t.key); err != nil {
+				return err
+			}
+			expired = append(expired, t.warn)
+			if k, _ := ub.Cursor().First(); k == nil {
+				if err := cb.DeleteBucket(t.userKey); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
 func itob(v int) []byte {
 	b := make([]byte, 8)
 	for i := uint(0); i < 8; i++ {
@@ -17,23 +17,8 @@ type WelcomeMessage struct {
 	Enabled        bool   `json:"enabled"`
 }
 
-func ensureWelcomeBuckets(db *bolt.DB) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("welcome"))
-		return err
-	})
-}
-
-func SetWelcome(chatID int64, msg *WelcomeMessage) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureWelcomeBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) SetWelcome(chatID int64, msg *WelcomeMessage) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("welcome"))
 		cb, err := b.CreateBucketIfNotExists([]byte(strconv.FormatInt(chatID, 10)))
 		if err != nil {
@@ -47,17 +32,9 @@ func SetWelcome(chatID int64, msg *WelcomeMessage) error {
 	})
 }
 
-func GetWelcome(chatID int64) (*WelcomeMessage, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureWelcomeBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetWelcome(chatID int64) (*WelcomeMessage, error) {
 	var msg *WelcomeMessage
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("welcome"))
 		cb := b.Bucket([]byte(strconv.FormatInt(chatID, 10)))
 		if cb == nil {
@@ -73,16 +50,8 @@ func GetWelcome(chatID int64) (*WelcomeMessage, error) {
 	return msg, err
 }
 
-func SetGoodbye(chatID int64, msg *WelcomeMessage) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureWelcomeBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) SetGoodbye(chatID int64, msg *WelcomeMessage) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("welcome"))
 		cb, err := b.CreateBucketIfNotExists([]byte(strconv.FormatInt(chatID, 10)))
 		if err != nil {
@@ -96,17 +65,9 @@ func SetGoodbye(chatID int64, msg *WelcomeMessage) error {
 	})
 }
 
-func GetGoodbye(chatID int64) (*WelcomeMessage, error) {
-	db, err := GetDB()
-	if err != nil {
-		return nil, err
-	}
-	if err := ensureWelcomeBuckets(db); err != nil {
-		return nil, err
-	}
-
+func (s *boltStore) GetGoodbye(chatID int64) (*WelcomeMessage, error) {
 	var msg *WelcomeMessage
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("welcome"))
 		cb := b.Bucket([]byte(strconv.FormatInt(chatID, 10)))
 		if cb == nil {
@@ -122,16 +83,8 @@ func GetGoodbye(chatID int64) (*WelcomeMessage, error) {
 	return msg, err
 }
 
-func SetLastWelcomeID(chatID int64, msgID int) error {
-	db, err := GetDB()
-	if err != nil {
-		return err
-	}
-	if err := ensureWelcomeBuckets(db); err != nil {
-		return err
-	}
-
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *boltStore) SetLastWelcomeID(chatID int64, msgID int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("welcome"))
 		cb, err := b.CreateBucketIfNotExists([]byte(strconv.FormatInt(chatID, 10)))
 		if err != nil {
@@ -141,17 +94,9 @@ func SetLastWelcomeID(chatID int64, msgID int) error {
 	})
 }
 
-func GetLastWelcomeID(chatID int64) (int, error) {
-	db, err := GetDB()
-	if err != nil {
-		return 0, err
-	}
-	if err := ensureWelcomeBuckets(db); err != nil {
-		return 0, err
-	}
-
+func (s *boltStore) GetLastWelcomeID(chatID int64) (int, error) {
 	var msgID int
-	err = db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("welcome"))
 		cb := b.Bucket([]byte(strconv.FormatInt(chatID, 10)))
 		if cb == nil {
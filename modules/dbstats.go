@@ -0,0 +1,96 @@
+package modules
+
+import (
+	"fmt"
+	"main/modules/db"
+	"strings"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+const (
+	// dbStatsChildren is how many of the largest sub-buckets are listed under
+	// each bucket, down to dbStatsDepth levels (chat, then user for warns).
+	dbStatsChildren = 5
+	dbStatsDepth    = 2
+)
+
+// DBStatsHandler - /dbstats, sizes and key counts for every bucket in database.db
+func DBStatsHandler(m *tg.NewMessage) error {
+	if backend := db.StoreBackend(); backend != db.BackendBolt {
+		m.Reply(fmt.Sprintf("/dbstats only covers <code>database.db</code>, but notes, filters and the rest are in <code>%s</code> with <code>DB_BACKEND=%s</code>.", db.SQLitePath(), backend))
+		return nil
+	}
+
+	stats, err := db.GetDBStats()
+	if err != nil {
+		m.Reply("Failed to read database stats: " + err.Error())
+		return nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<b>Database</b> <code>%s</code>\n", stats.Path)
+	fmt.Fprintf(&sb, "Size: <code>%s</code> (page <code>%d B</code>)\n", formatBytes(stats.FileSize), stats.PageSize)
+	fmt.Fprintf(&sb, "Free: <code>%d pages</code>, <code>%s</code> (<code>%.1f%%</code>)\n", stats.FreePages, formatBytes(stats.FreeBytes), stats.FreeRatio()*100)
+	if version, err := db.SchemaVersion(); err == nil {
+		fmt.Fprintf(&sb, "Schema: <code>v%d</code>\n", version)
+	}
+
+	sb.WriteString("\n<b>Buckets</b>\n")
+	for _, b := range stats.Buckets {
+		fmt.Fprintf(&sb, "• <b>%s</b> - %d keys", b.Name, b.Keys)
+		if b.Buckets > 0 {
+			fmt.Fprintf(&sb, ", %d sub-buckets", b.Buckets)
+		}
+		fmt.Fprintf(&sb, ", %s data, %s allocated\n", formatBytes(b.Bytes), formatBytes(b.Alloc))
+		writeBucketChildren(&sb, b, 1)
+	}
+	sb.WriteString("\n" + janitorStatsText())
+
+	for _, chunk := range splitLines(sb.String(), 4000) {
+		m.Reply(chunk)
+	}
+	return nil
+}
+
+func writeBucketChildren(sb *strings.Builder, b *db.BucketStats, depth int) {
+	if depth > dbStatsDepth {
+		return
+	}
+	indent := strings.Repeat("   ", depth)
+	for i, child := range b.Children {
+		if i == dbStatsChildren {
+			fmt.Fprintf(sb, "%s<i>+%d more</i>\n", indent, len(b.Children)-i)
+			break
+		}
+		fmt.Fprintf(sb, "%s↳ <code>%s</code>: %d keys, %s\n", indent, child.Name, child.Keys, formatBytes(child.Bytes))
+		writeBucketChildren(sb, child, depth+1)
+	}
+}
+
+// DBCompactHandler - /dbcompact, rewrites database.db to drop free pages
+func DBCompactHandler(m *tg.NewMessage) error {
+	msg, _ := m.Reply("Compacting database...")
+
+	start := time.Now()
+	before, after, err := db.CompactDB()
+	if err != nil {
+		msg.Edit("Compaction failed: " + err.Error())
+		return nil
+	}
+
+	msg.Edit(fmt.Sprintf("<b>Database compacted</b> in <code>%s</code>\n%s → %s (saved %s)",
+		time.Since(start).Round(time.Millisecond), formatBytes(before), formatBytes(after), formatBytes(before-after)))
+	return nil
+}
+
+func registerDBStatsHandlers() {
+	c := Client
+	c.On("cmd:dbstats", DBStatsHandler, tg.CustomFilter(FilterOwner))
+	c.On("cmd:dbcompact", DBCompactHandler, tg.CustomFilter(FilterOwner))
+}
+
+func init() {
+	QueueHandlerRegistration(registerDBStatsHandlers)
+}
@@ -658,13 +658,16 @@ func GoHandler(m *tg.NewMessage) error {
   • In Use: <code>%.2f MB</code>
 
 <b>Stack</b>: <code>%.2f MB</code>
-<b>GC Cycles</b>: <code>%d</code>`,
+<b>GC Cycles</b>: <code>%d</code>
+
+%s`,
 		runtime.NumGoroutine(),
 		heapAlloc,
 		heapSys,
 		heapInuse,
 		stackInuse,
 		numGC,
+		janitorStatsText(),
 	)
 
 	m.Reply(resp)
@@ -989,6 +992,10 @@ func init() {
 - <code>/mediainfo</code> - Get media information of a replied media
 - <code>/ls [directory]</code> - List files in a directory
 - <code>/go</code> - Get Go runtime stats
+- <code>/dbstats</code> - Database size, free pages and per-bucket counts
+- <code>/dbcompact</code> - Compact the database file in place
+- <code>/backup</code> - Upload a database backup now
+- <code>/restoredb confirm</code> - Restore the database from a replied backup file
 - <code>/gensession</code> - Generate a new string session
 - <code>/setpfp</code> - Set bot profile picture
 - <code>/spectrogram</code> - Generate spectrogram of an audio file
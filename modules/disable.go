@@ -0,0 +1,358 @@
+package modules
+
+import (
+	"fmt"
+	"main/modules/db"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+// disableSuggestions are offered as toggles in /disabled even while enabled.
+var disableSuggestions = []string{"ud", "tr", "paste", "kang", "q", "math", "doge", "timer"}
+
+// notDisableable can't be disabled, or the chat could lock itself out.
+var notDisableable = []string{"disable", "enable", "disabled"}
+
+var commandNameRe = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// disabledCache holds each chat's disabled commands so the middleware doesn't
+// hit the database for every command.
+type disabledCache struct {
+	mu    sync.RWMutex
+	chats map[int64]*db.DisabledCommands
+}
+
+var disabledCommands = &disabledCache{chats: make(map[int64]*db.DisabledCommands)}
+
+func (dc *disabledCache) get(chatID int64) *db.DisabledCommands {
+	dc.mu.RLock()
+	disabled, ok := dc.chats[chatID]
+	dc.mu.RUnlock()
+	if ok {
+		return disabled
+	}
+
+	disabled, err := db.GetDisabledCommands(chatID)
+	if err != nil {
+		return &db.DisabledCommands{}
+	}
+
+	dc.mu.Lock()
+	dc.chats[chatID] = disabled
+	dc.mu.Unlock()
+	return disabled
+}
+
+// update applies fn to a copy of the chat's settings and saves it.
+func (dc *disabledCache) update(chatID int64, fn func(*db.DisabledCommands)) (*db.DisabledCommands, error) {
+	current := dc.get(chatID)
+	disabled := &db.DisabledCommands{Commands: slices.Clone(current.Commands), Delete: current.Delete}
+	fn(disabled)
+	if err := db.SetDisabledCommands(chatID, disabled); err != nil {
+		return nil, err
+	}
+
+	dc.mu.Lock()
+	dc.chats[chatID] = disabled
+	dc.mu.Unlock()
+	return disabled, nil
+}
+
+func (dc *disabledCache) reset() {
+	dc.mu.Lock()
+	clear(dc.chats)
+	dc.mu.Unlock()
+}
+
+// commandName returns the lowercased command m invokes, or "" when the text
+// isn't a command or is addressed to another bot.
+func commandName(m *tg.NewMessage) string {
+	text := m.Text()
+	if len(text) < 2 || !strings.ContainsRune(commandPrefixes, rune(text[0])) {
+		return ""
+	}
+	name, _, _ := strings.Cut(text[1:], " ")
+	name, target, addressed := strings.Cut(name, "@")
+	if addressed && !strings.EqualFold(target, m.Client.Me().Username) {
+		return ""
+	}
+	return strings.ToLower(name)
+}
+
+// disableExempt are the OnNewMessage watchers. They match every message, so
+// a disabled command must not stop them or it would slip past blacklists,
+// filters and flood control.
+var disableExempt = map[uintptr]bool{}
+
+func exemptFromDisabling(handlers ...tg.MessageHandler) {
+	for _, h := range handlers {
+		disableExempt[reflect.ValueOf(h).Pointer()] = true
+	}
+}
+
+// disabledCommandsMiddleware runs before every message handler and drops
+// commands disabled in the chat. Admins can still use them.
+func disabledCommandsMiddleware(next tg.MessageHandler) tg.MessageHandler {
+	if disableExempt[reflect.ValueOf(next).Pointer()] {
+		return next
+	}
+	return func(m *tg.NewMessage) error {
+		if m.IsPrivate() {
+			return next(m)
+		}
+		name := commandName(m)
+		if name == "" {
+			return next(m)
+		}
+		disabled := disabledCommands.get(m.ChatID())
+		if !disabled.IsDisabled(name) || IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "") {
+			return next(m)
+		}
+		if disabled.Delete && CanBot(m.Client, m.Channel, "delete") {
+			m.Delete()
+		}
+		return nil
+	}
+}
+
+// parseCommandNames normalises "/ud paste@bot" style arguments and splits
+// out the names that can't be disabled.
+func parseCommandNames(args string) (names, invalid []string) {
+	for _, arg := range strings.Fields(strings.ToLower(args)) {
+		arg = strings.TrimLeft(arg, commandPrefixes)
+		arg, _, _ = strings.Cut(arg, "@")
+		if !commandNameRe.MatchString(arg) || slices.Contains(notDisableable, arg) {
+			invalid = append(invalid, arg)
+			continue
+		}
+		names = append(names, arg)
+	}
+	return names, invalid
+}
+
+// DisableHandler - /disable <command>..., or /disable delete|ignore
+func DisableHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Commands can only be disabled in groups")
+		return nil
+	}
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info permission to disable commands")
+		return nil
+	}
+
+	args := strings.ToLower(strings.TrimSpace(m.Args()))
+	if args == "" {
+		m.Reply("Usage: /disable <command> [command...]\nOr /disable delete|ignore to choose what happens to disabled commands")
+		return nil
+	}
+
+	if args == "delete" || args == "ignore" {
+		if _, err := disabledCommands.update(m.ChatID(), func(d *db.DisabledCommands) {
+			d.Delete = args == "delete"
+		}); err != nil {
+			m.Reply("Failed to update disabled commands")
+			return nil
+		}
+		if args == "delete" {
+			m.Reply("Messages using a disabled command will now be <b>deleted</b>")
+		} else {
+			m.Reply("Messages using a disabled command will now be <b>ignored</b>")
+		}
+		return nil
+	}
+
+	names, invalid := parseCommandNames(args)
+	if len(names) == 0 {
+		m.Reply(fmt.Sprintf("Can't disable: %s", strings.Join(invalid, ", ")))
+		return nil
+	}
+
+	if _, err := disabledCommands.update(m.ChatID(), func(d *db.DisabledCommands) {
+		d.Commands = append(d.Commands, names...)
+	}); err != nil {
+		m.Reply("Failed to update disabled commands")
+		return nil
+	}
+
+	text := fmt.Sprintf("Disabled: <code>%s</code>", strings.Join(names, "</code>, <code>"))
+	if len(invalid) > 0 {
+		text += fmt.Sprintf("\nSkipped: %s", strings.Join(invalid, ", "))
+	}
+	m.Reply(text)
+	return nil
+}
+
+// EnableHandler - /enable <command>..., or /enable all
+func EnableHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Commands can only be enabled in groups")
+		return nil
+	}
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info permission to enable commands")
+		return nil
+	}
+
+	args := strings.ToLower(strings.TrimSpace(m.Args()))
+	if args == "" {
+		m.Reply("Usage: /enable <command> [command...] or /enable all")
+		return nil
+	}
+
+	if args == "all" {
+		if _, err := disabledCommands.update(m.ChatID(), func(d *db.DisabledCommands) {
+			d.Commands = nil
+		}); err != nil {
+			m.Reply("Failed to update disabled commands")
+			return nil
+		}
+		m.Reply("All commands are enabled again")
+		return nil
+	}
+
+	names, _ := parseCommandNames(args)
+	var enabled []string
+	if _, err := disabledCommands.update(m.ChatID(), func(d *db.DisabledCommands) {
+		d.Commands = slices.DeleteFunc(d.Commands, func(cmd string) bool {
+			if slices.Contains(names, cmd) {
+				enabled = append(enabled, cmd)
+				return true
+			}
+			return false
+		})
+	}); err != nil {
+		m.Reply("Failed to update disabled commands")
+		return nil
+	}
+
+	if len(enabled) == 0 {
+		m.Reply("None of those commands are disabled here")
+		return nil
+	}
+	m.Reply(fmt.Sprintf("Enabled: <code>%s</code>", strings.Join(enabled, "</code>, <code>")))
+	return nil
+}
+
+func renderDisabled(disabled *db.DisabledCommands) (string, tg.ReplyMarkup) {
+	var text strings.Builder
+	text.WriteString("<b>Disabled Commands</b>\n\n")
+	if len(disabled.Commands) == 0 {
+		text.WriteString("No commands are disabled in this chat.\n")
+	} else {
+		for _, cmd := range disabled.Commands {
+			fmt.Fprintf(&text, " - <code>/%s</code>\n", cmd)
+		}
+	}
+	mode := "ignored"
+	if disabled.Delete {
+		mode = "deleted"
+	}
+	fmt.Fprintf(&text, "\nDisabled commands are <b>%s</b>. Admins can still use them.\nTap a command to toggle it.", mode)
+
+	commands := slices.Clone(disabled.Commands)
+	for _, cmd := range disableSuggestions {
+		if !slices.Contains(commands, cmd) {
+			commands = append(commands, cmd)
+		}
+	}
+
+	b := tg.Button
+	var buttons []tg.KeyboardButton
+	for _, cmd := range commands {
+		label := "✅ " + cmd
+		if disabled.IsDisabled(cmd) {
+			label = "🚫 " + cmd
+		}
+		buttons = append(buttons, b.Data(label, "distoggle_"+cmd))
+	}
+
+	modeLabel := "Mode: Ignore"
+	if disabled.Delete {
+		modeLabel = "Mode: Delete"
+	}
+	kb := tg.NewKeyboard().NewColumn(3, buttons...)
+	kb.AddRow(b.Data(modeLabel, "dismode_"))
+	return text.String(), kb.Build()
+}
+
+// DisabledHandler - /disabled, lists disabled commands with toggle buttons
+func DisabledHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Commands can only be disabled in groups")
+		return nil
+	}
+
+	text, kb := renderDisabled(disabledCommands.get(m.ChatID()))
+	m.Reply(text, &tg.SendOptions{ReplyMarkup: kb})
+	return nil
+}
+
+// DisabledToggleCallback handles distoggle_<command> and dismode_.
+func DisabledToggleCallback(c *tg.CallbackQuery) error {
+	if !IsUserAdmin(c.Client, c.SenderID, c.ChatID, "change_info") {
+		c.Answer("You need Change Info permission to do this", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	data := c.DataString()
+	disabled, err := disabledCommands.update(c.ChatID, func(d *db.DisabledCommands) {
+		if cmd, ok := strings.CutPrefix(data, "distoggle_"); ok {
+			if d.IsDisabled(cmd) {
+				d.Commands = slices.DeleteFunc(d.Commands, func(s string) bool { return s == cmd })
+			} else if commandNameRe.MatchString(cmd) && !slices.Contains(notDisableable, cmd) {
+				d.Commands = append(d.Commands, cmd)
+			}
+		} else {
+			d.Delete = !d.Delete
+		}
+	})
+	if err != nil {
+		c.Answer("Failed to update disabled commands", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	text, kb := renderDisabled(disabled)
+	c.Edit(text, &tg.SendOptions{ReplyMarkup: kb})
+	c.Answer("")
+	return nil
+}
+
+func registerDisableHandlers() {
+	c := Client
+	c.On("cmd:disable", DisableHandler)
+	c.On("cmd:enable", EnableHandler)
+	c.On("cmd:disabled", DisabledHandler)
+	c.On("callback:distoggle_", DisabledToggleCallback)
+	c.On("callback:dismode_", DisabledToggleCallback)
+
+	exemptFromDisabling(AFKHandler, SedHandler, BlacklistWatcher, FedBanWatcher, FilterWatcher, FloodWatcher, LockWatcher)
+	c.Use(disabledCommandsMiddleware)
+}
+
+func init() {
+	QueueHandlerRegistration(registerDisableHandlers)
+	db.OnRestore(disabledCommands.reset)
+
+	Mods.AddModule("Disabling", `<b>Disabling Commands</b>
+
+Turn off commands that don't fit the chat, like /ud or /kang in a serious group.
+
+<b>Commands:</b>
+/disabled - List disabled commands with toggle buttons
+/disable <command> [command...] - Disable one or more commands
+/enable <command> [command...] - Enable them again
+/enable all - Enable every command
+/disable delete - Delete messages using a disabled command
+/disable ignore - Just ignore them (default)
+
+<b>Example:</b>
+<code>/disable ud paste kang</code>
+
+<b>Note:</b> Admins can still use disabled commands.`)
+}
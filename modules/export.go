@@ -0,0 +1,455 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"main/modules/db"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+const (
+	importModeMerge   = "merge"
+	importModeReplace = "replace"
+
+	maxImportSize = 5 * 1024 * 1024
+)
+
+// ExportHandler - /export, sends the chat's settings as a JSON document
+func ExportHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Export only works in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info rights to export chat settings")
+		return nil
+	}
+
+	exp, err := db.ExportChat(m.ChatID())
+	if err != nil {
+		m.Reply("Failed to export chat settings: " + err.Error())
+		return nil
+	}
+	if m.Channel != nil {
+		exp.ChatTitle = m.Channel.Title
+	}
+
+	data, err := json.MarshalIndent(exp, "", "  ")
+	if err != nil {
+		m.Reply("Failed to encode export: " + err.Error())
+		return nil
+	}
+
+	_, err = m.ReplyMedia(data, &tg.MediaOptions{
+		FileName:      fmt.Sprintf("export_%d.json", m.ChatID()),
+		ForceDocument: true,
+		Caption: fmt.Sprintf("<b>Chat export</b> (v%d)\n%d notes, %d filters, %d blacklist entries\n\nReply /import to this file in another group to copy it over.",
+			exp.Version, len(exp.Notes), len(exp.Filters), len(exp.Blacklist)),
+	})
+	if err != nil {
+		m.Reply("Failed to send export: " + err.Error())
+	}
+	return nil
+}
+
+// parseImportModes reads "/import [merge|replace] [section[:mode]...]" into a
+// mode per section. With no sections listed every section is imported.
+func parseImportModes(args string) (map[string]string, error) {
+	defaultMode := importModeMerge
+	modes := make(map[string]string)
+	var listed []string
+
+	for _, arg := range strings.Fields(strings.ToLower(args)) {
+		if arg == importModeMerge || arg == importModeReplace {
+			defaultMode = arg
+			continue
+		}
+		section, mode, hasMode := strings.Cut(arg, ":")
+		if !slices.Contains(db.ExportSections, section) {
+			return nil, fmt.Errorf("unknown section %q", section)
+		}
+		if hasMode {
+			if mode != importModeMerge && mode != importModeReplace {
+				return nil, fmt.Errorf("unknown mode %q for %s", mode, section)
+			}
+			modes[section] = mode
+		}
+		listed = append(listed, section)
+	}
+
+	if len(listed) == 0 {
+		listed = db.ExportSections
+	}
+	result := make(map[string]string, len(listed))
+	for _, section := range listed {
+		if mode, ok := modes[section]; ok {
+			result[section] = mode
+		} else {
+			result[section] = defaultMode
+		}
+	}
+	return result, nil
+}
+
+// ImportHandler - /import [merge|replace] [sections], as a reply to an export file
+func ImportHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Import only works in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info rights to import chat settings")
+		return nil
+	}
+
+	usage := fmt.Sprintf(`<b>Usage:</b> reply to an /export file with
+<code>/import [merge|replace] [section[:mode] ...]</code>
+
+<b>Sections:</b> %s
+
+<b>merge</b> (default) adds to what's already here; <b>replace</b> clears the section first.
+Example: <code>/import notes:replace filters</code>`, strings.Join(db.ExportSections, ", "))
+
+	if !m.IsReply() {
+		m.Reply(usage)
+		return nil
+	}
+
+	reply, err := m.GetReplyMessage()
+	if err != nil || reply.Document() == nil || reply.File == nil {
+		m.Reply(usage)
+		return nil
+	}
+	if reply.File.Size > maxImportSize {
+		m.Reply("That file is too large to be a chat export")
+		return nil
+	}
+
+	modes, err := parseImportModes(m.Args())
+	if err != nil {
+		m.Reply("<b>Error:</b> " + err.Error() + "\n\n" + usage)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := reply.Download(&tg.DownloadOptions{Buffer: &buf}); err != nil {
+		m.Reply("Failed to download file: " + err.Error())
+		return nil
+	}
+
+	var exp db.ChatExport
+	if err := json.Unmarshal(buf.Bytes(), &exp); err != nil {
+		m.Reply("That file isn't a valid chat export")
+		return nil
+	}
+	switch {
+	case exp.Version == 0:
+		m.Reply("That file isn't a chat export (no schema version)")
+		return nil
+	case exp.Version > db.ChatExportVersion:
+		m.Reply(fmt.Sprintf("That export is version %d, but I only understand up to version %d", exp.Version, db.ChatExportVersion))
+		return nil
+	}
+
+	summary := applyChatImport(m.ChatID(), &exp, modes)
+
+	m.Reply("<b>Import complete</b>\n\n" + strings.Join(summary, "\n"))
+	return nil
+}
+
+// applyChatImport writes each selected section of exp into chatID and returns
+// one summary line per section.
+func applyChatImport(chatID int64, exp *db.ChatExport, modes map[string]string) []string {
+	var summary []string
+	report := func(section, format string, args ...any) {
+		summary = append(summary, fmt.Sprintf("<b>%s</b> (%s): ", section, modes[section])+fmt.Sprintf(format, args...))
+	}
+
+	for _, section := range db.ExportSections {
+		mode, ok := modes[section]
+		if !ok {
+			continue
+		}
+		replace := mode == importModeReplace
+
+		switch section {
+		case db.ExportSectionNotes:
+			existing, _ := db.GetAllNotes(chatID)
+			removed := 0
+			if replace && len(existing) > 0 {
+				db.DeleteAllNotes(chatID)
+				removed, existing = len(existing), nil
+			}
+			added, updated, failed := 0, 0, 0
+			for _, note := range exp.Notes {
+				if note == nil || note.Name == "" {
+					continue
+				}
+				if db.SaveNote(chatID, note) != nil {
+					failed++
+				} else if slices.ContainsFunc(existing, func(n *db.Note) bool { return n.Name == note.Name }) {
+					updated++
+				} else {
+					added++
+				}
+			}
+			report(section, "%d added, %d updated, %d removed%s", added, updated, removed, importFailures(failed))
+
+		case db.ExportSectionFilters:
+			existing, _ := db.GetAllFilters(chatID)
+			removed := 0
+			if replace && len(existing) > 0 {
+				db.DeleteAllFilters(chatID)
+				removed, existing = len(existing), nil
+			}
+			added, updated, failed := 0, 0, 0
+			for _, filter := range exp.Filters {
+				if filter == nil || filter.Keyword == "" {
+					continue
+				}
+				if validateImportedFilter(filter) != nil {
+					failed++
+					continue
+				}
+				if db.SaveFilter(chatID, filter) != nil {
+					failed++
+				} else if slices.ContainsFunc(existing, func(f *db.Filter) bool { return f.Keyword == filter.Keyword }) {
+					updated++
+				} else {
+					added++
+				}
+			}
+			report(section, "%d added, %d updated, %d removed%s", added, updated, removed, importFailures(failed))
+
+		case db.ExportSectionBlacklist:
+			existing, _ := db.GetBlacklist(chatID)
+			removed := 0
+			if replace && len(existing) > 0 {
+				db.ClearBlacklist(chatID)
+				removed, existing = len(existing), nil
+			}
+			added, updated, failed := 0, 0, 0
+			for _, entry := range exp.Blacklist {
+				if entry == nil || entry.Word == "" {
+					continue
+				}
+				if _, err := compileBlacklistEntry(entry); err != nil {
+					failed++
+					continue
+				}
+				if db.AddBlacklist(chatID, entry) != nil {
+					failed++
+				} else if slices.ContainsFunc(existing, func(e *db.BlacklistEntry) bool { return e.Word == entry.Word }) {
+					updated++
+				} else {
+					added++
+				}
+			}
+			report(section, "%d added, %d updated, %d removed%s", added, updated, removed, importFailures(failed))
+
+		case db.ExportSectionRules:
+			current, _ := db.GetRulesWithMedia(chatID)
+			hasCurrent := current != nil && (current.Content != "" || current.FileID != "")
+			switch {
+			case exp.Rules == nil && replace && hasCurrent:
+				db.DeleteRules(chatID)
+				report(section, "cleared")
+			case exp.Rules == nil:
+				report(section, "not in file")
+			case hasCurrent && !replace:
+				report(section, "kept existing")
+			case db.SetRulesWithMedia(chatID, exp.Rules) != nil:
+				report(section, "failed")
+			default:
+				report(section, "set")
+			}
+
+		case db.ExportSectionWelcome, db.ExportSectionGoodbye:
+			get, set, incoming := db.GetWelcome, db.SetWelcome, exp.Welcome
+			if section == db.ExportSectionGoodbye {
+				get, set, incoming = db.GetGoodbye, db.SetGoodbye, exp.Goodbye
+			}
+			current, _ := get(chatID)
+			hasCurrent := current != nil && (current.Content != "" || current.FileID != "")
+			switch {
+			case incoming == nil && replace && hasCurrent:
+				set(chatID, &db.WelcomeMessage{})
+				report(section, "cleared")
+			case incoming == nil:
+				report(section, "not in file")
+			case hasCurrent && !replace:
+				report(section, "kept existing")
+			case set(chatID, incoming) != nil:
+				report(section, "failed")
+			default:
+				report(section, "set")
+			}
+
+		// Settings always exist (with defaults), so merge and replace both
+		// take the imported values.
+		case db.ExportSectionBlacklistSettings:
+			report(section, "%s", importSetting(exp.BlacklistSettings != nil, func() error {
+				return validateBlacklistSettings(exp.BlacklistSettings)
+			}, func() error {
+				return db.SetBlacklistSettings(chatID, exp.BlacklistSettings)
+			}))
+		case db.ExportSectionWarnSettings:
+			report(section, "%s", importSetting(exp.WarnSettings != nil, func() error {
+				return validateWarnSettings(exp.WarnSettings)
+			}, func() error {
+				return db.SetWarnSettings(chatID, exp.WarnSettings)
+			}))
+		case db.ExportSectionFloodSettings:
+			report(section, "%s", importSetting(exp.FloodSettings != nil, func() error {
+				return validateFloodSettings(exp.FloodSettings)
+			}, func() error {
+				return floodSettings.set(chatID, exp.FloodSettings)
+			}))
+		case db.ExportSectionCaptchaSettings:
+			report(section, "%s", importSetting(exp.CaptchaSettings != nil, func() error {
+				return validateCaptchaSettings(exp.CaptchaSettings)
+			}, func() error {
+				return db.SetCaptchaSettings(chatID, exp.CaptchaSettings)
+			}))
+		}
+	}
+
+	return summary
+}
+
+func importSetting(present bool, validate, save func() error) string {
+	if !present {
+		return "not in file"
+	}
+	if err := validate(); err != nil {
+		return "rejected: " + err.Error()
+	}
+	if err := save(); err != nil {
+		return "failed"
+	}
+	return "set"
+}
+
+// The validators below apply the same limits as the commands that normally
+// set these values, so a hand-edited file can't store what they would refuse.
+
+func validateImportedFilter(filter *db.Filter) error {
+	switch filter.Mode {
+	case "", db.FilterModeWord, db.FilterModeExact, db.FilterModePrefix:
+	case db.FilterModeRegex:
+		for _, trigger := range filter.Triggers {
+			if _, err := regexp.Compile("(?i)" + trigger); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown filter mode %q", filter.Mode)
+	}
+	return nil
+}
+
+func validateBlacklistSettings(settings *db.BlacklistSettings) error {
+	if _, _, errMsg := parseBlacklistAction([]string{string(settings.Action), settings.Duration}, "/setblaction"); errMsg != "" {
+		return fmt.Errorf("invalid action %q", settings.Action)
+	}
+	return nil
+}
+
+func validateWarnSettings(settings *db.WarnSettings) error {
+	if settings.MaxWarns < 1 || settings.MaxWarns > 20 {
+		return fmt.Errorf("warn limit must be between 1 and 20")
+	}
+	switch settings.Action {
+	case db.WarnActionBan, db.WarnActionMute, db.WarnActionKick:
+	default:
+		return fmt.Errorf("invalid action %q", settings.Action)
+	}
+	if settings.DecayDays < 0 || settings.DecayDays > 365 {
+		return fmt.Errorf("decay must be between 0 and 365 days")
+	}
+	return nil
+}
+
+func validateFloodSettings(settings *db.FloodSettings) error {
+	if settings.Limit == 0 {
+		// Disabled; the rest is only used once a limit is set again.
+		return nil
+	}
+	if settings.Limit < 2 || settings.Limit > 100 {
+		return fmt.Errorf("flood limit must be between 2 and 100")
+	}
+	if window := time.Duration(settings.WindowSec) * time.Second; window < time.Second || window > maxFloodWindow {
+		return fmt.Errorf("flood window must be between 1s and %s", formatDuration(maxFloodWindow))
+	}
+	switch settings.Action {
+	case db.FloodActionBan, db.FloodActionMute, db.FloodActionKick:
+	case db.FloodActionTBan, db.FloodActionTMute:
+		if d, err := parseAdminDuration(settings.Duration); err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %q", settings.Duration)
+		}
+	default:
+		return fmt.Errorf("invalid action %q", settings.Action)
+	}
+	return nil
+}
+
+func validateCaptchaSettings(settings *db.CaptchaSettings) error {
+	switch settings.Mode {
+	case db.CaptchaModeButton, db.CaptchaModeMath, db.CaptchaModeText:
+	default:
+		return fmt.Errorf("invalid mode %q", settings.Mode)
+	}
+	if timeout := time.Duration(settings.TimeoutSec) * time.Second; timeout < 30*time.Second || timeout > 24*time.Hour {
+		return fmt.Errorf("timeout must be between 30 seconds and 24 hours")
+	}
+	switch settings.FailAction {
+	case db.CaptchaFailKick, db.CaptchaFailBan:
+	default:
+		return fmt.Errorf("invalid fail action %q", settings.FailAction)
+	}
+	return nil
+}
+
+func importFailures(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %d failed", n)
+}
+
+func registerExportHandlers() {
+	c := Client
+	c.On("cmd:export", ExportHandler)
+	c.On("cmd:import", ImportHandler)
+}
+
+func init() {
+	QueueHandlerRegistration(registerExportHandlers)
+
+	Mods.AddModule("Export", `<b>Export & Import</b>
+
+Back up a group's configuration or copy it to another group.
+
+<b>Commands:</b>
+/export - Send this chat's settings as a JSON file
+/import [merge|replace] [sections] - Reply to an export file to load it
+
+<b>Sections:</b>
+notes, filters, rules, welcome, goodbye, blacklist, blacklist_settings, warn_settings, flood_settings, captcha_settings
+
+<b>Modes:</b>
+ - merge - Add to what's here, keep existing rules/welcome (default)
+ - replace - Clear the section first
+
+Set a mode per section with <code>section:mode</code>, e.g. <code>/import notes:replace filters</code>.
+
+<b>Note:</b> Media in notes and filters is referenced by file ID, which only works with this bot.`)
+}
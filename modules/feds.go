@@ -0,0 +1,548 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"main/modules/db"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+// fedSeenTTL is how long a user stays in fedSeenUsers before their next
+// message is checked against the federation again.
+const fedSeenTTL = 6 * time.Hour
+
+// fedSeenUsers maps "chatID:userID" pairs already checked against the chat's
+// federation to when they were checked, so the ban lookup only runs on a
+// user's first message. Entries expire after fedSeenTTL.
+var fedSeenUsers sync.Map
+
+func isChatCreator(client *tg.Client, chatID, userID int64) bool {
+	member, err := client.GetChatMember(chatID, userID)
+	return err == nil && member.Status == tg.Creator
+}
+
+// contextFed returns the chat's federation in groups, or the sender's own
+// federation in PM.
+func contextFed(m *tg.NewMessage) (*db.Federation, error) {
+	if m.IsPrivate() {
+		return db.GetFedByOwner(m.SenderID())
+	}
+	return db.GetChatFed(m.ChatID())
+}
+
+// enforceFedBan bans userID from chatID if they are banned in the chat's
+// federation and reports whether a ban was found.
+func enforceFedBan(client *tg.Client, chatID, userID int64) bool {
+	fed, err := db.GetChatFed(chatID)
+	if err != nil || fed == nil {
+		return false
+	}
+
+	ban, err := db.GetFedBan(fed.ID, userID)
+	if err != nil || ban == nil {
+		return false
+	}
+
+	user, err := client.ResolvePeer(userID)
+	if err != nil {
+		return true
+	}
+
+	if _, err := client.EditBanned(chatID, user, &tg.BannedOptions{Ban: true}); err != nil {
+		return true
+	}
+
+	text := fmt.Sprintf("%s is banned in the federation <b>%s</b> and has been removed.", html.EscapeString(GetPeerDisplayName(client, user)), html.EscapeString(fed.Name))
+	if ban.Reason != "" {
+		text += "\n<b>Reason:</b> " + html.EscapeString(ban.Reason)
+	}
+	client.SendMessage(chatID, text)
+	return true
+}
+
+// FedBanWatcher enforces fed bans on the first message seen from each user.
+func FedBanWatcher(m *tg.NewMessage) error {
+	if m.IsPrivate() || m.SenderID() == 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf("%d:%d", m.ChatID(), m.SenderID())
+	if seenAt, seen := fedSeenUsers.Load(key); seen && time.Since(seenAt.(time.Time)) < fedSeenTTL {
+		return nil
+	}
+	fedSeenUsers.Store(key, time.Now())
+
+	if enforceFedBan(m.Client, m.ChatID(), m.SenderID()) {
+		m.Delete()
+	}
+	return nil
+}
+
+// pruneFedSeenUsers drops expired fedSeenUsers entries every fedSeenTTL, so
+// the map doesn't grow with every user ever seen.
+func pruneFedSeenUsers() {
+	for range time.Tick(fedSeenTTL) {
+		fedSeenUsers.Range(func(k, seenAt any) bool {
+			if time.Since(seenAt.(time.Time)) >= fedSeenTTL {
+				fedSeenUsers.Delete(k)
+			}
+			return true
+		})
+	}
+}
+
+func forgetFedSeenChat(chatID int64) {
+	prefix := fmt.Sprintf("%d:", chatID)
+	fedSeenUsers.Range(func(k, _ any) bool {
+		if strings.HasPrefix(k.(string), prefix) {
+			fedSeenUsers.Delete(k)
+		}
+		return true
+	})
+}
+
+// NewFedHandler - /newfed <name>
+func NewFedHandler(m *tg.NewMessage) error {
+	name := strings.TrimSpace(m.Args())
+	if name == "" {
+		m.Reply("Usage: /newfed <name>")
+		return nil
+	}
+
+	fed, err := db.CreateFed(m.SenderID(), name)
+	if errors.Is(err, db.ErrAlreadyOwnFed) {
+		m.Reply("You already own a federation. Use /fedinfo to view it.")
+		return nil
+	} else if err != nil {
+		m.Reply("Failed to create federation")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf(`<b>Federation created</b>
+
+<b>Name:</b> %s
+<b>ID:</b> <code>%s</code>
+
+Use <code>/joinfed %s</code> in your groups to connect them.`, html.EscapeString(fed.Name), fed.ID, fed.ID))
+	return nil
+}
+
+// JoinFedHandler - /joinfed <fed id>
+func JoinFedHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Run /joinfed in the group you want to connect")
+		return nil
+	}
+
+	if !isChatCreator(m.Client, m.ChatID(), m.SenderID()) {
+		m.Reply("Only the group creator can join a federation")
+		return nil
+	}
+
+	fedID := strings.TrimSpace(m.Args())
+	if fedID == "" {
+		m.Reply("Usage: /joinfed <fed id>")
+		return nil
+	}
+
+	if current, _ := db.GetChatFed(m.ChatID()); current != nil {
+		m.Reply(fmt.Sprintf("This chat is already in <b>%s</b>. Use /leavefed first.", html.EscapeString(current.Name)))
+		return nil
+	}
+
+	if err := db.JoinFed(m.ChatID(), fedID); errors.Is(err, db.ErrFedNotFound) {
+		m.Reply("No federation found with that ID")
+		return nil
+	} else if err != nil {
+		m.Reply("Failed to join federation")
+		return nil
+	}
+	forgetFedSeenChat(m.ChatID())
+
+	fed, _ := db.GetFed(fedID)
+	m.Reply(fmt.Sprintf("This chat is now part of the federation <b>%s</b>", html.EscapeString(fed.Name)))
+	return nil
+}
+
+// LeaveFedHandler - /leavefed
+func LeaveFedHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Run /leavefed in the group you want to disconnect")
+		return nil
+	}
+
+	if !isChatCreator(m.Client, m.ChatID(), m.SenderID()) {
+		m.Reply("Only the group creator can leave a federation")
+		return nil
+	}
+
+	fed, _ := db.GetChatFed(m.ChatID())
+	if fed == nil {
+		m.Reply("This chat is not in any federation")
+		return nil
+	}
+
+	if err := db.LeaveFed(m.ChatID()); err != nil {
+		m.Reply("Failed to leave federation")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("This chat has left the federation <b>%s</b>", html.EscapeString(fed.Name)))
+	return nil
+}
+
+// FedBanHandler - /fedban <user> [reason]
+func FedBanHandler(m *tg.NewMessage) error {
+	fed, err := contextFed(m)
+	if err != nil || fed == nil {
+		m.Reply("This chat is not in any federation")
+		return nil
+	}
+
+	if !fed.IsAdmin(m.SenderID()) {
+		m.Reply("Only federation admins can fed ban")
+		return nil
+	}
+
+	user, reason, err := GetUserFromContext(m)
+	if err != nil {
+		m.Reply("Usage: /fedban <user> [reason] or reply to a message")
+		return nil
+	}
+
+	userID := m.Client.GetPeerID(user)
+	if fed.IsAdmin(userID) {
+		m.Reply("Federation admins can't be fed banned")
+		return nil
+	}
+	if userID == m.Client.Me().ID {
+		m.Reply("I'm not banning myself")
+		return nil
+	}
+
+	if err := db.AddFedBan(fed.ID, &db.FedBan{
+		UserID:    userID,
+		Reason:    reason,
+		BannedBy:  m.SenderID(),
+		Timestamp: time.Now(),
+	}); err != nil {
+		m.Reply("Failed to save fed ban")
+		return nil
+	}
+
+	chats, _ := db.GetFedChats(fed.ID)
+	status, _ := m.Reply(fmt.Sprintf("Enforcing fed ban in %d chats...", len(chats)))
+
+	link := ""
+	if !m.IsPrivate() {
+		link = fmt.Sprintf("https://t.me/c/%d/%d", m.ChatID(), m.ID)
+	}
+
+	done := 0
+	for _, chatID := range chats {
+		if ok, err := m.Client.EditBanned(chatID, user, &tg.BannedOptions{Ban: true}); err != nil || !ok {
+			continue
+		}
+		done++
+		RecordAction(chatID, userID, m.SenderID(), "fban", map[string]interface{}{"reason": reason, "fed": fed.ID})
+		go sendLogEvent(m.Client, &LogEvent{
+			Category: LogCategoryBans,
+			Action:   "fedban",
+			ChatID:   chatID,
+			ActorID:  m.SenderID(),
+			TargetID: userID,
+			Reason:   reason,
+			Details:  fmt.Sprintf("Federation: %s (%s)", fed.Name, fed.ID),
+			Link:     link,
+		})
+	}
+
+	text := fmt.Sprintf("<b>New fed ban</b>\n<b>Federation:</b> %s\n<b>User:</b> %s\n<b>Enforced in:</b> %d/%d chats",
+		html.EscapeString(fed.Name), html.EscapeString(GetPeerDisplayName(m.Client, user)), done, len(chats))
+	if reason != "" {
+		text += "\n<b>Reason:</b> " + html.EscapeString(reason)
+	}
+
+	if status != nil {
+		status.Edit(text)
+	} else {
+		m.Reply(text)
+	}
+	return nil
+}
+
+// UnFedBanHandler - /unfedban <user>
+func UnFedBanHandler(m *tg.NewMessage) error {
+	fed, err := contextFed(m)
+	if err != nil || fed == nil {
+		m.Reply("This chat is not in any federation")
+		return nil
+	}
+
+	if !fed.IsAdmin(m.SenderID()) {
+		m.Reply("Only federation admins can remove fed bans")
+		return nil
+	}
+
+	user, _, err := GetUserFromContext(m)
+	if err != nil {
+		m.Reply("Usage: /unfedban <user> or reply to a message")
+		return nil
+	}
+
+	userID := m.Client.GetPeerID(user)
+	if ban, _ := db.GetFedBan(fed.ID, userID); ban == nil {
+		m.Reply("That user isn't banned in this federation")
+		return nil
+	}
+
+	if err := db.RemoveFedBan(fed.ID, userID); err != nil {
+		m.Reply("Failed to remove fed ban")
+		return nil
+	}
+
+	link := ""
+	if !m.IsPrivate() {
+		link = fmt.Sprintf("https://t.me/c/%d/%d", m.ChatID(), m.ID)
+	}
+
+	chats, _ := db.GetFedChats(fed.ID)
+	done := 0
+	for _, chatID := range chats {
+		if ok, err := m.Client.EditBanned(chatID, user, &tg.BannedOptions{Unban: true}); err != nil || !ok {
+			continue
+		}
+		done++
+		RecordAction(chatID, userID, m.SenderID(), "unfban", map[string]interface{}{"fed": fed.ID})
+		go sendLogEvent(m.Client, &LogEvent{
+			Category: LogCategoryBans,
+			Action:   "unfedban",
+			ChatID:   chatID,
+			ActorID:  m.SenderID(),
+			TargetID: userID,
+			Details:  fmt.Sprintf("Federation: %s (%s)", fed.Name, fed.ID),
+			Link:     link,
+		})
+	}
+
+	m.Reply(fmt.Sprintf("%s is no longer banned in <b>%s</b> (lifted in %d/%d chats)",
+		html.EscapeString(GetPeerDisplayName(m.Client, user)), html.EscapeString(fed.Name), done, len(chats)))
+	return nil
+}
+
+// FedPromoteHandler - /fedpromote <user>
+func FedPromoteHandler(m *tg.NewMessage) error {
+	fed, _ := db.GetFedByOwner(m.SenderID())
+	if fed == nil {
+		m.Reply("Only federation owners can promote fed admins")
+		return nil
+	}
+
+	user, _, err := GetUserFromContext(m)
+	if err != nil {
+		m.Reply("Usage: /fedpromote <user> or reply to a message")
+		return nil
+	}
+
+	userID := m.Client.GetPeerID(user)
+	if fed.IsAdmin(userID) {
+		m.Reply("That user is already a federation admin")
+		return nil
+	}
+
+	if err := db.AddFedAdmin(fed.ID, userID); err != nil {
+		m.Reply("Failed to promote fed admin")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("%s is now an admin of <b>%s</b>", html.EscapeString(GetPeerDisplayName(m.Client, user)), html.EscapeString(fed.Name)))
+	return nil
+}
+
+// FedDemoteHandler - /feddemote <user>
+func FedDemoteHandler(m *tg.NewMessage) error {
+	fed, _ := db.GetFedByOwner(m.SenderID())
+	if fed == nil {
+		m.Reply("Only federation owners can demote fed admins")
+		return nil
+	}
+
+	user, _, err := GetUserFromContext(m)
+	if err != nil {
+		m.Reply("Usage: /feddemote <user> or reply to a message")
+		return nil
+	}
+
+	userID := m.Client.GetPeerID(user)
+	if userID == fed.OwnerID || !fed.IsAdmin(userID) {
+		m.Reply("That user is not a federation admin")
+		return nil
+	}
+
+	if err := db.RemoveFedAdmin(fed.ID, userID); err != nil {
+		m.Reply("Failed to demote fed admin")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("%s is no longer an admin of <b>%s</b>", html.EscapeString(GetPeerDisplayName(m.Client, user)), html.EscapeString(fed.Name)))
+	return nil
+}
+
+// fedFromArgs resolves the federation named in the command arguments,
+// falling back to the current context.
+func fedFromArgs(m *tg.NewMessage) (*db.Federation, error) {
+	if fedID := strings.TrimSpace(m.Args()); fedID != "" {
+		return db.GetFed(fedID)
+	}
+	return contextFed(m)
+}
+
+// FedAdminsHandler - /fedadmins [fed id]
+func FedAdminsHandler(m *tg.NewMessage) error {
+	fed, err := fedFromArgs(m)
+	if err != nil || fed == nil {
+		m.Reply("No federation found. Use /fedadmins <fed id> or run it in a federated chat.")
+		return nil
+	}
+
+	var resp strings.Builder
+	fmt.Fprintf(&resp, "<b>Admins of %s</b>\n\n", html.EscapeString(fed.Name))
+	fmt.Fprintf(&resp, "• %s (owner)\n", logUserMention(m.Client, fed.OwnerID))
+	for _, adminID := range fed.Admins {
+		fmt.Fprintf(&resp, "• %s\n", logUserMention(m.Client, adminID))
+	}
+
+	m.Reply(resp.String())
+	return nil
+}
+
+// FedInfoHandler - /fedinfo [fed id]
+func FedInfoHandler(m *tg.NewMessage) error {
+	fed, err := fedFromArgs(m)
+	if err != nil || fed == nil {
+		m.Reply("No federation found. Use /fedinfo <fed id> or run it in a federated chat.")
+		return nil
+	}
+
+	chats, _ := db.GetFedChats(fed.ID)
+	bans, _ := db.GetFedBans(fed.ID)
+
+	m.Reply(fmt.Sprintf(`<b>Federation Info</b>
+
+<b>Name:</b> %s
+<b>ID:</b> <code>%s</code>
+<b>Owner:</b> %s
+<b>Admins:</b> %d
+<b>Chats:</b> %d
+<b>Bans:</b> %d
+<b>Created:</b> %s`,
+		html.EscapeString(fed.Name), fed.ID, logUserMention(m.Client, fed.OwnerID), len(fed.Admins),
+		len(chats), len(bans), fed.CreatedAt.Format("02 Jan 2006")))
+	return nil
+}
+
+// FedExportHandler - /fedexport, sends the ban list as CSV
+func FedExportHandler(m *tg.NewMessage) error {
+	fed, err := contextFed(m)
+	if err != nil || fed == nil {
+		m.Reply("This chat is not in any federation")
+		return nil
+	}
+
+	if !fed.IsAdmin(m.SenderID()) {
+		m.Reply("Only federation admins can export the ban list")
+		return nil
+	}
+
+	bans, err := db.GetFedBans(fed.ID)
+	if err != nil {
+		m.Reply("Failed to read fed bans")
+		return nil
+	}
+	if len(bans) == 0 {
+		m.Reply("This federation has no bans to export")
+		return nil
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"user_id", "banned_by", "timestamp", "reason"})
+	for _, ban := range bans {
+		w.Write([]string{
+			strconv.FormatInt(ban.UserID, 10),
+			strconv.FormatInt(ban.BannedBy, 10),
+			ban.Timestamp.UTC().Format(time.RFC3339),
+			ban.Reason,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		m.Reply("Failed to build export: " + err.Error())
+		return nil
+	}
+
+	_, err = m.ReplyMedia(buf.Bytes(), &tg.MediaOptions{
+		FileName:      fmt.Sprintf("fedbans_%s.csv", fed.ID),
+		ForceDocument: true,
+		Caption:       fmt.Sprintf("<b>%s</b>: %d bans", html.EscapeString(fed.Name), len(bans)),
+	})
+	if err != nil {
+		m.Reply("Failed to send export: " + err.Error())
+	}
+	return nil
+}
+
+func registerFedHandlers() {
+	c := Client
+	c.On("cmd:newfed", NewFedHandler)
+	c.On("cmd:joinfed", JoinFedHandler)
+	c.On("cmd:leavefed", LeaveFedHandler)
+	c.On("cmd:fedban", FedBanHandler)
+	c.On("cmd:fban", FedBanHandler)
+	c.On("cmd:unfedban", UnFedBanHandler)
+	c.On("cmd:unfban", UnFedBanHandler)
+	c.On("cmd:fedpromote", FedPromoteHandler)
+	c.On("cmd:feddemote", FedDemoteHandler)
+	c.On("cmd:fedadmins", FedAdminsHandler)
+	c.On("cmd:fedinfo", FedInfoHandler)
+	c.On("cmd:fedexport", FedExportHandler)
+	c.On(tg.OnNewMessage, FedBanWatcher)
+
+	go pruneFedSeenUsers()
+}
+
+func init() {
+	QueueHandlerRegistration(registerFedHandlers)
+
+	Mods.AddModule("Federations", `<b>Federations</b>
+
+Share one ban list across several groups.
+
+<b>Owner:</b>
+/newfed <name> - Create a federation
+/fedpromote [user] - Make someone a fed admin
+/feddemote [user] - Remove a fed admin
+
+<b>Group creator:</b>
+/joinfed <fed id> - Connect this group to a federation
+/leavefed - Disconnect this group
+
+<b>Fed admins:</b>
+/fedban [user] [reason] - Ban in every chat of the federation
+/unfedban [user] - Lift a fed ban
+/fedexport - Export the ban list as CSV
+
+<b>Anyone:</b>
+/fedinfo [fed id] - Federation details
+/fedadmins [fed id] - List federation admins
+
+Fed-banned users are removed when they join or first speak in any member chat.`)
+}
@@ -314,7 +314,7 @@ func registerFileHandlers() {
 	c.On("cmd:fid", GetFileIDHandle)
 	c.On("cmd:ul", UploadHandle, tg.CustomFilter(FilterOwnerNoReply))
 	c.On("cmd:ldl", DownloadHandle, tg.CustomFilter(FilterOwnerNoReply))
-	c.On("cmd:cancel", CancelDownloadHandle, tg.CustomFilter(FilterOwnerNoReply))
+	// /cancel is shared with timers; CancelHandler falls back to CancelDownloadHandle.
 	c.On("cmd:fileinfo", FileInfoHandle)
 	c.On("cmd:finfo", FileInfoHandle)
 }
@@ -2,14 +2,225 @@ package modules
 
 import (
 	"fmt"
+	"log"
 	"main/modules/db"
+	"math/rand"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 
 	tg "github.com/amarnathcjd/gogram/telegram"
 )
 
+// filterMatcher matches all of a chat's filters against a message. Word and
+// prefix triggers share one automaton, exact triggers are looked up by the
+// whole message, and regex triggers are checked one by one.
+type filterMatcher struct {
+	ac        *ahoCorasick
+	acFilters []int
+	acPrefix  []bool
+	exact     map[string][]int
+	regexes   []filterRegex
+	filters   []*db.Filter
+}
+
+type filterRegex struct {
+	re     *regexp.Regexp
+	filter int
+}
+
+func newFilterMatcher(filters []*db.Filter) *filterMatcher {
+	fm := &filterMatcher{exact: make(map[string][]int), filters: filters}
+
+	var keywords []string
+	for i, filter := range filters {
+		for _, trigger := range filter.Triggers {
+			switch filter.Mode {
+			case db.FilterModeRegex:
+				re, err := regexp.Compile("(?i)" + trigger)
+				if err != nil {
+					log.Printf("Skipping filter %q: %v", filter.Keyword, err)
+					continue
+				}
+				fm.regexes = append(fm.regexes, filterRegex{re: re, filter: i})
+			case db.FilterModeExact:
+				fm.exact[trigger] = append(fm.exact[trigger], i)
+			default:
+				keywords = append(keywords, trigger)
+				fm.acFilters = append(fm.acFilters, i)
+				fm.acPrefix = append(fm.acPrefix, filter.Mode == db.FilterModePrefix)
+			}
+		}
+	}
+	fm.ac = newAhoCorasick(keywords)
+
+	return fm
+}
+
+// matchAll returns every filter triggered by text, each once, in order of
+// where its first trigger appears.
+func (fm *filterMatcher) matchAll(text string) []*db.Filter {
+	type hit struct{ filter, pos int }
+	var hits []hit
+
+	lower := strings.TrimSpace(strings.ToLower(text))
+	for _, i := range fm.exact[lower] {
+		hits = append(hits, hit{i, 0})
+	}
+	for _, m := range fm.ac.findAll(lower) {
+		if fm.acPrefix[m.Pattern] {
+			if m.Start == 0 && isWordEdge(lower, m.End, false) {
+				hits = append(hits, hit{fm.acFilters[m.Pattern], 0})
+			}
+			continue
+		}
+		if isWordEdge(lower, m.Start, true) && isWordEdge(lower, m.End, false) {
+			hits = append(hits, hit{fm.acFilters[m.Pattern], m.Start})
+		}
+	}
+	for _, r := range fm.regexes {
+		if loc := r.re.FindStringIndex(text); loc != nil {
+			hits = append(hits, hit{r.filter, loc[0]})
+		}
+	}
+
+	sort.SliceStable(hits, func(a, b int) bool { return hits[a].pos < hits[b].pos })
+
+	var matched []*db.Filter
+	seen := make(map[int]bool)
+	for _, h := range hits {
+		if !seen[h.filter] {
+			seen[h.filter] = true
+			matched = append(matched, fm.filters[h.filter])
+		}
+	}
+	return matched
+}
+
+var filterModes = []string{db.FilterModeRegex, db.FilterModeExact, db.FilterModePrefix}
+
+// splitFilterKeyword takes the trigger off the front of /filter's arguments.
+// A trigger containing spaces must be wrapped in double quotes.
+func splitFilterKeyword(args string) (keyword, rest string, ok bool) {
+	args = strings.TrimSpace(args)
+	if quoted, found := strings.CutPrefix(args, `"`); found {
+		end := strings.Index(quoted, `"`)
+		if end < 0 {
+			return "", "", false
+		}
+		return quoted[:end], strings.TrimSpace(quoted[end+1:]), true
+	}
+	if end := strings.IndexAny(args, " \n\t"); end >= 0 {
+		return args[:end], strings.TrimSpace(args[end+1:]), true
+	}
+	return args, "", true
+}
+
+// parseFilterTriggers reads an optional mode prefix and a "(a|b|c)" list of
+// alternatives. Regex triggers keep their case; everything else is lowercased.
+func parseFilterTriggers(keyword string) (mode string, triggers []string, err error) {
+	mode = db.FilterModeWord
+	body := keyword
+	for _, m := range filterModes {
+		if len(keyword) > len(m) && strings.EqualFold(keyword[:len(m)+1], m+":") {
+			mode, body = m, keyword[len(m)+1:]
+			break
+		}
+	}
+
+	if mode == db.FilterModeRegex {
+		if _, err := regexp.Compile("(?i)" + body); err != nil {
+			return "", nil, err
+		}
+		return mode, []string{body}, nil
+	}
+
+	body = strings.ToLower(strings.TrimSpace(body))
+	if inner, ok := strings.CutPrefix(body, "("); ok && strings.HasSuffix(inner, ")") {
+		for t := range strings.SplitSeq(strings.TrimSuffix(inner, ")"), "|") {
+			if t = strings.TrimSpace(t); t != "" {
+				triggers = append(triggers, t)
+			}
+		}
+	} else if body != "" {
+		triggers = []string{body}
+	}
+	return mode, triggers, nil
+}
+
+// filterStorageKey is the key a trigger is saved under: regex filters keep
+// their case so the pattern is unchanged, everything else is lowercased.
+func filterStorageKey(keyword string) string {
+	if strings.HasPrefix(strings.ToLower(keyword), db.FilterModeRegex+":") {
+		return db.FilterModeRegex + ":" + keyword[len(db.FilterModeRegex)+1:]
+	}
+	return strings.ToLower(keyword)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isWordEdge reports whether a trigger may start or end at byte offset i of
+// text: the character on the far side, if any, must not be part of a word.
+// Only the outside is checked, so triggers may begin or end with punctuation.
+func isWordEdge(text string, i int, start bool) bool {
+	var r rune
+	switch {
+	case start && i > 0:
+		r, _ = utf8.DecodeLastRuneInString(text[:i])
+	case !start && i < len(text):
+		r, _ = utf8.DecodeRuneInString(text[i:])
+	default:
+		return true
+	}
+	return !isWordRune(r)
+}
+
+// filterCache holds a matcher per chat, built on first use and dropped
+// whenever the chat's filters change.
+type filterCache struct {
+	mu    sync.RWMutex
+	chats map[int64]*filterMatcher
+}
+
+var filterMatchers = &filterCache{chats: make(map[int64]*filterMatcher)}
+
+func (fc *filterCache) get(chatID int64) (*filterMatcher, error) {
+	fc.mu.RLock()
+	fm, ok := fc.chats[chatID]
+	fc.mu.RUnlock()
+	if ok {
+		return fm, nil
+	}
+
+	filters, err := db.GetAllFilters(chatID)
+	if err != nil {
+		return nil, err
+	}
+	fm = newFilterMatcher(filters)
+
+	fc.mu.Lock()
+	fc.chats[chatID] = fm
+	fc.mu.Unlock()
+	return fm, nil
+}
+
+func (fc *filterCache) invalidate(chatID int64) {
+	fc.mu.Lock()
+	delete(fc.chats, chatID)
+	fc.mu.Unlock()
+}
+
+func (fc *filterCache) reset() {
+	fc.mu.Lock()
+	clear(fc.chats)
+	fc.mu.Unlock()
+}
+
 func FilterHandler(m *tg.NewMessage) error {
 	if m.IsPrivate() {
 		m.Reply("<b>Filters work in groups only.</b>")
@@ -27,22 +238,40 @@ func FilterHandler(m *tg.NewMessage) error {
 		return nil
 	}
 
-	parts := strings.SplitN(args, " ", 2)
-	keyword := strings.ToLower(strings.TrimSpace(parts[0]))
+	keyword, response, ok := splitFilterKeyword(args)
+	if !ok {
+		m.Reply("<b>Error:</b> Missing closing quote in trigger.")
+		return nil
+	}
+	keyword = filterStorageKey(keyword)
 
 	if keyword == "" {
 		m.Reply("<b>Error:</b> Keyword required.")
 		return nil
 	}
 
-	if len(keyword) < 2 {
-		m.Reply("<b>Error:</b> Keyword must be at least 2 characters.")
+	mode, triggers, err := parseFilterTriggers(keyword)
+	if err != nil {
+		m.Reply(fmt.Sprintf("<b>Invalid regex:</b> <code>%s</code>", err.Error()))
+		return nil
+	}
+
+	for _, trigger := range triggers {
+		if len(trigger) < 2 {
+			m.Reply("<b>Error:</b> Keyword must be at least 2 characters.")
+			return nil
+		}
+	}
+	if len(triggers) == 0 {
+		m.Reply("<b>Error:</b> Keyword required.")
 		return nil
 	}
 
 	filter := &db.Filter{
-		Keyword: keyword,
-		AddedBy: m.SenderID(),
+		Keyword:  keyword,
+		Triggers: triggers,
+		Mode:     mode,
+		AddedBy:  m.SenderID(),
 	}
 
 	if m.IsReply() {
@@ -75,16 +304,17 @@ func FilterHandler(m *tg.NewMessage) error {
 		}
 
 		filter.Content = reply.Text()
-		if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
-			filter.Content = parts[1]
+		if response != "" {
+			filter.Content = response
 		}
 	} else {
-		if len(parts) < 2 {
+		if response == "" {
 			m.Reply("<b>Error:</b> Provide response or reply to a message.")
 			return nil
 		}
-		filter.Content = parts[1]
+		filter.Content = response
 	}
+	filter.Replies = db.SplitFilterReplies(filter.Content)
 
 	if filter.Content == "" && filter.FileID == "" {
 		m.Reply("<b>Error:</b> Filter response required.")
@@ -96,6 +326,7 @@ func FilterHandler(m *tg.NewMessage) error {
 		return nil
 	}
 
+	logMessageEvent(m, &LogEvent{Category: LogCategoryFilters, Action: "filter", Details: keyword})
 	m.Reply(fmt.Sprintf("<b>Filter saved:</b> <code>%s</code>", keyword))
 	return nil
 }
@@ -111,7 +342,8 @@ func StopFilterHandler(m *tg.NewMessage) error {
 		return nil
 	}
 
-	keyword := strings.ToLower(strings.TrimSpace(m.Args()))
+	keyword, _, _ := splitFilterKeyword(m.Args())
+	keyword = filterStorageKey(keyword)
 	if keyword == "" {
 		m.Reply("<b>Usage:</b> <code>/stop keyword</code>")
 		return nil
@@ -128,6 +360,7 @@ func StopFilterHandler(m *tg.NewMessage) error {
 		return nil
 	}
 
+	logMessageEvent(m, &LogEvent{Category: LogCategoryFilters, Action: "stop", Details: keyword})
 	m.Reply(fmt.Sprintf("<b>Filter removed:</b> <code>%s</code>", keyword))
 	return nil
 }
@@ -155,8 +388,14 @@ func ListFiltersHandler(m *tg.NewMessage) error {
 	mediaCount := 0
 	for _, filter := range filters {
 		marker := ""
+		if filter.Mode != db.FilterModeWord {
+			marker += " [" + filter.Mode + "]"
+		}
+		if len(filter.Replies) > 1 {
+			marker += fmt.Sprintf(" [%d replies]", len(filter.Replies))
+		}
 		if filter.FileID != "" {
-			marker = " [M]"
+			marker += " [M]"
 			mediaCount++
 		}
 		resp.WriteString(fmt.Sprintf(" • <code>%s</code>%s\n", filter.Keyword, marker))
@@ -230,6 +469,7 @@ func StopAllFiltersCallback(c *tg.CallbackQuery) error {
 			return nil
 		}
 
+		logCallbackEvent(c, &LogEvent{Category: LogCategoryFilters, Action: "stopall", Details: fmt.Sprintf("Removed %d filters", count)})
 		c.Edit(fmt.Sprintf("<b>Deleted:</b> %d filters", count))
 	}
 
@@ -241,40 +481,45 @@ func FilterWatcher(m *tg.NewMessage) error {
 		return nil
 	}
 
-	filters, err := db.GetAllFilters(m.ChatID())
-	if err != nil || len(filters) == 0 {
+	fm, err := filterMatchers.get(m.ChatID())
+	if err != nil || len(fm.filters) == 0 {
 		return nil
 	}
 
-	msgLower := strings.ToLower(m.Text())
+	matched := fm.matchAll(m.Text())
+	if len(matched) == 0 {
+		return nil
+	}
 
-	for _, filter := range filters {
-		pattern := `(?i)\b` + regexp.QuoteMeta(filter.Keyword) + `\b`
-		matched, _ := regexp.MatchString(pattern, msgLower)
+	// Only the earliest trigger in the message replies, so one message can't
+	// set off a burst of filter responses.
+	sendFilterReply(m, matched[0])
+	return nil
+}
 
-		if matched {
-			// Parse buttons from filter content
-			buttons, cleanContent := ParseButtonsFromText(filter.Content)
+func sendFilterReply(m *tg.NewMessage, filter *db.Filter) {
+	content := ""
+	if len(filter.Replies) > 0 {
+		content = filter.Replies[rand.Intn(len(filter.Replies))]
+	}
 
-			if filter.FileID != "" {
-				opts := &tg.MediaOptions{Caption: cleanContent}
-				if len(buttons) > 0 {
-					opts.ReplyMarkup = BuildButtonKeyboard(buttons)
-				}
-				file, _ := tg.ResolveBotFileID(filter.FileID)
-				m.ReplyMedia(file, opts)
-			} else if cleanContent != "" {
-				if len(buttons) > 0 {
-					m.Reply(cleanContent, &tg.SendOptions{ReplyMarkup: BuildButtonKeyboard(buttons)})
-				} else {
-					m.Reply(cleanContent)
-				}
-			}
-			break
+	// Parse buttons from filter content
+	buttons, cleanContent := ParseButtonsFromText(content)
+
+	if filter.FileID != "" {
+		opts := &tg.MediaOptions{Caption: cleanContent}
+		if len(buttons) > 0 {
+			opts.ReplyMarkup = BuildButtonKeyboard(buttons)
+		}
+		file, _ := tg.ResolveBotFileID(filter.FileID)
+		m.ReplyMedia(file, opts)
+	} else if cleanContent != "" {
+		if len(buttons) > 0 {
+			m.Reply(cleanContent, &tg.SendOptions{ReplyMarkup: BuildButtonKeyboard(buttons)})
+		} else {
+			m.Reply(cleanContent)
 		}
 	}
-
-	return nil
 }
 
 func registerFiltersHandlers() {
@@ -290,6 +535,8 @@ func registerFiltersHandlers() {
 
 func init() {
 	QueueHandlerRegistration(registerFiltersHandlers)
+	db.OnFilterChange(filterMatchers.invalidate)
+	db.OnRestore(filterMatchers.reset)
 
 	Mods.AddModule("Filters", `<b>Content Filters</b>
 
@@ -305,6 +552,17 @@ Set automatic responses or deletion for keywords.
 Triggers when keyword appears as a complete word.
 Example: "hello" triggers on "hello there" but not "helloworld".
 
+<b>Triggers:</b>
+• <code>/filter "good morning" ...</code> - Quote triggers with spaces
+• <code>/filter "(hi|hello|hey)" ...</code> - Any of several keywords
+• <code>/filter exact:hi ...</code> - Only when the whole message is the keyword
+• <code>/filter prefix:!help ...</code> - Only when the message starts with it
+• <code>/filter "regex:fr[e3]{2} ?stuff" ...</code> - Regular expression, case-insensitive
+
+<b>Random Replies:</b>
+Separate alternatives with <code>%%%</code> and one is picked at random.
+Example: <code>/filter hi Hello! %%% Hey there! %%% Yo</code>
+
 <b>Add Buttons:</b>
 Use format: <code>[Button Text](https://example.com)</code>
 Example: <code>/filter spam [Report](url) | [Info](url)</code>
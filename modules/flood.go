@@ -0,0 +1,367 @@
+package modules
+
+import (
+	"fmt"
+	"main/modules/db"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+// maxFloodWindow bounds /setflood windows and how long idle history is kept.
+const maxFloodWindow = 10 * time.Minute
+
+// floodTracker keeps a sliding window of message timestamps per chat and user.
+type floodTracker struct {
+	mu        sync.Mutex
+	chats     map[int64]map[int64][]time.Time
+	lastSweep time.Time
+}
+
+var flood = &floodTracker{chats: make(map[int64]map[int64][]time.Time)}
+
+// hit records a message and reports whether the user has now sent more than
+// limit messages inside window. The user's history is cleared when it fires
+// so a single burst only triggers one action.
+func (f *floodTracker) hit(chatID, userID int64, limit int, window time.Duration) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(f.lastSweep) > time.Minute {
+		f.sweep(now)
+		f.lastSweep = now
+	}
+
+	users, ok := f.chats[chatID]
+	if !ok {
+		users = make(map[int64][]time.Time)
+		f.chats[chatID] = users
+	}
+
+	cutoff := now.Add(-window)
+	stamps := users[userID]
+	i := 0
+	for i < len(stamps) && !stamps[i].After(cutoff) {
+		i++
+	}
+	stamps = append(stamps[i:], now)
+
+	if len(stamps) > limit {
+		delete(users, userID)
+		return true
+	}
+	users[userID] = stamps
+	return false
+}
+
+// sweep drops users whose newest message is older than any possible window.
+func (f *floodTracker) sweep(now time.Time) {
+	cutoff := now.Add(-maxFloodWindow)
+	for chatID, users := range f.chats {
+		for userID, stamps := range users {
+			if len(stamps) == 0 || !stamps[len(stamps)-1].After(cutoff) {
+				delete(users, userID)
+			}
+		}
+		if len(users) == 0 {
+			delete(f.chats, chatID)
+		}
+	}
+}
+
+func (f *floodTracker) reset(chatID int64) {
+	f.mu.Lock()
+	delete(f.chats, chatID)
+	f.mu.Unlock()
+}
+
+// floodSettingsCache holds each chat's flood settings so FloodWatcher doesn't
+// read the database for every message. Cached values are shared; change
+// settings through set.
+type floodSettingsCache struct {
+	mu    sync.RWMutex
+	chats map[int64]*db.FloodSettings
+}
+
+var floodSettings = &floodSettingsCache{chats: make(map[int64]*db.FloodSettings)}
+
+func (fc *floodSettingsCache) get(chatID int64) (*db.FloodSettings, error) {
+	fc.mu.RLock()
+	settings, ok := fc.chats[chatID]
+	fc.mu.RUnlock()
+	if ok {
+		return settings, nil
+	}
+
+	settings, err := db.GetFloodSettings(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	fc.mu.Lock()
+	fc.chats[chatID] = settings
+	fc.mu.Unlock()
+	return settings, nil
+}
+
+// set saves settings and resets the chat's flood history, since counts
+// gathered under the old limit no longer apply.
+func (fc *floodSettingsCache) set(chatID int64, settings *db.FloodSettings) error {
+	if err := db.SetFloodSettings(chatID, settings); err != nil {
+		return err
+	}
+
+	fc.mu.Lock()
+	fc.chats[chatID] = settings
+	fc.mu.Unlock()
+	flood.reset(chatID)
+	return nil
+}
+
+func (fc *floodSettingsCache) reset() {
+	fc.mu.Lock()
+	clear(fc.chats)
+	fc.mu.Unlock()
+}
+
+func formatFloodAction(settings *db.FloodSettings) string {
+	if settings.Duration != "" {
+		return settings.Action + " (" + settings.Duration + ")"
+	}
+	return settings.Action
+}
+
+func FloodWatcher(m *tg.NewMessage) error {
+	if m.IsPrivate() || m.SenderID() == 0 {
+		return nil
+	}
+
+	settings, err := floodSettings.get(m.ChatID())
+	if err != nil || settings.Limit <= 0 {
+		return nil
+	}
+
+	window := time.Duration(settings.WindowSec) * time.Second
+	if !flood.hit(m.ChatID(), m.SenderID(), settings.Limit, window) {
+		return nil
+	}
+
+	if IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "") || db.IsApproved(m.ChatID(), m.SenderID()) {
+		return nil
+	}
+
+	user, err := m.Client.ResolvePeer(m.SenderID())
+	if err != nil {
+		return nil
+	}
+
+	reason := fmt.Sprintf("Flooding (%d+ messages in %ds)", settings.Limit+1, settings.WindowSec)
+	botID := m.Client.Me().ID
+
+	var msg string
+	switch settings.Action {
+	case db.FloodActionBan:
+		msg, err = performBan(m.Client, m.ChatID(), user, reason, botID)
+	case db.FloodActionKick:
+		msg, err = performKick(m.Client, m.ChatID(), user, reason, botID)
+	case db.FloodActionTBan:
+		msg, err = performTban(m.Client, m.ChatID(), user, settings.Duration, reason, botID)
+	case db.FloodActionTMute:
+		msg, err = performTmute(m.Client, m.ChatID(), user, settings.Duration, reason, botID)
+	default:
+		msg, err = performMute(m.Client, m.ChatID(), user, reason, botID)
+	}
+
+	if err != nil {
+		m.Respond(adminFriendlyError(err, settings.Action+" flooder"))
+		return nil
+	}
+
+	logMessageEvent(m, &LogEvent{
+		Category: actionLogCategory(settings.Action),
+		Action:   "flood " + settings.Action,
+		ActorID:  botID,
+		TargetID: m.SenderID(),
+		Reason:   reason,
+		Duration: settings.Duration,
+	})
+
+	m.Respond(msg)
+	return nil
+}
+
+// SetFloodHandler - /setflood <count|off> [window]
+func SetFloodHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Flood control can only be used in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "ban") {
+		m.Reply("You need Ban Users permission to change flood settings")
+		return nil
+	}
+
+	args := strings.Fields(strings.ToLower(m.Args()))
+	if len(args) == 0 {
+		m.Reply("Usage: /setflood <count|off> [window]\nExample: /setflood 5 10s")
+		return nil
+	}
+
+	settings, _ := db.GetFloodSettings(m.ChatID())
+
+	switch args[0] {
+	case "off", "no", "0":
+		settings.Limit = 0
+	default:
+		limit, err := strconv.Atoi(args[0])
+		if err != nil || limit < 2 || limit > 100 {
+			m.Reply("Flood limit must be a number between 2 and 100")
+			return nil
+		}
+		settings.Limit = limit
+	}
+
+	if len(args) > 1 {
+		var window time.Duration
+		if secs, err := strconv.Atoi(args[1]); err == nil {
+			window = time.Duration(secs) * time.Second
+		} else if window, err = parseAdminDuration(args[1]); err != nil {
+			m.Reply("Invalid window. Examples: 10, 10s, 1m")
+			return nil
+		}
+		if window < time.Second || window > maxFloodWindow {
+			m.Reply("Flood window must be between 1 second and 10 minutes")
+			return nil
+		}
+		settings.WindowSec = int(window / time.Second)
+	}
+
+	if err := floodSettings.set(m.ChatID(), settings); err != nil {
+		m.Reply("Failed to update flood settings")
+		return nil
+	}
+
+	if settings.Limit == 0 {
+		m.Reply("Flood control disabled")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("Flood control enabled: more than <b>%d</b> messages in <b>%ds</b> will trigger <b>%s</b>",
+		settings.Limit, settings.WindowSec, formatFloodAction(settings)))
+	return nil
+}
+
+// SetFloodModeHandler - /setfloodmode ban|mute|kick|tban <d>|tmute <d>
+func SetFloodModeHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Flood control can only be used in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "ban") {
+		m.Reply("You need Ban Users permission to change flood settings")
+		return nil
+	}
+
+	args := strings.Fields(strings.ToLower(m.Args()))
+	if len(args) == 0 {
+		m.Reply("Usage: /setfloodmode ban|mute|kick|tban <duration>|tmute <duration>")
+		return nil
+	}
+
+	settings, _ := db.GetFloodSettings(m.ChatID())
+
+	switch args[0] {
+	case db.FloodActionBan, db.FloodActionMute, db.FloodActionKick:
+		settings.Action = args[0]
+		settings.Duration = ""
+	case db.FloodActionTBan, db.FloodActionTMute:
+		if len(args) < 2 {
+			m.Reply(fmt.Sprintf("%s requires a duration. Example: /setfloodmode %s 1h", args[0], args[0]))
+			return nil
+		}
+		if d, err := parseAdminDuration(args[1]); err != nil || d <= 0 {
+			m.Reply("Invalid duration. Examples: 30m, 1h, 2d")
+			return nil
+		}
+		settings.Action = args[0]
+		settings.Duration = args[1]
+	default:
+		m.Reply("Unknown action. Use: ban, mute, kick, tban, tmute")
+		return nil
+	}
+
+	if err := floodSettings.set(m.ChatID(), settings); err != nil {
+		m.Reply("Failed to update flood settings")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("Flood action set to: <b>%s</b>", formatFloodAction(settings)))
+	return nil
+}
+
+// FloodHandler - /flood, shows current settings
+func FloodHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Flood control can only be used in groups")
+		return nil
+	}
+
+	settings, err := floodSettings.get(m.ChatID())
+	if err != nil {
+		m.Reply("Failed to read flood settings")
+		return nil
+	}
+
+	if settings.Limit <= 0 {
+		m.Reply("Flood control is <b>disabled</b> in this chat.\nUse /setflood <count> [window] to enable it.")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf(`<b>Flood Control</b>
+
+<b>Limit:</b> %d messages
+<b>Window:</b> %ds
+<b>Action:</b> %s`, settings.Limit, settings.WindowSec, formatFloodAction(settings)))
+	return nil
+}
+
+func registerFloodHandlers() {
+	c := Client
+	c.On("cmd:setflood", SetFloodHandler)
+	c.On("cmd:setfloodmode", SetFloodModeHandler)
+	c.On("cmd:flood", FloodHandler)
+	c.On(tg.OnNewMessage, FloodWatcher)
+}
+
+func init() {
+	QueueHandlerRegistration(registerFloodHandlers)
+	db.OnRestore(floodSettings.reset)
+
+	Mods.AddModule("Antiflood", `<b>Antiflood</b>
+
+Act on users who send too many messages in a short time.
+
+<b>Commands:</b>
+/flood - Show current flood settings
+/setflood <count|off> [window] - Allow up to count messages per window (default 10s)
+/setfloodmode <action> [duration] - Set what happens to flooders
+
+<b>Actions:</b>
+ - ban - Ban the user
+ - mute - Mute the user (default)
+ - kick - Remove the user
+ - tban <duration> - Temporary ban
+ - tmute <duration> - Temporary mute
+
+<b>Examples:</b>
+<code>/setflood 6 5s</code>
+<code>/setfloodmode tmute 30m</code>
+
+<b>Note:</b> Admins and approved users are exempt from flood control.`)
+}
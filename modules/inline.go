@@ -264,20 +264,21 @@ func fetchPinterestImages(query string, lim int, offset int) ([]string, error) {
 func EmptyPreviewInline(i *telegram.InlineQuery) error {
 	b := i.Builder()
 
-b.Article(
-	"Preview",
-	"Empty webpage preview",
-	"",
-	&telegram.ArticleOptions{
-		WebPage: &telegram.InputBotInlineMessageMediaWebPage{
-			URL:      "https://telegram.org/",
-			Optional: true,
-			Message:  "",
+	b.Article(
+		"Preview",
+		"Empty webpage preview",
+		"",
+		&telegram.ArticleOptions{
+			WebPage: &telegram.InputBotInlineMessageMediaWebPage{
+				URL:      "https://telegram.org/",
+				Optional: true,
+				Message:  "",
+			},
 		},
-	},
-)
+	)
 
-return i.Answer(b.Results())
+	_, err := i.Answer(b.Results())
+	return err
 }
 
 func registerInlineHandlers() {
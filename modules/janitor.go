@@ -0,0 +1,175 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"main/modules/db"
+	"strings"
+	"sync"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+const (
+	janitorInterval = time.Minute
+	// janitorBatchSize caps how many records one write transaction removes,
+	// so a large backlog doesn't hold the database lock for long.
+	janitorBatchSize = 200
+)
+
+// janitorStats is shown in /go.
+type janitorStats struct {
+	sync.Mutex
+	Runs         int
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+	NotesRemoved int
+	WarnsExpired int
+	WarnsDecayed int
+}
+
+var janitor janitorStats
+
+// startJanitor sweeps expired temporary notes and warnings every
+// janitorInterval.
+func startJanitor(client *tg.Client) {
+	runJanitor(client)
+	for range time.Tick(janitorInterval) {
+		runJanitor(client)
+	}
+}
+
+func runJanitor(client *tg.Client) {
+	start := time.Now()
+	var notes []*db.ExpiredNote
+	var warns []*db.ExpiredWarn
+	var runErr error
+
+	for {
+		batch, err := db.DeleteExpiredNotes(start, janitorBatchSize)
+		if err != nil {
+			runErr = err
+			break
+		}
+		notes = append(notes, batch...)
+		if len(batch) < janitorBatchSize {
+			break
+		}
+	}
+	for runErr == nil {
+		batch, err := db.DeleteExpiredWarns(start, janitorBatchSize)
+		if err != nil {
+			runErr = err
+			break
+		}
+		warns = append(warns, batch...)
+		if len(batch) < janitorBatchSize {
+			break
+		}
+	}
+
+	janitor.Lock()
+	janitor.Runs++
+	janitor.LastRun = start
+	janitor.LastDuration = time.Since(start)
+	janitor.LastError = ""
+	if runErr != nil {
+		janitor.LastError = runErr.Error()
+	}
+	janitor.NotesRemoved += len(notes)
+	for _, w := range warns {
+		if w.Decayed {
+			janitor.WarnsDecayed++
+		} else {
+			janitor.WarnsExpired++
+		}
+	}
+	janitor.Unlock()
+
+	if runErr != nil {
+		log.Printf("[janitor] sweep failed: %v", runErr)
+	}
+	if len(notes) > 0 || len(warns) > 0 {
+		log.Printf("[janitor] removed %d notes, %d warnings", len(notes), len(warns))
+	}
+
+	logJanitorRemovals(client, notes, warns)
+}
+
+// logJanitorRemovals posts one log event per chat for notes and one per user
+// for warnings, to chats that have a log channel.
+func logJanitorRemovals(client *tg.Client, notes []*db.ExpiredNote, warns []*db.ExpiredWarn) {
+	noteNames := make(map[int64][]string)
+	for _, n := range notes {
+		noteNames[n.ChatID] = append(noteNames[n.ChatID], "#"+n.Name)
+	}
+	for chatID, names := range noteNames {
+		sendLogEvent(client, &LogEvent{
+			Category: LogCategoryNotes,
+			Action:   "note expired",
+			ChatID:   chatID,
+			Details:  trimString(strings.Join(names, ", "), 500),
+		})
+	}
+
+	type warnKey struct {
+		chatID, userID int64
+		decayed        bool
+	}
+	var order []warnKey
+	reasons := make(map[warnKey][]string)
+	for _, w := range warns {
+		key := warnKey{w.ChatID, w.UserID, w.Decayed}
+		if _, ok := reasons[key]; !ok {
+			order = append(order, key)
+		}
+		reasons[key] = append(reasons[key], w.Reason)
+	}
+	for _, key := range order {
+		action := "warn expired"
+		if key.decayed {
+			action = "warn decayed"
+		}
+		sendLogEvent(client, &LogEvent{
+			Category: LogCategoryWarns,
+			Action:   action,
+			ChatID:   key.chatID,
+			TargetID: key.userID,
+			Reason:   trimString(strings.Join(reasons[key], "; "), 500),
+			Details:  fmt.Sprintf("%d removed", len(reasons[key])),
+		})
+	}
+}
+
+// janitorStatsText renders the janitor counters for /go.
+func janitorStatsText() string {
+	janitor.Lock()
+	defer janitor.Unlock()
+
+	if janitor.Runs == 0 {
+		return "<b>Janitor</b>: <code>not run yet</code>"
+	}
+	text := fmt.Sprintf(`<b>Janitor</b>
+  • Runs: <code>%d</code> (every %s)
+  • Last: <code>%s ago</code> in <code>%s</code>
+  • Notes removed: <code>%d</code>
+  • Warns expired: <code>%d</code>
+  • Warns decayed: <code>%d</code>`,
+		janitor.Runs, formatDuration(janitorInterval),
+		formatDuration(time.Since(janitor.LastRun).Round(time.Second)), janitor.LastDuration.Round(time.Millisecond),
+		janitor.NotesRemoved, janitor.WarnsExpired, janitor.WarnsDecayed)
+	if janitor.LastError != "" {
+		text += fmt.Sprintf("\n  • Last error: <code>%s</code>", janitor.LastError)
+	}
+	return text
+}
+
+func registerJanitor() {
+	go startJanitor(Client)
+}
+
+func init() {
+	QueueHandlerRegistration(registerJanitor)
+}
@@ -0,0 +1,472 @@
+package modules
+
+import (
+	"fmt"
+	"html"
+	"main/modules/db"
+	"strings"
+	"sync"
+	"unicode"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+// botLock is a lock Telegram has no banned right for, so LockWatcher deletes
+// offending messages itself.
+type botLock struct {
+	name  string
+	label string
+	match func(m *tg.NewMessage) bool
+}
+
+var botLocks = []botLock{
+	{"url", "URLs", hasURL},
+	{"forward", "Forwards", func(m *tg.NewMessage) bool { return m.IsForward() }},
+	{"forwardchannel", "Forwards from channels", isChannelForward},
+	{"inlinebot", "Inline bot messages", func(m *tg.NewMessage) bool { return m.Message.ViaBotID != 0 }},
+	{"button", "Buttons", hasButtons},
+	{"mention", "Mentions", hasMention},
+	{"emoji", "Emoji-only messages", func(m *tg.NewMessage) bool { return isEmojiOnly(m.Text()) }},
+	{"rtl", "RTL/Arabic text", func(m *tg.NewMessage) bool { return isRTL(m.Text()) }},
+	{"cyrillic", "Cyrillic text", func(m *tg.NewMessage) bool { return containsLetters(m.Text(), unicode.Cyrillic) }},
+	{"chinese", "Chinese text", func(m *tg.NewMessage) bool { return containsLetters(m.Text(), unicode.Han) }},
+	{"contact", "Contacts", func(m *tg.NewMessage) bool { return m.Contact() != nil }},
+	{"location", "Locations", isLocation},
+	{"voice", "Voice messages", func(m *tg.NewMessage) bool { return documentAttr(m, isVoiceAttr) }},
+	{"videonote", "Video notes", func(m *tg.NewMessage) bool { return documentAttr(m, isRoundAttr) }},
+	// bots is enforced on participant updates, not messages.
+	{"bots", "Bots added by non-admins", nil},
+}
+
+var botLockAliases = map[string]string{
+	"urls":            "url",
+	"link":            "url",
+	"links":           "url",
+	"forwards":        "forward",
+	"fwd":             "forward",
+	"channelforward":  "forwardchannel",
+	"forwardchannels": "forwardchannel",
+	"via":             "inlinebot",
+	"buttons":         "button",
+	"mentions":        "mention",
+	"emojis":          "emoji",
+	"arabic":          "rtl",
+	"russian":         "cyrillic",
+	"cjk":             "chinese",
+	"contacts":        "contact",
+	"locations":       "location",
+	"geo":             "location",
+	"voices":          "voice",
+	"videonotes":      "videonote",
+	"round":           "videonote",
+	"bot":             "bots",
+}
+
+func findBotLock(name string) *botLock {
+	if alias, ok := botLockAliases[name]; ok {
+		name = alias
+	}
+	for i := range botLocks {
+		if botLocks[i].name == name {
+			return &botLocks[i]
+		}
+	}
+	return nil
+}
+
+func botLockNames() string {
+	names := make([]string, len(botLocks))
+	for i, l := range botLocks {
+		names[i] = l.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// containsLetters reports whether text has a non-ASCII letter, optionally
+// only counting letters from the given scripts.
+func containsLetters(text string, scripts ...*unicode.RangeTable) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) && r > unicode.MaxASCII && (len(scripts) == 0 || unicode.IsOneOf(scripts, r)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isRTL(text string) bool {
+	return containsLetters(text, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko) ||
+		strings.ContainsAny(text, "\u200f\u202b\u202e")
+}
+
+// isEmojiOnly reports whether text is made of emoji alone. Modifiers, joiners
+// and variation selectors count as part of an emoji.
+func isEmojiOnly(text string) bool {
+	emoji := false
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+		case unicode.Is(unicode.So, r):
+			emoji = true
+		case unicode.In(r, unicode.Sk, unicode.Mn, unicode.Me, unicode.Cf):
+		default:
+			return false
+		}
+	}
+	return emoji
+}
+
+func hasEntity(m *tg.NewMessage, match func(tg.MessageEntity) bool) bool {
+	for _, e := range m.Message.Entities {
+		if match(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasURL(m *tg.NewMessage) bool {
+	return hasEntity(m, func(e tg.MessageEntity) bool {
+		switch e.(type) {
+		case *tg.MessageEntityURL, *tg.MessageEntityTextURL:
+			return true
+		}
+		return false
+	})
+}
+
+func hasMention(m *tg.NewMessage) bool {
+	return hasEntity(m, func(e tg.MessageEntity) bool {
+		switch e.(type) {
+		case *tg.MessageEntityMention, *tg.MessageEntityMentionName, *tg.InputMessageEntityMentionName:
+			return true
+		}
+		return false
+	})
+}
+
+func hasButtons(m *tg.NewMessage) bool {
+	_, ok := m.Message.ReplyMarkup.(*tg.ReplyInlineMarkup)
+	return ok
+}
+
+func isChannelForward(m *tg.NewMessage) bool {
+	if m.Message.FwdFrom == nil {
+		return false
+	}
+	_, ok := m.Message.FwdFrom.FromID.(*tg.PeerChannel)
+	return ok
+}
+
+func isLocation(m *tg.NewMessage) bool {
+	switch m.Media().(type) {
+	case *tg.MessageMediaGeo, *tg.MessageMediaGeoLive, *tg.MessageMediaVenue:
+		return true
+	}
+	return false
+}
+
+func isVoiceAttr(attr tg.DocumentAttribute) bool {
+	a, ok := attr.(*tg.DocumentAttributeAudio)
+	return ok && a.Voice
+}
+
+func isRoundAttr(attr tg.DocumentAttribute) bool {
+	v, ok := attr.(*tg.DocumentAttributeVideo)
+	return ok && v.RoundMessage
+}
+
+func documentAttr(m *tg.NewMessage, match func(tg.DocumentAttribute) bool) bool {
+	media, ok := m.Media().(*tg.MessageMediaDocument)
+	if !ok {
+		return false
+	}
+	doc, ok := media.Document.(*tg.DocumentObj)
+	if !ok {
+		return false
+	}
+	for _, attr := range doc.Attributes {
+		if match(attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// lockCache holds each chat's bot-enforced locks so LockWatcher doesn't read
+// the database for every message.
+type lockCache struct {
+	mu    sync.RWMutex
+	chats map[int64]*db.ChatLocks
+}
+
+var chatLocks = &lockCache{chats: make(map[int64]*db.ChatLocks)}
+
+func (lc *lockCache) get(chatID int64) *db.ChatLocks {
+	lc.mu.RLock()
+	locks, ok := lc.chats[chatID]
+	lc.mu.RUnlock()
+	if ok {
+		return locks
+	}
+
+	locks, err := db.GetChatLocks(chatID)
+	if err != nil {
+		return &db.ChatLocks{}
+	}
+
+	lc.mu.Lock()
+	lc.chats[chatID] = locks
+	lc.mu.Unlock()
+	return locks
+}
+
+// update applies fn to a copy of the chat's locks and saves it.
+func (lc *lockCache) update(chatID int64, fn func(*db.ChatLocks)) error {
+	current := lc.get(chatID)
+	locks := &db.ChatLocks{Locks: make(map[string]*db.LockSetting, len(current.Locks))}
+	for name, setting := range current.Locks {
+		locks.Locks[name] = setting
+	}
+	fn(locks)
+	if err := db.SetChatLocks(chatID, locks); err != nil {
+		return err
+	}
+
+	lc.mu.Lock()
+	lc.chats[chatID] = locks
+	lc.mu.Unlock()
+	return nil
+}
+
+func (lc *lockCache) reset() {
+	lc.mu.Lock()
+	clear(lc.chats)
+	lc.mu.Unlock()
+}
+
+// parseLockAction reads "[action] [duration]" after a lock name.
+func parseLockAction(args []string) (*db.LockSetting, error) {
+	if len(args) == 0 {
+		return &db.LockSetting{Action: db.LockActionDelete}, nil
+	}
+
+	switch args[0] {
+	case db.LockActionDelete, db.LockActionWarn, db.LockActionMute, db.LockActionKick, db.LockActionBan:
+		return &db.LockSetting{Action: args[0]}, nil
+	case db.LockActionTMute, db.LockActionTBan:
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s needs a duration, e.g. <code>%s 1h</code>", args[0], args[0])
+		}
+		if d, err := parseAdminDuration(args[1]); err != nil || d <= 0 {
+			return nil, fmt.Errorf("bad duration %q, use e.g. 30m, 1h or 2d", args[1])
+		}
+		return &db.LockSetting{Action: args[0], Duration: args[1]}, nil
+	}
+	return nil, fmt.Errorf("unknown action %q, use delete, warn, mute, kick, ban, tmute or tban", args[0])
+}
+
+func formatLockAction(setting *db.LockSetting) string {
+	if setting.Duration != "" {
+		return setting.Action + " " + setting.Duration
+	}
+	return setting.Action
+}
+
+// lockBotLock handles /lock <name> [action] [duration] for a bot-enforced lock.
+func lockBotLock(m *tg.NewMessage, lock *botLock, args []string) error {
+	setting, err := parseLockAction(args)
+	if err != nil {
+		m.Reply("Invalid lock action: " + html.EscapeString(err.Error()))
+		return nil
+	}
+	if !CanBot(m.Client, m.Channel, "delete") {
+		m.Reply("I need Delete Messages permission to enforce this lock.")
+		return nil
+	}
+
+	if err := chatLocks.update(m.ChatID(), func(l *db.ChatLocks) {
+		l.Locks[lock.name] = setting
+	}); err != nil {
+		m.Reply("Failed to save the lock.")
+		return nil
+	}
+
+	logMessageEvent(m, &LogEvent{Category: LogCategoryLocks, Action: "lock", Details: fmt.Sprintf("%s (%s)", lock.label, formatLockAction(setting))})
+	m.Reply(fmt.Sprintf("🔒 Locked: %s\n<b>Action:</b> %s", lock.label, formatLockAction(setting)))
+	return nil
+}
+
+func unlockBotLock(m *tg.NewMessage, lock *botLock) error {
+	if _, ok := chatLocks.get(m.ChatID()).Locks[lock.name]; !ok {
+		m.Reply(lock.label + " isn't locked.")
+		return nil
+	}
+
+	if err := chatLocks.update(m.ChatID(), func(l *db.ChatLocks) {
+		delete(l.Locks, lock.name)
+	}); err != nil {
+		m.Reply("Failed to remove the lock.")
+		return nil
+	}
+
+	logMessageEvent(m, &LogEvent{Category: LogCategoryLocks, Action: "unlock", Details: lock.label})
+	m.Reply("🔓 Unlocked: " + lock.label)
+	return nil
+}
+
+// botLocksStatus lists the bot-enforced locks for /locks and returns how many
+// are active.
+func botLocksStatus(chatID int64) (string, int) {
+	locks := chatLocks.get(chatID)
+
+	var sb strings.Builder
+	count := 0
+	for _, l := range botLocks {
+		if setting, ok := locks.Locks[l.name]; ok {
+			fmt.Fprintf(&sb, "🔒 %s <i>(%s)</i>\n", l.label, formatLockAction(setting))
+			count++
+		} else {
+			sb.WriteString("🔓 " + l.label + "\n")
+		}
+	}
+	return sb.String(), count
+}
+
+// punishLockBreaker applies setting to user and returns the message to post,
+// or "" when the lock only deletes.
+func punishLockBreaker(client *tg.Client, chatID int64, user tg.InputPeer, setting *db.LockSetting, reason string) (string, error) {
+	botID := client.Me().ID
+	switch setting.Action {
+	case db.LockActionWarn:
+		msg, _, err := performWarn(client, chatID, user, reason, botID)
+		return msg, err
+	case db.LockActionMute:
+		return performMute(client, chatID, user, reason, botID)
+	case db.LockActionKick:
+		return performKick(client, chatID, user, reason, botID)
+	case db.LockActionBan:
+		return performBan(client, chatID, user, reason, botID)
+	case db.LockActionTMute:
+		return performTmute(client, chatID, user, setting.Duration, reason, botID)
+	case db.LockActionTBan:
+		return performTban(client, chatID, user, setting.Duration, reason, botID)
+	}
+	return "", nil
+}
+
+// LockWatcher deletes messages that break a bot-enforced lock. Admins,
+// approved users and anonymous admins are exempt.
+func LockWatcher(m *tg.NewMessage) error {
+	if m.IsPrivate() || m.SenderID() == 0 || m.SenderID() == m.ChatID() {
+		return nil
+	}
+
+	locks := chatLocks.get(m.ChatID())
+	if len(locks.Locks) == 0 {
+		return nil
+	}
+
+	var broken *botLock
+	for i, l := range botLocks {
+		if _, ok := locks.Locks[l.name]; ok && l.match != nil && l.match(m) {
+			broken = &botLocks[i]
+			break
+		}
+	}
+	if broken == nil {
+		return nil
+	}
+
+	if IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "") || db.IsApproved(m.ChatID(), m.SenderID()) {
+		return nil
+	}
+	if !CanBot(m.Client, m.Channel, "delete") {
+		return nil
+	}
+	m.Delete()
+
+	setting := locks.Locks[broken.name]
+	reason := "Broke the " + broken.name + " lock"
+	if setting.Action != db.LockActionDelete {
+		user, err := m.Client.ResolvePeer(m.SenderID())
+		if err != nil {
+			return nil
+		}
+		msg, err := punishLockBreaker(m.Client, m.ChatID(), user, setting, reason)
+		if err != nil {
+			m.Respond(adminFriendlyError(err, setting.Action+" user"))
+			return nil
+		}
+		if msg != "" {
+			m.Respond(msg)
+		}
+	}
+
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryLocks,
+		Action:   "lock " + setting.Action,
+		ActorID:  m.Client.Me().ID,
+		TargetID: m.SenderID(),
+		Reason:   reason,
+		Duration: setting.Duration,
+	})
+	return nil
+}
+
+// LockBotsHandler removes bots added by non-admins while the bots lock is on,
+// and applies the lock's action to whoever added them.
+func LockBotsHandler(p *tg.ParticipantUpdate) error {
+	if !p.IsAdded() || p.User == nil || !p.User.Bot || p.User.ID == p.Client.Me().ID {
+		return nil
+	}
+
+	chatID := p.ChatID()
+	setting, ok := chatLocks.get(chatID).Locks["bots"]
+	if !ok {
+		return nil
+	}
+
+	actorID := p.ActorID()
+	if actorID == 0 || IsUserAdmin(p.Client, actorID, chatID, "") || db.IsApproved(chatID, actorID) {
+		return nil
+	}
+	if !CanBot(p.Client, p.Channel, "ban") {
+		return nil
+	}
+
+	if _, err := p.Client.KickParticipant(chatID, p.User); err != nil {
+		return nil
+	}
+
+	text := fmt.Sprintf("Removed @%s: only admins can add bots here.", p.User.Username)
+	if setting.Action != db.LockActionDelete {
+		if actor, err := p.Client.ResolvePeer(actorID); err == nil {
+			if msg, err := punishLockBreaker(p.Client, chatID, actor, setting, "Added a bot while bots are locked"); err == nil && msg != "" {
+				text += "\n\n" + msg
+			}
+		}
+	}
+	p.Client.SendMessage(chatID, text)
+
+	go sendLogEvent(p.Client, &LogEvent{
+		Category: LogCategoryLocks,
+		Action:   "lock " + setting.Action,
+		ChatID:   chatID,
+		ActorID:  p.Client.Me().ID,
+		TargetID: actorID,
+		Reason:   fmt.Sprintf("Added bot @%s", p.User.Username),
+		Duration: setting.Duration,
+	})
+	return nil
+}
+
+func registerLocksHandlers() {
+	c := Client
+	c.On(tg.OnNewMessage, LockWatcher)
+	c.On(tg.OnParticipant, LockBotsHandler)
+}
+
+func init() {
+	QueueHandlerRegistration(registerLocksHandlers)
+	db.OnRestore(chatLocks.reset)
+}
@@ -0,0 +1,362 @@
+package modules
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"main/modules/db"
+	"strings"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+const (
+	LogCategoryBans      = "bans"
+	LogCategoryMutes     = "mutes"
+	LogCategoryKicks     = "kicks"
+	LogCategoryWarns     = "warns"
+	LogCategoryPurges    = "purges"
+	LogCategoryLocks     = "locks"
+	LogCategoryFilters   = "filters"
+	LogCategoryNotes     = "notes"
+	LogCategoryWelcome   = "welcome"
+	LogCategoryBlacklist = "blacklist"
+)
+
+var logCategories = []string{
+	LogCategoryBans,
+	LogCategoryMutes,
+	LogCategoryKicks,
+	LogCategoryWarns,
+	LogCategoryPurges,
+	LogCategoryLocks,
+	LogCategoryFilters,
+	LogCategoryNotes,
+	LogCategoryWelcome,
+	LogCategoryBlacklist,
+}
+
+// actionLogCategory maps a moderation action name to its log category.
+func actionLogCategory(action string) string {
+	switch strings.TrimPrefix(strings.TrimPrefix(action, "un"), "t") {
+	case "ban", "dban", "sban":
+		return LogCategoryBans
+	case "mute", "dmute", "smute":
+		return LogCategoryMutes
+	case "kick", "dkick", "skick":
+		return LogCategoryKicks
+	case "warn":
+		return LogCategoryWarns
+	}
+	return action
+}
+
+// LogEvent is a single entry posted to a chat's log channel. ChatID, ActorID
+// and Link are filled in by logMessageEvent/logCallbackEvent when left empty.
+type LogEvent struct {
+	Category string
+	Action   string
+	ChatID   int64
+	ActorID  int64
+	TargetID int64
+	Reason   string
+	Duration string
+	Details  string
+	Link     string
+}
+
+// logMessageEvent posts ev for an action triggered by m.
+func logMessageEvent(m *tg.NewMessage, ev *LogEvent) {
+	if ev.ChatID == 0 {
+		ev.ChatID = m.ChatID()
+	}
+	if ev.ActorID == 0 {
+		ev.ActorID = m.SenderID()
+	}
+	if ev.Link == "" {
+		ev.Link = fmt.Sprintf("https://t.me/c/%d/%d", ev.ChatID, m.ID)
+	}
+	go sendLogEvent(m.Client, ev)
+}
+
+// logCallbackEvent posts ev for an action triggered by a button press.
+func logCallbackEvent(c *tg.CallbackQuery, ev *LogEvent) {
+	if ev.ChatID == 0 {
+		ev.ChatID = c.ChatID
+	}
+	if ev.ActorID == 0 {
+		ev.ActorID = c.SenderID
+	}
+	if ev.Link == "" {
+		ev.Link = fmt.Sprintf("https://t.me/c/%d/%d", ev.ChatID, c.MessageID)
+	}
+	go sendLogEvent(c.Client, ev)
+}
+
+func sendLogEvent(client *tg.Client, ev *LogEvent) {
+	logChannel, err := db.GetLogChannel(ev.ChatID)
+	if err != nil || logChannel == nil || !logChannel.Enabled(ev.Category) {
+		return
+	}
+
+	if _, err := client.SendMessage(logChannel.ChannelID, formatLogEvent(client, ev), &tg.SendOptions{LinkPreview: false}); err != nil {
+		log.Printf("Failed to post %s log for %d: %v", ev.Action, ev.ChatID, err)
+	}
+}
+
+func formatLogEvent(client *tg.Client, ev *LogEvent) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "#%s\n", strings.ToUpper(strings.ReplaceAll(ev.Action, " ", "_")))
+
+	chatTitle := fmt.Sprint(ev.ChatID)
+	if channel, err := client.GetChannel(ev.ChatID); err == nil && channel != nil {
+		chatTitle = channel.Title
+	}
+	fmt.Fprintf(&sb, "<b>Chat:</b> %s (<code>%d</code>)\n", html.EscapeString(chatTitle), ev.ChatID)
+
+	if ev.ActorID != 0 {
+		fmt.Fprintf(&sb, "<b>Admin:</b> %s\n", logUserMention(client, ev.ActorID))
+	}
+	if ev.TargetID != 0 {
+		fmt.Fprintf(&sb, "<b>User:</b> %s\n", logUserMention(client, ev.TargetID))
+	}
+	if ev.Duration != "" {
+		fmt.Fprintf(&sb, "<b>Duration:</b> %s\n", ev.Duration)
+	}
+	if ev.Reason != "" {
+		fmt.Fprintf(&sb, "<b>Reason:</b> %s\n", html.EscapeString(ev.Reason))
+	}
+	if ev.Details != "" {
+		fmt.Fprintf(&sb, "<b>Details:</b> %s\n", html.EscapeString(ev.Details))
+	}
+	if ev.Link != "" {
+		fmt.Fprintf(&sb, "<a href=\"%s\">Go to message</a>\n", ev.Link)
+	}
+	fmt.Fprintf(&sb, "<i>%s</i>", time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
+
+	return sb.String()
+}
+
+func logUserMention(client *tg.Client, userID int64) string {
+	name := "User"
+	if user, err := client.GetUser(userID); err == nil && user != nil {
+		name = user.FirstName
+	} else if channel, err := client.GetChannel(userID); err == nil && channel != nil {
+		return fmt.Sprintf("%s (<code>%d</code>)", html.EscapeString(channel.Title), userID)
+	}
+	return fmt.Sprintf("%s (<code>%d</code>)", userMention(userID, name), userID)
+}
+
+// SetLogHandler - /setlog, sent in the log channel and forwarded to the group
+func SetLogHandler(m *tg.NewMessage) error {
+	if m.Channel != nil && m.Channel.Broadcast {
+		// The command posted in the channel itself; it's picked up once forwarded.
+		return nil
+	}
+
+	if m.IsPrivate() {
+		m.Reply("Send /setlog in your log channel and forward it to the group")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info rights to set a log channel")
+		return nil
+	}
+
+	fwd := m.Message.FwdFrom
+	if fwd == nil && m.IsReply() {
+		if reply, err := m.GetReplyMessage(); err == nil {
+			fwd = reply.Message.FwdFrom
+		}
+	}
+
+	var channelID int64
+	if fwd != nil {
+		if peer, ok := fwd.FromID.(*tg.PeerChannel); ok {
+			channelID = peer.ChannelID
+		}
+	}
+
+	if channelID == 0 {
+		m.Reply(`<b>How to set a log channel:</b>
+1. Add me to the channel as an admin with post rights
+2. Send /setlog in the channel
+3. Forward that message here
+
+You can also reply /setlog to any post forwarded from the channel.`)
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), channelID, "") {
+		m.Reply("You need to be an admin of that channel to use it as the log channel")
+		return nil
+	}
+
+	chatTitle := fmt.Sprint(m.ChatID())
+	if m.Channel != nil {
+		chatTitle = m.Channel.Title
+	}
+
+	if _, err := m.Client.SendMessage(channelID, fmt.Sprintf("This channel is now the log channel for <b>%s</b> (<code>%d</code>)", html.EscapeString(chatTitle), m.ChatID())); err != nil {
+		m.Reply("I couldn't post in that channel. Make sure I'm an admin there with post rights.")
+		return nil
+	}
+
+	logChannel := &db.LogChannel{ChannelID: channelID, SetBy: m.SenderID()}
+	if existing, _ := db.GetLogChannel(m.ChatID()); existing != nil {
+		logChannel.Disabled = existing.Disabled
+	}
+
+	if err := db.SetLogChannel(m.ChatID(), logChannel); err != nil {
+		m.Reply("Failed to save log channel")
+		return nil
+	}
+
+	if fwd == m.Message.FwdFrom {
+		m.Delete()
+		m.Respond("Log channel set. Admin actions will now be posted there.\nUse /logsettings to choose what gets logged.")
+		return nil
+	}
+	m.Reply("Log channel set. Admin actions will now be posted there.\nUse /logsettings to choose what gets logged.")
+	return nil
+}
+
+// UnsetLogHandler - /unsetlog
+func UnsetLogHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("This command only works in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info rights to unset the log channel")
+		return nil
+	}
+
+	logChannel, _ := db.GetLogChannel(m.ChatID())
+	if logChannel == nil {
+		m.Reply("No log channel is set for this chat")
+		return nil
+	}
+
+	if err := db.DeleteLogChannel(m.ChatID()); err != nil {
+		m.Reply("Failed to unset log channel")
+		return nil
+	}
+
+	chatTitle := fmt.Sprint(m.ChatID())
+	if m.Channel != nil {
+		chatTitle = m.Channel.Title
+	}
+	m.Client.SendMessage(logChannel.ChannelID, fmt.Sprintf("This channel is no longer the log channel for <b>%s</b>", html.EscapeString(chatTitle)))
+
+	m.Reply("Log channel unset")
+	return nil
+}
+
+// LogSettingsHandler - /logsettings, shows category toggles
+func LogSettingsHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("This command only works in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info rights to change log settings")
+		return nil
+	}
+
+	logChannel, _ := db.GetLogChannel(m.ChatID())
+	if logChannel == nil {
+		m.Reply("No log channel is set. Use /setlog first.")
+		return nil
+	}
+
+	m.Reply(logSettingsText(logChannel), &tg.SendOptions{ReplyMarkup: logSettingsKeyboard(logChannel)})
+	return nil
+}
+
+func logSettingsText(logChannel *db.LogChannel) string {
+	return fmt.Sprintf("<b>Log Channel:</b> <code>%d</code>\n\nTap a category to toggle whether it is logged.", logChannel.ChannelID)
+}
+
+func logSettingsKeyboard(logChannel *db.LogChannel) tg.ReplyMarkup {
+	b := tg.Button
+	kb := tg.NewKeyboard()
+	var row []tg.KeyboardButton
+	for _, category := range logCategories {
+		label := "✅ " + category
+		if !logChannel.Enabled(category) {
+			label = "❌ " + category
+		}
+		row = append(row, b.Data(label, "logcat_"+category))
+		if len(row) == 2 {
+			kb.AddRow(row...)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		kb.AddRow(row...)
+	}
+	return kb.Build()
+}
+
+func LogCategoryCallback(c *tg.CallbackQuery) error {
+	data := c.DataString()
+	if !strings.HasPrefix(data, "logcat_") {
+		return nil
+	}
+
+	if !IsUserAdmin(c.Client, c.SenderID, c.ChatID, "change_info") {
+		c.Answer("You need Change Info rights to change log settings", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	category := strings.TrimPrefix(data, "logcat_")
+	enabled, err := db.ToggleLogCategory(c.ChatID, category)
+	if err != nil {
+		c.Answer("No log channel is set", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	logChannel, _ := db.GetLogChannel(c.ChatID)
+	if logChannel != nil {
+		c.Edit(logSettingsText(logChannel), &tg.SendOptions{ReplyMarkup: logSettingsKeyboard(logChannel)})
+	}
+
+	if enabled {
+		c.Answer(category + " will be logged")
+	} else {
+		c.Answer(category + " will no longer be logged")
+	}
+	return nil
+}
+
+func registerLogChannelHandlers() {
+	c := Client
+	c.On("cmd:setlog", SetLogHandler)
+	c.On("cmd:unsetlog", UnsetLogHandler)
+	c.On("cmd:logsettings", LogSettingsHandler)
+	c.On("callback:logcat_", LogCategoryCallback)
+}
+
+func init() {
+	QueueHandlerRegistration(registerLogChannelHandlers)
+
+	Mods.AddModule("Logs", `<b>Log Channel</b>
+
+Post a record of every admin action to a channel.
+
+<b>Commands:</b>
+/setlog - Bind a log channel (send it in the channel, then forward here)
+/unsetlog - Stop logging to the channel
+/logsettings - Toggle which categories are logged
+
+<b>Categories:</b>
+bans, mutes, kicks, warns, purges, locks, filters, notes, welcome, blacklist
+
+Each entry includes the admin, target, reason, duration and a link to the triggering message.`)
+}
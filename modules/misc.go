@@ -2,7 +2,6 @@ package modules
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -169,45 +168,6 @@ func postToSpaceBin(content string) (string, string, error) {
 	return "https://spaceb.in" + location, "SpaceBin", nil
 }
 
-func Gban(m *telegram.NewMessage) error {
-	user, reason, err := GetUserFromContext(m)
-	if err != nil {
-		m.Reply("Error: " + err.Error())
-		return nil
-	}
-	message, _ := m.Reply("Enforcing global ban...")
-	done := 0
-	m.Client.Broadcast(context.Background(), nil, func(c telegram.Chat) error {
-		_, err := m.Client.EditBanned(c, user, &telegram.BannedOptions{Ban: true})
-		if err == nil {
-			done++
-		}
-		return nil
-	}, 600)
-
-	message.Edit(fmt.Sprintf("Global ban enforced in %d groups.\nReason: %s", done, reason))
-	return nil
-}
-
-func Ungban(m *telegram.NewMessage) error {
-	user, _, err := GetUserFromContext(m)
-	if err != nil {
-		m.Reply("Error: " + err.Error())
-		return nil
-	}
-	message, _ := m.Reply("Removing global ban...")
-	done := 0
-	m.Client.Broadcast(context.Background(), nil, func(c telegram.Chat) error {
-		_, err := m.Client.EditBanned(c, user, &telegram.BannedOptions{Ban: false})
-		if err == nil {
-			done++
-		}
-		return nil
-	}, 600)
-	message.Edit(fmt.Sprintf("Global ban removed in %d groups.", done))
-	return nil
-}
-
 func mathQuery(query string) (string, error) {
 	c := &http.Client{}
 	url := "https://evaluate-expression.p.rapidapi.com/?expression=" + url.QueryEscape(query)
@@ -205,6 +205,7 @@ func SaveNoteHandler(m *tg.NewMessage) error {
 		tagsStr = " [" + strings.Join(tags, ", ") + "]"
 	}
 
+	logMessageEvent(m, &LogEvent{Category: LogCategoryNotes, Action: "save", Details: "#" + noteName + tagsStr})
 	m.Reply(fmt.Sprintf("<b>Note saved:</b> <code>#%s</code>%s", noteName, tagsStr))
 	return nil
 }
@@ -396,6 +397,7 @@ func ClearNoteHandler(m *tg.NewMessage) error {
 		return nil
 	}
 
+	logMessageEvent(m, &LogEvent{Category: LogCategoryNotes, Action: "clear", Details: "#" + noteName})
 	m.Reply(fmt.Sprintf("<b>Note deleted:</b> <code>#%s</code>", noteName))
 	return nil
 }
@@ -460,6 +462,7 @@ func ClearAllNotesCallback(c *tg.CallbackQuery) error {
 			return nil
 		}
 
+		logCallbackEvent(c, &LogEvent{Category: LogCategoryNotes, Action: "clearall", Details: fmt.Sprintf("Removed %d notes", count)})
 		c.Edit(fmt.Sprintf("<b>All notes deleted.</b> Removed %d notes.", count))
 	}
 
@@ -557,6 +560,12 @@ func SaveTempNoteHandler(m *tg.NewMessage) error {
 	}
 
 	expiryTime := time.Now().Add(duration).Format("3:04 PM")
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryNotes,
+		Action:   "tempnote",
+		Duration: formatDuration(duration),
+		Details:  "#" + noteName,
+	})
 	m.Reply(fmt.Sprintf("<b>Temporary note created:</b> <code>#%s</code>\n"+
 		"<b>Expires in:</b> %s (at %s)",
 		noteName, formatDuration(duration), expiryTime))
@@ -747,6 +756,7 @@ func RenameNoteHandler(m *tg.NewMessage) error {
 	// Delete old note
 	db.DeleteNote(m.ChatID(), oldName)
 
+	logMessageEvent(m, &LogEvent{Category: LogCategoryNotes, Action: "rename", Details: fmt.Sprintf("#%s → #%s", oldName, newName)})
 	m.Reply(fmt.Sprintf("<b>Note renamed:</b> <code>#%s</code> → <code>#%s</code>", oldName, newName))
 	return nil
 }
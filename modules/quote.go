@@ -0,0 +1,684 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf16"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const (
+	quoteMaxMessages = 10
+
+	// Layout of a quote at stickerSide width; tall quotes are scaled down
+	// afterwards to fit 512px.
+	quotePad        = 8.0
+	quoteAvatar     = 44.0
+	quoteBubblePad  = 12.0
+	quoteRadius     = 16.0
+	quoteTextSize   = 20.0
+	quoteNameSize   = 18.0
+	quoteReplySize  = 16.0
+	quoteLineHeight = 1.3
+	quoteGroupGap   = 12.0
+	quoteBubbleGap  = 4.0
+)
+
+// quoteNameColors mirrors Telegram's seven name colors, picked by user ID.
+var quoteNameColors = []string{"#FF8E86", "#FFA357", "#B18FFF", "#4DD663", "#45E8D1", "#7AC9FF", "#FF7FD5"}
+
+const (
+	quoteBold = 1 << iota
+	quoteItalic
+	quoteCode
+)
+
+type quoteFaceKey struct {
+	style int
+	size  float64
+}
+
+var (
+	quoteFonts     map[int]*truetype.Font
+	quoteFontsOnce sync.Once
+	quoteFaces     = make(map[quoteFaceKey]font.Face)
+	quoteFacesMu   sync.Mutex
+)
+
+// quoteFace returns the Go font face for a style; code wins over bold and
+// italic since there is no bold mono in use.
+func quoteFace(style int, size float64) font.Face {
+	quoteFontsOnce.Do(func() {
+		quoteFonts = make(map[int]*truetype.Font)
+		for style, ttf := range map[int][]byte{
+			0:                       goregular.TTF,
+			quoteBold:               gobold.TTF,
+			quoteItalic:             goitalic.TTF,
+			quoteBold | quoteItalic: gobolditalic.TTF,
+			quoteCode:               gomono.TTF,
+		} {
+			f, _ := truetype.Parse(ttf)
+			quoteFonts[style] = f
+		}
+	})
+	if style&quoteCode != 0 {
+		style = quoteCode
+	}
+
+	quoteFacesMu.Lock()
+	defer quoteFacesMu.Unlock()
+	key := quoteFaceKey{style, size}
+	if face, ok := quoteFaces[key]; ok {
+		return face
+	}
+	face := truetype.NewFace(quoteFonts[style], &truetype.Options{Size: size})
+	quoteFaces[key] = face
+	return face
+}
+
+func measureText(face font.Face, s string) float64 {
+	return float64(font.MeasureString(face, s)) / 64
+}
+
+// truncateToWidth cuts s with an ellipsis so it fits in width.
+func truncateToWidth(face font.Face, s string, width float64) string {
+	if measureText(face, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && measureText(face, string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+type quoteRun struct {
+	text  string
+	style int
+	x     float64
+}
+
+type quoteLine struct {
+	runs  []quoteRun
+	width float64
+}
+
+// entityRuns splits text into runs of the same bold/italic/code style.
+// Entity offsets are in UTF-16 code units.
+func entityRuns(text string, entities []tg.MessageEntity) []quoteRun {
+	runes := []rune(text)
+	styles := make([]int, len(utf16.Encode(runes)))
+	mark := func(offset, length int32, style int) {
+		for i := max(offset, 0); i < offset+length && int(i) < len(styles); i++ {
+			styles[i] |= style
+		}
+	}
+	for _, e := range entities {
+		switch e := e.(type) {
+		case *tg.MessageEntityBold:
+			mark(e.Offset, e.Length, quoteBold)
+		case *tg.MessageEntityItalic:
+			mark(e.Offset, e.Length, quoteItalic)
+		case *tg.MessageEntityCode:
+			mark(e.Offset, e.Length, quoteCode)
+		case *tg.MessageEntityPre:
+			mark(e.Offset, e.Length, quoteCode)
+		}
+	}
+
+	var runs []quoteRun
+	pos := 0
+	for _, r := range runes {
+		style := styles[pos]
+		pos += len(utf16.Encode([]rune{r}))
+		if n := len(runs); n > 0 && runs[n-1].style == style {
+			runs[n-1].text += string(r)
+			continue
+		}
+		runs = append(runs, quoteRun{text: string(r), style: style})
+	}
+	return runs
+}
+
+// quoteToken is a word, a run of spaces or a newline. A word can span
+// several styles, as in "b<b>old</b>", and is wrapped as one.
+type quoteToken struct {
+	segs    []quoteRun
+	space   bool
+	newline bool
+}
+
+func splitQuoteTokens(runs []quoteRun) []quoteToken {
+	var tokens []quoteToken
+	for _, run := range runs {
+		for _, r := range run.text {
+			if r == '\n' {
+				tokens = append(tokens, quoteToken{newline: true})
+				continue
+			}
+			space := unicode.IsSpace(r)
+			n := len(tokens)
+			if n == 0 || tokens[n-1].newline || tokens[n-1].space != space {
+				tokens = append(tokens, quoteToken{space: space})
+				n++
+			}
+			tok := &tokens[n-1]
+			if k := len(tok.segs); k > 0 && tok.segs[k-1].style == run.style {
+				tok.segs[k-1].text += string(r)
+			} else {
+				tok.segs = append(tok.segs, quoteRun{text: string(r), style: run.style})
+			}
+		}
+	}
+	return tokens
+}
+
+// layoutQuoteText word-wraps styled runs into lines no wider than maxWidth,
+// breaking words that don't fit on a line of their own.
+func layoutQuoteText(runs []quoteRun, maxWidth, size float64) []quoteLine {
+	lines := []quoteLine{{}}
+	place := func(text string, style int) {
+		if text == "" {
+			return
+		}
+		line := &lines[len(lines)-1]
+		line.runs = append(line.runs, quoteRun{text: text, style: style, x: line.width})
+		line.width += measureText(quoteFace(style, size), text)
+	}
+
+	for _, tok := range splitQuoteTokens(runs) {
+		if tok.newline {
+			lines = append(lines, quoteLine{})
+			continue
+		}
+
+		line := &lines[len(lines)-1]
+		if tok.space && line.width == 0 {
+			continue
+		}
+		width := 0.0
+		for _, seg := range tok.segs {
+			width += measureText(quoteFace(seg.style, size), seg.text)
+		}
+		if line.width+width > maxWidth {
+			if tok.space {
+				continue
+			}
+			if line.width > 0 {
+				lines = append(lines, quoteLine{})
+			}
+		}
+		if width <= maxWidth {
+			for _, seg := range tok.segs {
+				place(seg.text, seg.style)
+			}
+			continue
+		}
+
+		// Too long for any line: break it wherever it overflows.
+		for _, seg := range tok.segs {
+			face := quoteFace(seg.style, size)
+			var part []rune
+			for _, r := range seg.text {
+				if line := lines[len(lines)-1]; line.width+measureText(face, string(append(part, r))) > maxWidth && (len(part) > 0 || line.width > 0) {
+					place(string(part), seg.style)
+					lines = append(lines, quoteLine{})
+					part = part[:0]
+				}
+				part = append(part, r)
+			}
+			place(string(part), seg.style)
+		}
+	}
+
+	for len(lines) > 1 && len(lines[len(lines)-1].runs) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+type quoteReply struct {
+	name  string
+	color string
+	text  string
+}
+
+// quoteItem is one message in the quote, already measured.
+type quoteItem struct {
+	senderID int64
+	name     string
+	color    string
+	avatar   image.Image
+	reply    *quoteReply
+	lines    []quoteLine
+
+	showName   bool
+	showAvatar bool
+	width      float64
+	height     float64
+}
+
+func quoteNameColor(id int64) string {
+	if id < 0 {
+		id = -id
+	}
+	return quoteNameColors[id%int64(len(quoteNameColors))]
+}
+
+func quoteSenderName(msg *tg.NewMessage) string {
+	if msg.Sender != nil {
+		name := strings.TrimSpace(msg.Sender.FirstName + " " + msg.Sender.LastName)
+		if name == "" {
+			return "Deleted Account"
+		}
+		return name
+	}
+	if chat := msg.GetSenderChat(); chat != nil {
+		return chat.Title
+	}
+	return "Unknown"
+}
+
+// quoteMediaLabel names the media in a message for the italic placeholder.
+func quoteMediaLabel(msg *tg.NewMessage) string {
+	switch media := msg.Media().(type) {
+	case nil:
+		return ""
+	case *tg.MessageMediaPhoto:
+		return "Photo"
+	case *tg.MessageMediaGeo, *tg.MessageMediaGeoLive, *tg.MessageMediaVenue:
+		return "Location"
+	case *tg.MessageMediaPoll:
+		return "Poll"
+	case *tg.MessageMediaContact:
+		return "Contact"
+	case *tg.MessageMediaDocument:
+		if doc, ok := media.Document.(*tg.DocumentObj); ok {
+			label := "File"
+			for _, attr := range doc.Attributes {
+				switch attr := attr.(type) {
+				case *tg.DocumentAttributeSticker:
+					return "Sticker"
+				case *tg.DocumentAttributeAnimated:
+					return "GIF"
+				case *tg.DocumentAttributeVideo:
+					label = "Video"
+				case *tg.DocumentAttributeAudio:
+					if attr.Voice {
+						return "Voice message"
+					}
+					label = "Audio"
+				}
+			}
+			return label
+		}
+	}
+	return "Media"
+}
+
+// quoteMessageRuns is the message text with a media label in front.
+func quoteMessageRuns(msg *tg.NewMessage) []quoteRun {
+	var runs []quoteRun
+	if label := quoteMediaLabel(msg); label != "" {
+		runs = append(runs, quoteRun{text: label, style: quoteItalic})
+		if msg.Text() != "" {
+			runs = append(runs, quoteRun{text: "\n"})
+		}
+	}
+	return append(runs, entityRuns(msg.Text(), msg.Message.Entities)...)
+}
+
+func quoteReplyText(msg *tg.NewMessage) string {
+	text := strings.Join(strings.Fields(msg.Text()), " ")
+	if label := quoteMediaLabel(msg); label != "" {
+		if text == "" {
+			return label
+		}
+		return label + ", " + text
+	}
+	return text
+}
+
+// fetchQuoteAvatar downloads the sender's current profile photo; channels and
+// users without a photo get nil and are drawn as an initial instead.
+func fetchQuoteAvatar(client *tg.Client, msg *tg.NewMessage) image.Image {
+	if msg.Sender == nil {
+		return nil
+	}
+	photos, err := client.GetProfilePhotos(msg.Sender.ID, &tg.PhotosOptions{Limit: 1})
+	if err != nil || len(photos) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if _, err := client.DownloadMedia(photos[0].Photo, &tg.DownloadOptions{Buffer: &buf}); err != nil {
+		return nil
+	}
+	img, _, err := image.Decode(&buf)
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// fetchQuoteMessages returns the replied message and up to n-1 messages
+// after it, skipping service and deleted messages and stopping before the
+// /q command itself.
+func fetchQuoteMessages(m *tg.NewMessage, first int32, n int) ([]*tg.NewMessage, error) {
+	var ids []int32
+	for id := first; id < m.ID && len(ids) < n*2; id++ {
+		ids = append(ids, id)
+	}
+	fetched, err := m.Client.GetMessages(m.ChatID(), &tg.SearchOption{IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []*tg.NewMessage
+	for i := range fetched {
+		msg := &fetched[i]
+		if msg.Message == nil || msg.Action != nil {
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	slices.SortFunc(msgs, func(a, b *tg.NewMessage) int { return int(a.ID - b.ID) })
+	if len(msgs) > n {
+		msgs = msgs[:n]
+	}
+	return msgs, nil
+}
+
+// buildQuoteItems measures every message and fetches avatars and the
+// messages they reply to.
+func buildQuoteItems(m *tg.NewMessage, msgs []*tg.NewMessage) []*quoteItem {
+	var replyIDs []int32
+	for _, msg := range msgs {
+		if header, ok := msg.Message.ReplyTo.(*tg.MessageReplyHeaderObj); ok && header.ReplyToMsgID != 0 && header.ReplyToPeerID == nil {
+			replyIDs = append(replyIDs, header.ReplyToMsgID)
+		}
+	}
+	replies := make(map[int32]*tg.NewMessage)
+	if len(replyIDs) > 0 {
+		if fetched, err := m.Client.GetMessages(m.ChatID(), &tg.SearchOption{IDs: replyIDs}); err == nil {
+			for i := range fetched {
+				if fetched[i].Message != nil {
+					replies[fetched[i].ID] = &fetched[i]
+				}
+			}
+		}
+	}
+
+	avatars := make(map[int64]image.Image)
+	maxText := stickerSide - quotePad*2 - quoteAvatar - quotePad - quoteBubblePad*2
+	nameFace := quoteFace(quoteBold, quoteNameSize)
+	replyNameFace := quoteFace(quoteBold, quoteReplySize)
+	replyFace := quoteFace(0, quoteReplySize)
+
+	items := make([]*quoteItem, 0, len(msgs))
+	for i, msg := range msgs {
+		item := &quoteItem{
+			senderID: msg.SenderID(),
+			name:     quoteSenderName(msg),
+			lines:    layoutQuoteText(quoteMessageRuns(msg), maxText, quoteTextSize),
+		}
+		item.color = quoteNameColor(item.senderID)
+		item.showName = i == 0 || msgs[i-1].SenderID() != item.senderID
+		item.showAvatar = i == len(msgs)-1 || msgs[i+1].SenderID() != item.senderID
+		if item.showAvatar {
+			if _, ok := avatars[item.senderID]; !ok {
+				avatars[item.senderID] = fetchQuoteAvatar(m.Client, msg)
+			}
+			item.avatar = avatars[item.senderID]
+		}
+
+		if header, ok := msg.Message.ReplyTo.(*tg.MessageReplyHeaderObj); ok {
+			if replied, ok := replies[header.ReplyToMsgID]; ok {
+				item.reply = &quoteReply{
+					name:  truncateToWidth(replyNameFace, quoteSenderName(replied), maxText-10),
+					color: quoteNameColor(replied.SenderID()),
+					text:  truncateToWidth(replyFace, quoteReplyText(replied), maxText-10),
+				}
+			}
+		}
+
+		// Measure the bubble.
+		width, height := 0.0, quoteBubblePad*2
+		if item.showName {
+			item.name = truncateToWidth(nameFace, item.name, maxText)
+			width = measureText(nameFace, item.name)
+			height += quoteNameSize * quoteLineHeight
+		}
+		if item.reply != nil {
+			width = max(width, measureText(replyNameFace, item.reply.name)+10, measureText(replyFace, item.reply.text)+10)
+			height += quoteReplySize*quoteLineHeight*2 + 6
+		}
+		for _, line := range item.lines {
+			width = max(width, line.width)
+		}
+		height += float64(len(item.lines)) * quoteTextSize * quoteLineHeight
+		item.width = width + quoteBubblePad*2
+		item.height = height
+		items = append(items, item)
+	}
+	return items
+}
+
+// renderQuote draws the bubbles onto a transparent canvas, scaled to fit
+// inside 512x512.
+func renderQuote(items []*quoteItem) image.Image {
+	height := quotePad * 2
+	for i, item := range items {
+		height += item.height
+		if i > 0 {
+			if item.showName {
+				height += quoteGroupGap
+			} else {
+				height += quoteBubbleGap
+			}
+		}
+	}
+
+	dc := gg.NewContext(stickerSide, int(height))
+	bubbleX := quotePad + quoteAvatar + quotePad
+	y := quotePad
+	for i, item := range items {
+		if i > 0 {
+			if item.showName {
+				y += quoteGroupGap
+			} else {
+				y += quoteBubbleGap
+			}
+		}
+
+		dc.SetHexColor("#1F2C38")
+		dc.DrawRoundedRectangle(bubbleX, y, item.width, item.height, quoteRadius)
+		dc.Fill()
+
+		cy := y + quoteBubblePad
+		tx := bubbleX + quoteBubblePad
+		if item.showName {
+			dc.SetFontFace(quoteFace(quoteBold, quoteNameSize))
+			dc.SetHexColor(item.color)
+			dc.DrawStringAnchored(item.name, tx, cy+quoteNameSize*quoteLineHeight/2, 0, 0.35)
+			cy += quoteNameSize * quoteLineHeight
+		}
+
+		if item.reply != nil {
+			blockHeight := quoteReplySize * quoteLineHeight * 2
+			dc.SetHexColor(item.reply.color)
+			dc.DrawRectangle(tx, cy, 3, blockHeight)
+			dc.Fill()
+			dc.SetFontFace(quoteFace(quoteBold, quoteReplySize))
+			dc.DrawStringAnchored(item.reply.name, tx+10, cy+quoteReplySize*quoteLineHeight/2, 0, 0.35)
+			dc.SetFontFace(quoteFace(0, quoteReplySize))
+			dc.SetHexColor("#B8C2CC")
+			dc.DrawStringAnchored(item.reply.text, tx+10, cy+quoteReplySize*quoteLineHeight*1.5, 0, 0.35)
+			cy += blockHeight + 6
+		}
+
+		lineHeight := quoteTextSize * quoteLineHeight
+		for _, line := range item.lines {
+			mid := cy + lineHeight/2
+			for _, run := range line.runs {
+				face := quoteFace(run.style, quoteTextSize)
+				if run.style&quoteCode != 0 {
+					dc.SetRGBA(1, 1, 1, 0.1)
+					dc.DrawRoundedRectangle(tx+run.x, cy+2, measureText(face, run.text), lineHeight-4, 4)
+					dc.Fill()
+					dc.SetHexColor("#9AD0FF")
+				} else {
+					dc.SetHexColor("#FFFFFF")
+				}
+				dc.SetFontFace(face)
+				dc.DrawStringAnchored(run.text, tx+run.x, mid, 0, 0.35)
+			}
+			cy += lineHeight
+		}
+
+		if item.showAvatar {
+			drawQuoteAvatar(dc, item, quotePad, y+item.height-quoteAvatar)
+		}
+		y += item.height
+	}
+
+	img := dc.Image()
+	if height <= stickerSide {
+		return img
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, int(stickerSide*stickerSide/height), stickerSide))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return scaled
+}
+
+func drawQuoteAvatar(dc *gg.Context, item *quoteItem, x, y float64) {
+	r := quoteAvatar / 2
+	dc.DrawCircle(x+r, y+r, r)
+	if item.avatar == nil {
+		dc.SetHexColor(item.color)
+		dc.Fill()
+		initial := "?"
+		if runes := []rune(strings.TrimSpace(item.name)); len(runes) > 0 {
+			initial = strings.ToUpper(string(runes[0]))
+		}
+		dc.SetFontFace(quoteFace(quoteBold, quoteTextSize))
+		dc.SetHexColor("#FFFFFF")
+		dc.DrawStringAnchored(initial, x+r, y+r, 0.5, 0.35)
+		return
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, int(quoteAvatar), int(quoteAvatar)))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), item.avatar, item.avatar.Bounds(), draw.Src, nil)
+	dc.Clip()
+	dc.DrawImage(scaled, int(x), int(y))
+	dc.ResetClip()
+}
+
+// saveQuoteSticker writes the quote as WEBP with ffmpeg when it's available;
+// otherwise the PNG is sent under a .webp name, as the doge sticker does.
+func saveQuoteSticker(img image.Image, name string) (string, error) {
+	png := name + ".png"
+	if err := gg.SavePNG(png, img); err != nil {
+		return "", err
+	}
+	out := name + ".webp"
+	if _, err := exec.LookPath("ffmpeg"); err == nil {
+		err := runFFmpeg("-i", png, "-c:v", "libwebp", "-quality", "90", "-y", out)
+		os.Remove(png)
+		return out, err
+	}
+	return out, os.Rename(png, out)
+}
+
+// QuoteHandler - /q [n] [kang], renders the replied message and the next n-1 as a sticker
+func QuoteHandler(m *tg.NewMessage) error {
+	if !m.IsReply() {
+		m.Reply("Reply to a message to quote it.\nUsage: <code>/q [count] [kang]</code>")
+		return nil
+	}
+
+	count, kang := 1, false
+	for _, arg := range strings.Fields(m.Args()) {
+		if n, err := strconv.Atoi(arg); err == nil {
+			count = min(max(n, 1), quoteMaxMessages)
+		} else if strings.EqualFold(arg, "kang") || arg == "-k" {
+			kang = true
+		}
+	}
+
+	msgs, err := fetchQuoteMessages(m, m.ReplyToMsgID(), count)
+	if err != nil || len(msgs) == 0 {
+		m.Reply("Failed to get the messages to quote.")
+		return nil
+	}
+
+	img := renderQuote(buildQuoteItems(m, msgs))
+	out, err := saveQuoteSticker(img, fmt.Sprintf("quote_%d_%d", m.ChatID(), m.ID))
+	if err != nil {
+		m.Reply(fmt.Sprintf("Failed to render quote: %v", err))
+		return nil
+	}
+	defer os.Remove(out)
+
+	m.ReplyMedia(out, &tg.MediaOptions{
+		MimeType: "image/webp",
+		Attributes: []tg.DocumentAttribute{
+			&tg.DocumentAttributeSticker{
+				Alt:        "💬",
+				Stickerset: &tg.InputStickerSetEmpty{},
+			},
+			&tg.DocumentAttributeFilename{
+				FileName: "quote.webp",
+			},
+		},
+	})
+
+	if !kang {
+		return nil
+	}
+	media, err := m.Client.GetSendableMedia(out, &tg.MediaMetadata{Inline: true, ForceDocument: true, MimeType: "image/webp"})
+	if err != nil {
+		m.Reply(fmt.Sprintf("Failed to upload quote for kanging: %v", err))
+		return nil
+	}
+	doc, ok := media.(*tg.InputMediaDocument)
+	if !ok {
+		m.Reply("Failed to upload quote for kanging.")
+		return nil
+	}
+	text, err := kangDocument(m, "normal", doc.ID, "💬")
+	if err != nil {
+		m.Reply(err.Error())
+		return nil
+	}
+	m.Reply(text)
+	return nil
+}
+
+func registerQuoteHandlers() {
+	c := Client
+	c.OnCommand("q", QuoteHandler)
+}
+
+func init() {
+	QueueHandlerRegistration(registerQuoteHandlers)
+}
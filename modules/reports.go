@@ -0,0 +1,342 @@
+package modules
+
+import (
+	"fmt"
+	"html"
+	"main/modules/db"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+// reportCooldown is how long a user waits between reports in the same chat.
+const reportCooldown = 2 * time.Minute
+
+// lastReports maps "chatID:userID" to the time of the user's last report.
+var lastReports sync.Map
+
+// reportActions maps a report button to the status it sets and the admin
+// right it needs.
+var reportActions = map[string]struct {
+	status string
+	right  string
+}{
+	"del":     {db.ReportDeleted, "delete"},
+	"warn":    {db.ReportWarned, "ban"},
+	"mute":    {db.ReportMuted, "ban"},
+	"ban":     {db.ReportBanned, "ban"},
+	"dismiss": {db.ReportDismissed, ""},
+}
+
+func reportKeyboard(r *db.Report) tg.ReplyMarkup {
+	b := tg.Button
+	data := func(action string) string {
+		return fmt.Sprintf("rpt_%s_%d_%d", action, r.ChatID, r.ID)
+	}
+	return tg.NewKeyboard().
+		AddRow(b.Data("Delete", data("del")), b.Data("Warn", data("warn"))).
+		AddRow(b.Data("Mute", data("mute")), b.Data("Ban", data("ban")).Danger()).
+		AddRow(b.Data("Dismiss", data("dismiss"))).
+		Build()
+}
+
+// renderReport is the text admins get, in PM or in the group.
+func renderReport(r *db.Report) string {
+	var sb strings.Builder
+	sb.WriteString("<b>New Report</b>\n\n")
+	if r.ChatTitle != "" {
+		fmt.Fprintf(&sb, "<b>Chat:</b> %s\n", html.EscapeString(r.ChatTitle))
+	}
+	fmt.Fprintf(&sb, "<b>Reported:</b> %s (<code>%d</code>)\n", userMention(r.TargetID, r.TargetName), r.TargetID)
+	fmt.Fprintf(&sb, "<b>By:</b> %s\n", userMention(r.ReporterID, r.ReporterName))
+	if r.Reason != "" {
+		fmt.Fprintf(&sb, "<b>Reason:</b> %s\n", html.EscapeString(r.Reason))
+	}
+	fmt.Fprintf(&sb, "<a href=\"https://t.me/c/%d/%d\">Go to message</a>", r.ChatID, r.MessageID)
+
+	if r.Status != db.ReportOpen {
+		fmt.Fprintf(&sb, "\n\n<b>Resolved:</b> %s by %s", r.Status, userMention(r.ResolvedBy, reportResolverName(r.ResolvedBy)))
+	}
+	return sb.String()
+}
+
+func reportResolverName(userID int64) string {
+	if u, err := Client.GetUser(userID); err == nil && u != nil {
+		return strings.TrimSpace(u.FirstName + " " + u.LastName)
+	}
+	return ""
+}
+
+// ReportHandler - /report [reason], as a reply
+func ReportHandler(m *tg.NewMessage) error {
+	return submitReport(m, strings.TrimSpace(m.Args()))
+}
+
+// AdminsMentionHandler - "@admins [reason]", as a reply
+func AdminsMentionHandler(m *tg.NewMessage) error {
+	_, reason, _ := strings.Cut(m.Text(), " ")
+	return submitReport(m, strings.TrimSpace(reason))
+}
+
+func submitReport(m *tg.NewMessage, reason string) error {
+	if m.IsPrivate() {
+		m.Reply("Reports only work in groups")
+		return nil
+	}
+	if settings, err := db.GetReportSettings(m.ChatID()); err == nil && settings.Disabled {
+		return nil
+	}
+	if !m.IsReply() {
+		m.Reply("Reply to the message you want to report")
+		return nil
+	}
+
+	reply, err := m.GetReplyMessage()
+	if err != nil || reply.Sender == nil {
+		m.Reply("Only messages sent by users can be reported")
+		return nil
+	}
+	targetID := reply.Sender.ID
+	switch {
+	case targetID == m.SenderID():
+		m.Reply("You can't report yourself")
+		return nil
+	case targetID == m.Client.Me().ID:
+		m.Reply("Nice try")
+		return nil
+	case IsUserAdmin(m.Client, targetID, m.ChatID(), ""):
+		m.Reply("Admins can't be reported")
+		return nil
+	}
+
+	key := fmt.Sprintf("%d:%d", m.ChatID(), m.SenderID())
+	if last, ok := lastReports.Load(key); ok {
+		if wait := reportCooldown - time.Since(last.(time.Time)); wait > 0 {
+			m.Reply(fmt.Sprintf("You can report again in %s", formatDuration(wait.Round(time.Second))))
+			return nil
+		}
+	}
+
+	admins, err := chatAdminCache.get(m.Client, m.ChatID())
+	if err != nil {
+		m.Reply("I couldn't fetch the admin list. Please try again.")
+		return nil
+	}
+	lastReports.Store(key, time.Now())
+
+	r := &db.Report{
+		ChatID:     m.ChatID(),
+		MessageID:  reply.ID,
+		TargetID:   targetID,
+		TargetName: strings.TrimSpace(reply.Sender.FirstName + " " + reply.Sender.LastName),
+		ReporterID: m.SenderID(),
+		Reason:     reason,
+		Status:     db.ReportOpen,
+		CreatedAt:  time.Now(),
+	}
+	if m.Sender != nil {
+		r.ReporterName = strings.TrimSpace(m.Sender.FirstName + " " + m.Sender.LastName)
+	}
+	if m.Channel != nil {
+		r.ChatTitle = m.Channel.Title
+	}
+	if err := db.SaveReport(r); err != nil {
+		m.Reply("Failed to save the report")
+		return nil
+	}
+
+	// Admins who never started the bot can't be messaged, so they get an
+	// invisible mention in the group instead.
+	text, kb := renderReport(r), reportKeyboard(r)
+	var notices []db.ReportNotice
+	var unreached strings.Builder
+	for id, p := range admins.members {
+		if p.User == nil || p.User.Bot {
+			continue
+		}
+		sent, err := m.Client.SendMessage(id, text, &tg.SendOptions{ReplyMarkup: kb, LinkPreview: false})
+		if err != nil {
+			fmt.Fprintf(&unreached, "<a href=\"tg://user?id=%d\">\u200b</a>", id)
+			continue
+		}
+		notices = append(notices, db.ReportNotice{ChatID: id, MessageID: sent.ID})
+	}
+
+	groupText := fmt.Sprintf("Reported %s to the admins.", userMention(r.TargetID, r.TargetName)) + unreached.String()
+	if sent, err := reply.Reply(groupText, &tg.SendOptions{ReplyMarkup: kb}); err == nil {
+		notices = append(notices, db.ReportNotice{ChatID: m.ChatID(), MessageID: sent.ID})
+	}
+	db.AddReportNotices(r.ChatID, r.ID, notices)
+	return nil
+}
+
+// updateReportNotices replaces the buttons on every message sent about r
+// with its resolution.
+func updateReportNotices(client *tg.Client, r *db.Report) {
+	resolved := fmt.Sprintf("Report on %s: <b>%s</b> by %s",
+		userMention(r.TargetID, r.TargetName), r.Status, userMention(r.ResolvedBy, reportResolverName(r.ResolvedBy)))
+	full := renderReport(r)
+
+	for _, n := range r.Notices {
+		text := full
+		if n.ChatID == r.ChatID {
+			text = resolved
+		}
+		client.EditMessage(n.ChatID, n.MessageID, text, &tg.SendOptions{LinkPreview: false})
+	}
+}
+
+// ReportCallback handles rpt_<action>_<chatID>_<reportID>, pressed either in
+// the group or in an admin's PM.
+func ReportCallback(c *tg.CallbackQuery) error {
+	parts := strings.Split(strings.TrimPrefix(c.DataString(), "rpt_"), "_")
+	if len(parts) != 3 {
+		return nil
+	}
+	action, ok := reportActions[parts[0]]
+	chatID, err1 := strconv.ParseInt(parts[1], 10, 64)
+	id, err2 := strconv.ParseUint(parts[2], 10, 64)
+	if !ok || err1 != nil || err2 != nil {
+		return nil
+	}
+
+	if !IsUserAdmin(c.Client, c.SenderID, chatID, action.right) {
+		c.Answer("You don't have permission to do this", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	r, claimed, err := db.ResolveReport(chatID, id, action.status, c.SenderID)
+	if err != nil || r == nil {
+		c.Answer("Report not found", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+	if !claimed {
+		c.Answer(fmt.Sprintf("This report was already %s", r.Status), &tg.CallbackOptions{Alert: true})
+		updateReportNotices(c.Client, r)
+		return nil
+	}
+
+	reason := "Reported"
+	if r.Reason != "" {
+		reason += ": " + r.Reason
+	}
+
+	var resultMsg string
+	var opErr error
+	switch action.status {
+	case db.ReportDeleted:
+		_, opErr = c.Client.DeleteMessages(chatID, []int32{r.MessageID})
+	case db.ReportWarned, db.ReportMuted, db.ReportBanned:
+		user, err := c.Client.ResolvePeer(r.TargetID)
+		if err != nil {
+			opErr = err
+			break
+		}
+		switch action.status {
+		case db.ReportWarned:
+			resultMsg, _, opErr = performWarn(c.Client, chatID, user, reason, c.SenderID)
+		case db.ReportMuted:
+			resultMsg, opErr = performMute(c.Client, chatID, user, html.EscapeString(reason), c.SenderID)
+		default:
+			resultMsg, opErr = performBan(c.Client, chatID, user, html.EscapeString(reason), c.SenderID)
+		}
+	}
+
+	if opErr != nil {
+		db.ResolveReport(chatID, id, db.ReportOpen, 0)
+		c.Answer(adminFriendlyError(opErr, parts[0]), &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	if parts[0] == "warn" || parts[0] == "mute" || parts[0] == "ban" {
+		logCallbackEvent(c, &LogEvent{
+			Category: actionLogCategory(parts[0]),
+			Action:   "report " + parts[0],
+			ChatID:   chatID,
+			TargetID: r.TargetID,
+			Reason:   reason,
+			Link:     fmt.Sprintf("https://t.me/c/%d/%d", chatID, r.MessageID),
+		})
+	}
+	if resultMsg != "" {
+		c.Client.SendMessage(chatID, resultMsg)
+	}
+
+	updateReportNotices(c.Client, r)
+	c.Answer("Report " + r.Status)
+	return nil
+}
+
+// ReportsToggleHandler - /reports [on|off]
+func ReportsToggleHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Reports can only be configured in groups")
+		return nil
+	}
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info permission to configure reports")
+		return nil
+	}
+
+	settings, err := db.GetReportSettings(m.ChatID())
+	if err != nil {
+		m.Reply("Failed to read report settings")
+		return nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(m.Args())) {
+	case "":
+		state := "on"
+		if settings.Disabled {
+			state = "off"
+		}
+		m.Reply(fmt.Sprintf("Reports are <b>%s</b> in this chat.\nUse /reports on|off to change it.", state))
+		return nil
+	case "on", "yes", "enable":
+		settings.Disabled = false
+	case "off", "no", "disable":
+		settings.Disabled = true
+	default:
+		m.Reply("Usage: /reports on|off")
+		return nil
+	}
+
+	if err := db.SetReportSettings(m.ChatID(), settings); err != nil {
+		m.Reply("Failed to update report settings")
+		return nil
+	}
+	if settings.Disabled {
+		m.Reply("Reports are now <b>off</b>. /report and @admins will be ignored.")
+	} else {
+		m.Reply("Reports are now <b>on</b>")
+	}
+	return nil
+}
+
+func registerReportHandlers() {
+	c := Client
+	c.On("cmd:report", ReportHandler)
+	c.On("cmd:reports", ReportsToggleHandler)
+	c.On("message:(?i)^@admins?(\\s|$)", AdminsMentionHandler)
+	c.On("callback:rpt_", ReportCallback)
+}
+
+func init() {
+	QueueHandlerRegistration(registerReportHandlers)
+
+	Mods.AddModule("Reports", `<b>Reports</b>
+
+Let members flag messages for the admins.
+
+<b>Commands:</b>
+/report [reason] - Reply to a message to report it
+@admins [reason] - Same as /report
+/reports on|off - Turn reporting on or off (admins)
+
+Every admin gets the report in PM with Delete, Warn, Mute, Ban and Dismiss buttons. Admins who haven't started the bot are mentioned in the group instead.
+
+<b>Note:</b> Admins can't be reported, and each user can report once every 2 minutes.`)
+}
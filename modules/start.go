@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"main/modules/db"
 	"net/http"
 	"net/url"
 	"os"
@@ -284,6 +285,14 @@ func UserHandle(m *telegram.NewMessage) error {
 		}
 	}
 
+	if !m.IsPrivate() {
+		if a, _ := db.GetApproval(m.ChatID(), un.ID); a != nil {
+			userString += fmt.Sprintf("<b>Approved:</b> yes, since %s\n", a.ApprovedAt.Format("2006-01-02"))
+		} else {
+			userString += "<b>Approved:</b> no\n"
+		}
+	}
+
 	if uf.CommonChatsCount > 0 {
 		userString += fmt.Sprintf("<b>Common groups:</b> %d\n", uf.CommonChatsCount)
 	}
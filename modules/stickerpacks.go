@@ -0,0 +1,593 @@
+package modules
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+
+	"main/modules/db"
+
+	tg "github.com/amarnathcjd/gogram/telegram"
+)
+
+const maxPackTitleLength = 64
+
+var packTypes = []string{"normal", "webm", "tgs"}
+
+var packTypeLabels = map[string]string{
+	"normal": "Static",
+	"webm":   "Video",
+	"tgs":    "Animated",
+}
+
+func packLink(pack *db.PackInfo) string {
+	return fmt.Sprintf("<a href='https://t.me/addstickers/%s'>%s</a>", pack.ShortName, html.EscapeString(pack.Title))
+}
+
+// repliedStickerSet fetches the full set the replied sticker belongs to.
+// Errors are meant to be shown to the user as-is.
+func repliedStickerSet(m *tg.NewMessage) (*tg.MessagesStickerSetObj, *tg.DocumentObj, error) {
+	if !m.IsReply() {
+		return nil, nil, fmt.Errorf("Reply to a sticker.")
+	}
+	reply, err := m.GetReplyMessage()
+	if err != nil || reply.Document() == nil {
+		return nil, nil, fmt.Errorf("Reply to a sticker.")
+	}
+
+	document := reply.Document()
+	var set tg.InputStickerSet
+	for _, attr := range document.Attributes {
+		if sticker, ok := attr.(*tg.DocumentAttributeSticker); ok {
+			set = sticker.Stickerset
+		}
+	}
+	if set == nil {
+		return nil, nil, fmt.Errorf("Reply to a sticker.")
+	}
+	if _, ok := set.(*tg.InputStickerSetEmpty); ok {
+		return nil, nil, fmt.Errorf("That sticker isn't in a pack.")
+	}
+
+	result, err := m.Client.MessagesGetStickerSet(set, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get sticker pack: %w", err)
+	}
+	resp, ok := result.(*tg.MessagesStickerSetObj)
+	if !ok {
+		return nil, nil, fmt.Errorf("Failed to get sticker pack.")
+	}
+	return resp, document, nil
+}
+
+// repliedUserPack returns the pack the replied sticker belongs to, if it is
+// one of the sender's packs, along with the sticker itself. Errors are meant
+// to be shown to the user as-is.
+func repliedUserPack(m *tg.NewMessage) (*db.PackInfo, tg.InputDocument, error) {
+	set, document, err := repliedStickerSet(m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pack, err := db.GetPackByShortName(m.SenderID(), set.Set.ShortName)
+	if err != nil || pack == nil {
+		return nil, nil, fmt.Errorf("That sticker isn't in one of your packs.")
+	}
+
+	doc := &tg.InputDocumentObj{
+		ID:            document.ID,
+		AccessHash:    document.AccessHash,
+		FileReference: document.FileReference,
+	}
+	return pack, doc, nil
+}
+
+// userPacksText lists a user's packs per type, marking the active ones, with
+// a button to switch to each of the others.
+func userPacksText(userID int64) (string, *tg.ReplyInlineMarkup) {
+	packs, err := db.GetUserPacks(userID)
+	if err != nil {
+		return "Failed to load your sticker packs.", nil
+	}
+
+	b := tg.Button
+	var sb strings.Builder
+	var buttons []tg.KeyboardButton
+	total := 0
+
+	sb.WriteString("<b>Your sticker packs</b>\n")
+	for _, packType := range packTypes {
+		list := packs[packType]
+		if len(list) == 0 {
+			continue
+		}
+		total += len(list)
+
+		active, _ := db.GetActivePack(userID, packType)
+		fmt.Fprintf(&sb, "\n<b>%s</b>\n", packTypeLabels[packType])
+		for _, pack := range list {
+			if active != nil && active.PackNumber == pack.PackNumber {
+				fmt.Fprintf(&sb, "✅ %s - %d/%d\n", packLink(pack), pack.StickerCount, MaxStickersPerPack)
+				continue
+			}
+			fmt.Fprintf(&sb, "• %s - %d/%d\n", packLink(pack), pack.StickerCount, MaxStickersPerPack)
+			buttons = append(buttons, b.Data(
+				fmt.Sprintf("%s #%d", packTypeLabels[packType], pack.PackNumber),
+				fmt.Sprintf("setpack_%d_%s_%d", userID, packType, pack.PackNumber),
+			))
+		}
+	}
+
+	if total == 0 {
+		return "You don't have any sticker packs yet. Reply to a sticker with /kang to start one.", nil
+	}
+
+	sb.WriteString("\n✅ marks the pack /kang adds to.")
+	if len(buttons) == 0 {
+		return sb.String(), nil
+	}
+	sb.WriteString(" Tap a pack below to switch.")
+	return sb.String(), tg.NewKeyboard().NewColumn(2, buttons...).Build()
+}
+
+// MyPacksHandler - /mypacks
+func MyPacksHandler(m *tg.NewMessage) error {
+	text, markup := userPacksText(m.SenderID())
+	if markup == nil {
+		m.Reply(text, &tg.SendOptions{LinkPreview: false})
+		return nil
+	}
+	m.Reply(text, &tg.SendOptions{LinkPreview: false, ReplyMarkup: markup})
+	return nil
+}
+
+func SetActivePackCallback(c *tg.CallbackQuery) error {
+	parts := strings.Split(strings.TrimPrefix(c.DataString(), "setpack_"), "_")
+	if len(parts) != 3 {
+		return nil
+	}
+	if fmt.Sprint(c.SenderID) != parts[0] {
+		c.Answer("Not for you", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	userID := c.SenderID
+	packType := parts[1]
+	packNumber, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil
+	}
+
+	packs, _ := db.GetUserPacks(userID)
+	var pack *db.PackInfo
+	for _, p := range packs[packType] {
+		if p.PackNumber == packNumber {
+			pack = p
+		}
+	}
+	if pack == nil {
+		c.Answer("That pack no longer exists", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	if err := db.SetActivePack(userID, packType, packNumber); err != nil {
+		c.Answer("Failed to switch packs", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	c.Answer(fmt.Sprintf("Now kanging %s stickers into %s", strings.ToLower(packTypeLabels[packType]), pack.Title))
+	text, markup := userPacksText(userID)
+	if markup == nil {
+		c.Edit(text, &tg.SendOptions{LinkPreview: false})
+		return nil
+	}
+	c.Edit(text, &tg.SendOptions{LinkPreview: false, ReplyMarkup: markup})
+	return nil
+}
+
+// NewPackHandler - /newpack <title>, as a reply to the pack's first sticker
+func NewPackHandler(m *tg.NewMessage) error {
+	title := strings.TrimSpace(m.Args())
+	if !m.IsReply() || title == "" {
+		m.Reply("Reply to a sticker, photo, video or GIF to start a new pack with it.\nUsage: <code>/newpack &lt;title&gt;</code>")
+		return nil
+	}
+	if len([]rune(title)) > maxPackTitleLength {
+		m.Reply(fmt.Sprintf("Pack titles can be at most %d characters.", maxPackTitleLength))
+		return nil
+	}
+
+	reply, err := m.GetReplyMessage()
+	if err != nil {
+		m.Reply("Failed to get replied message.")
+		return nil
+	}
+
+	src, err := getKangSource(reply, "")
+	if err != nil {
+		m.Reply(fmt.Sprintf("<b>Can't use that:</b> %v", err))
+		return nil
+	}
+
+	doc, err := prepareKangDocument(m, src)
+	if err != nil {
+		m.Reply(fmt.Sprintf("<b>Can't use that:</b> %v", err))
+		return nil
+	}
+
+	pack, err := createStickerPack(m, src.packType, title, doc, src.emoji)
+	if err != nil {
+		m.Reply(fmt.Sprintf("Failed to create sticker pack: %v", err))
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("<b>Created %s!</b>\nNew %s stickers from /kang go here now.",
+		packLink(pack), strings.ToLower(packTypeLabels[pack.Type])))
+	return nil
+}
+
+// RenamePackHandler - /renamepack <title>, as a reply to a sticker in the pack
+func RenamePackHandler(m *tg.NewMessage) error {
+	title := strings.TrimSpace(m.Args())
+	if title == "" {
+		m.Reply("Reply to a sticker from your pack.\nUsage: <code>/renamepack &lt;title&gt;</code>")
+		return nil
+	}
+	if len([]rune(title)) > maxPackTitleLength {
+		m.Reply(fmt.Sprintf("Pack titles can be at most %d characters.", maxPackTitleLength))
+		return nil
+	}
+
+	pack, _, err := repliedUserPack(m)
+	if err != nil {
+		m.Reply(err.Error())
+		return nil
+	}
+
+	if _, err := m.Client.StickersRenameStickerSet(&tg.InputStickerSetShortName{ShortName: pack.ShortName}, title); err != nil {
+		m.Reply(fmt.Sprintf("Failed to rename pack: %v", err))
+		return nil
+	}
+
+	old := pack.Title
+	pack.Title = title
+	db.SavePack(m.SenderID(), pack)
+
+	m.Reply(fmt.Sprintf("Renamed <b>%s</b> to %s.", html.EscapeString(old), packLink(pack)))
+	return nil
+}
+
+// MoveStickerHandler - /movesticker <position>, as a reply to a sticker in the pack
+func MoveStickerHandler(m *tg.NewMessage) error {
+	position, err := strconv.Atoi(strings.TrimSpace(m.Args()))
+	if err != nil || position < 1 {
+		m.Reply("Reply to a sticker from your pack.\nUsage: <code>/movesticker &lt;position&gt;</code> (1 is first)")
+		return nil
+	}
+
+	pack, doc, err := repliedUserPack(m)
+	if err != nil {
+		m.Reply(err.Error())
+		return nil
+	}
+	if pack.StickerCount > 0 && position > pack.StickerCount {
+		m.Reply(fmt.Sprintf("%s only has %d stickers.", packLink(pack), pack.StickerCount))
+		return nil
+	}
+
+	if _, err := m.Client.StickersChangeStickerPosition(doc, int32(position-1)); err != nil {
+		m.Reply(fmt.Sprintf("Failed to move sticker: %v", err))
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("Moved sticker to position %d in %s.", position, packLink(pack)))
+	return nil
+}
+
+// DeletePackHandler - /delpack [short name], or as a reply to a sticker in the pack
+func DeletePackHandler(m *tg.NewMessage) error {
+	var pack *db.PackInfo
+	if shortName := strings.TrimSpace(m.Args()); shortName != "" {
+		shortName = strings.TrimPrefix(shortName, "https://t.me/addstickers/")
+		p, err := db.GetPackByShortName(m.SenderID(), shortName)
+		if err != nil || p == nil {
+			m.Reply("That isn't one of your packs. Check /mypacks.")
+			return nil
+		}
+		pack = p
+	} else {
+		p, _, err := repliedUserPack(m)
+		if err != nil {
+			m.Reply("Reply to a sticker from the pack, or give its short name.\nUsage: <code>/delpack [short name]</code>")
+			return nil
+		}
+		pack = p
+	}
+
+	b := tg.Button
+	m.Reply(
+		fmt.Sprintf("<b>Delete %s?</b>\n\nAll %d stickers in it will be gone for everyone. This cannot be undone.", packLink(pack), pack.StickerCount),
+		&tg.SendOptions{
+			LinkPreview: false,
+			ReplyMarkup: tg.NewKeyboard().AddRow(
+				b.Data("Delete", fmt.Sprintf("delpack_%d_%s_%d", m.SenderID(), pack.Type, pack.PackNumber)),
+				b.Data("Cancel", fmt.Sprintf("canceldelpack_%d", m.SenderID())),
+			).Build(),
+		},
+	)
+	return nil
+}
+
+func DeletePackCallback(c *tg.CallbackQuery) error {
+	data := c.DataString()
+
+	if after, ok := strings.CutPrefix(data, "canceldelpack_"); ok {
+		if fmt.Sprint(c.SenderID) != after {
+			c.Answer("Not for you", &tg.CallbackOptions{Alert: true})
+			return nil
+		}
+		c.Edit("<b>Cancelled.</b>")
+		return nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(data, "delpack_"), "_")
+	if len(parts) != 3 {
+		return nil
+	}
+	if fmt.Sprint(c.SenderID) != parts[0] {
+		c.Answer("Not for you", &tg.CallbackOptions{Alert: true})
+		return nil
+	}
+
+	userID := c.SenderID
+	packType := parts[1]
+	packNumber, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil
+	}
+
+	packs, _ := db.GetUserPacks(userID)
+	var pack *db.PackInfo
+	for _, p := range packs[packType] {
+		if p.PackNumber == packNumber {
+			pack = p
+		}
+	}
+	if pack == nil {
+		c.Edit("<b>That pack was already deleted.</b>")
+		return nil
+	}
+
+	if _, err := c.Client.StickersDeleteStickerSet(&tg.InputStickerSetShortName{ShortName: pack.ShortName}); err != nil && !isStickerSetMissing(err) {
+		c.Edit(fmt.Sprintf("Failed to delete pack: %v", err))
+		return nil
+	}
+	if err := db.DeletePack(userID, packType, packNumber); err != nil {
+		c.Edit(fmt.Sprintf("Pack deleted, but failed to forget it: %v", err))
+		return nil
+	}
+
+	c.Edit(fmt.Sprintf("<b>Deleted</b> %s.", html.EscapeString(pack.Title)))
+	return nil
+}
+
+func isStickerSetMissing(err error) bool {
+	return strings.Contains(err.Error(), "STICKERSET_INVALID")
+}
+
+// SyncPacksHandler - /syncpacks, reloads titles and sticker counts from Telegram
+func SyncPacksHandler(m *tg.NewMessage) error {
+	userID := m.SenderID()
+	packs, err := db.GetUserPacks(userID)
+	if err != nil {
+		m.Reply("Failed to load your sticker packs.")
+		return nil
+	}
+
+	msg, _ := m.Reply("Syncing sticker packs...")
+
+	var sb strings.Builder
+	checked, changed, removed := 0, 0, 0
+	for _, packType := range packTypes {
+		for _, pack := range packs[packType] {
+			checked++
+			result, err := m.Client.MessagesGetStickerSet(&tg.InputStickerSetShortName{ShortName: pack.ShortName}, 0)
+			if err != nil {
+				if isStickerSetMissing(err) {
+					db.DeletePack(userID, packType, pack.PackNumber)
+					removed++
+					fmt.Fprintf(&sb, "• %s - no longer exists, removed\n", html.EscapeString(pack.Title))
+				} else {
+					fmt.Fprintf(&sb, "• %s - failed: %v\n", html.EscapeString(pack.Title), err)
+				}
+				continue
+			}
+			resp, ok := result.(*tg.MessagesStickerSetObj)
+			if !ok {
+				continue
+			}
+
+			count := len(resp.Documents)
+			if count == pack.StickerCount && resp.Set.Title == pack.Title {
+				continue
+			}
+			fmt.Fprintf(&sb, "• %s - %d → %d stickers\n", packLink(pack), pack.StickerCount, count)
+			pack.StickerCount = count
+			pack.Title = resp.Set.Title
+			db.SavePack(userID, pack)
+			changed++
+		}
+	}
+
+	if checked == 0 {
+		msg.Edit("You don't have any sticker packs yet.")
+		return nil
+	}
+
+	text := fmt.Sprintf("<b>Synced %d packs</b> (%d updated, %d removed)", checked, changed, removed)
+	if sb.Len() > 0 {
+		text += "\n\n" + sb.String()
+	}
+	msg.Edit(splitLines(text, 4000)[0], &tg.SendOptions{LinkPreview: false})
+	return nil
+}
+
+// clonePackProgressEvery is how many stickers are copied between progress
+// message edits.
+const clonePackProgressEvery = 10
+
+// cloning holds the users with a /clonepack running, so a second one can't
+// race the first for pack numbers.
+var cloning sync.Map
+
+// stickerDocType is the pack type a sticker from an existing set belongs in.
+func stickerDocType(doc *tg.DocumentObj) string {
+	switch doc.MimeType {
+	case "application/x-tgsticker":
+		return "tgs"
+	case "video/webm":
+		return "webm"
+	}
+	return "normal"
+}
+
+// ClonePackHandler - /clonepack, as a reply to a sticker from the pack to copy
+func ClonePackHandler(m *tg.NewMessage) error {
+	set, _, err := repliedStickerSet(m)
+	if err != nil {
+		m.Reply(err.Error() + "\nUsage: reply to a sticker with <code>/clonepack</code>")
+		return nil
+	}
+	if set.Set.Masks || set.Set.Emojis {
+		m.Reply("Mask and custom emoji packs can't be cloned.")
+		return nil
+	}
+
+	userID := m.SenderID()
+	if _, running := cloning.LoadOrStore(userID, true); running {
+		m.Reply("You already have a pack being cloned, wait for it to finish.")
+		return nil
+	}
+	defer cloning.Delete(userID)
+
+	// Every sticker keeps all the emojis it had in the source set.
+	emojis := make(map[int64]string)
+	for _, p := range set.Packs {
+		for _, id := range p.Documents {
+			emojis[id] += p.Emoticon
+		}
+	}
+
+	// Sets can mix static, video and animated stickers, which need separate
+	// packs here; each type is split into packs of MaxStickersPerPack.
+	byType := make(map[string][]*tg.DocumentObj)
+	total := 0
+	for _, d := range set.Documents {
+		doc, ok := d.(*tg.DocumentObj)
+		if !ok {
+			continue
+		}
+		packType := stickerDocType(doc)
+		byType[packType] = append(byType[packType], doc)
+		total++
+	}
+	if total == 0 {
+		m.Reply("That pack has no stickers to clone.")
+		return nil
+	}
+
+	type chunk struct {
+		packType string
+		docs     []*tg.DocumentObj
+	}
+	var chunks []chunk
+	for _, packType := range packTypes {
+		docs := byType[packType]
+		for len(docs) > 0 {
+			n := min(len(docs), MaxStickersPerPack)
+			chunks = append(chunks, chunk{packType, docs[:n]})
+			docs = docs[n:]
+		}
+	}
+
+	setTitle := html.EscapeString(set.Set.Title)
+	progress, _ := m.Reply(fmt.Sprintf("Cloning <b>%s</b>: 0/%d stickers...", setTitle, total))
+
+	var created []*db.PackInfo
+	done, failed := 0, 0
+	for i, ch := range chunks {
+		title := set.Set.Title
+		if len(chunks) > 1 {
+			suffix := fmt.Sprintf(" (%d)", i+1)
+			if runes := []rune(title); len(runes)+len(suffix) > maxPackTitleLength {
+				title = string(runes[:maxPackTitleLength-len(suffix)])
+			}
+			title += suffix
+		}
+
+		var pack *db.PackInfo
+		for _, doc := range ch.docs {
+			input := &tg.InputDocumentObj{ID: doc.ID, AccessHash: doc.AccessHash, FileReference: doc.FileReference}
+			emoji := emojis[doc.ID]
+			if emoji == "" {
+				emoji = "👍"
+			}
+
+			if pack == nil {
+				pack, err = createStickerPack(m, ch.packType, title, input, emoji)
+				if err != nil {
+					progress.Edit(fmt.Sprintf("Failed to create sticker pack: %v\n\nCopied %d/%d stickers before stopping.", err, done, total))
+					return nil
+				}
+				created = append(created, pack)
+			} else {
+				_, err := m.Client.StickersAddStickerToSet(&tg.InputStickerSetShortName{ShortName: pack.ShortName}, &tg.InputStickerSetItem{
+					Document: input,
+					Emoji:    emoji,
+				})
+				if err != nil {
+					failed++
+					continue
+				}
+				db.IncrementPackCount(userID, pack)
+			}
+
+			done++
+			if done%clonePackProgressEvery == 0 {
+				progress.Edit(fmt.Sprintf("Cloning <b>%s</b>: %d/%d stickers...", setTitle, done, total))
+			}
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<b>Cloned %s</b> - %d/%d stickers\n\n", setTitle, done, total)
+	for _, pack := range created {
+		fmt.Fprintf(&sb, "• %s - %d/%d\n", packLink(pack), pack.StickerCount, MaxStickersPerPack)
+	}
+	if failed > 0 {
+		fmt.Fprintf(&sb, "\n%d stickers couldn't be added.", failed)
+	}
+	sb.WriteString("\nNew /kang stickers go into the last pack of each type.")
+	progress.Edit(sb.String(), &tg.SendOptions{LinkPreview: false})
+	return nil
+}
+
+func registerStickerPackHandlers() {
+	c := Client
+	c.OnCommand("mypacks", MyPacksHandler)
+	c.OnCommand("newpack", NewPackHandler)
+	c.OnCommand("renamepack", RenamePackHandler)
+	c.OnCommand("movesticker", MoveStickerHandler)
+	c.OnCommand("delpack", DeletePackHandler)
+	c.OnCommand("syncpacks", SyncPacksHandler)
+	c.OnCommand("clonepack", ClonePackHandler)
+	c.On("callback:setpack_", SetActivePackCallback)
+	c.On("callback:delpack_", DeletePackCallback)
+	c.On("callback:canceldelpack_", DeletePackCallback)
+}
+
+func init() {
+	QueueHandlerRegistration(registerStickerPackHandlers)
+}
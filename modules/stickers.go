@@ -2,9 +2,10 @@ package modules
 
 import (
 	"fmt"
+	"html"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
 
 	"main/modules/db"
@@ -14,9 +15,10 @@ import (
 
 const MaxStickersPerPack = 120
 
+// GifToSticker - /gif, sends the replied GIF or video back as a video sticker
 func GifToSticker(m *tg.NewMessage) error {
 	if !m.IsReply() {
-		m.Reply("<b>Error:</b> Please reply to a GIF message to convert it to a sticker.")
+		m.Reply("<b>Error:</b> Please reply to a GIF or video to convert it to a sticker.")
 		return nil
 	}
 
@@ -26,39 +28,35 @@ func GifToSticker(m *tg.NewMessage) error {
 		return nil
 	}
 
-	if !r.IsMedia() {
-		m.Reply("<b>Error:</b> The replied message is not a GIF.")
-		return nil
+	src, err := getKangSource(r, "")
+	if err == nil && src.packType != "webm" {
+		err = fmt.Errorf("that isn't a GIF or video")
 	}
-
-	fn := ""
-	if r.File != nil {
-		fn = r.File.Name
+	if err != nil {
+		m.Reply(fmt.Sprintf("<b>Error:</b> %v", err))
+		return nil
 	}
-
-	if fn != "" {
-		lfn := strings.ToLower(fn)
-		if !(strings.HasSuffix(lfn, ".mp4") || strings.HasSuffix(lfn, ".gif")) {
-			m.Reply("Invalid media: only .mp4 or .gif files are supported.")
-			return nil
-		}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		m.Reply("<b>Error:</b> ffmpeg is not installed on the server.")
+		return nil
 	}
 
-	fi, err := r.Download(&tg.DownloadOptions{
-		FileName: "gif.gif",
-	})
+	fi, err := m.Client.DownloadMedia(src.media)
 	if err != nil {
 		m.Reply("<b>Error:</b> Unable to download the GIF.")
 		return nil
 	}
+	defer os.Remove(fi)
 
-	out := "sticker.webm"
-	defer os.Remove("gif.gif")
+	out, err := convertVideoSticker(fi)
+	if err != nil {
+		m.Reply(fmt.Sprintf("<b>Error:</b> %v", err))
+		return nil
+	}
 	defer os.Remove(out)
 
-	cmd := exec.Command("ffmpeg", "-i", fi, "-vf", "format=yuv420p", "-c:v", "libvpx-vp9", "-b:v", "0", "-crf", "30", "-an", "-y", out)
-	_ = cmd.Run()
-	m.ReplyMedia("sticker.webm", &tg.MediaOptions{
+	m.ReplyMedia(out, &tg.MediaOptions{
+		MimeType: "video/webm",
 		Attributes: []tg.DocumentAttribute{
 			&tg.DocumentAttributeSticker{
 				Alt:        "😍",
@@ -73,272 +71,297 @@ func GifToSticker(m *tg.NewMessage) error {
 	return nil
 }
 
-func KangSticker(m *tg.NewMessage) error {
-	if !m.IsReply() {
-		m.Reply("Reply to a sticker to kang it!\nUsage: <code>/kang [emoji]</code>")
-		return nil
-	}
+const (
+	stickerSide             = 512
+	maxStaticStickerBytes   = 512 << 10
+	maxVideoStickerBytes    = 256 << 10
+	maxAnimatedStickerBytes = 64 << 10
+	maxVideoStickerSeconds  = 3
+	maxKangSourceBytes      = 50 << 20
+)
 
-	reply, err := m.GetReplyMessage()
-	if err != nil {
-		m.Reply("Failed to get replied message.")
+// kangSource is the media a /kang or /newpack was replied to: a sticker,
+// photo, image document, video or GIF.
+type kangSource struct {
+	media    tg.MessageMedia
+	packType string
+	emoji    string
+}
 
+// getKangSource picks the pack type for the replied media and the emoji to
+// use. An emoji passed as the command argument wins over a sticker's own.
+// Errors are meant to be shown to the user.
+func getKangSource(reply *tg.NewMessage, emoji string) (*kangSource, error) {
+	src := &kangSource{media: reply.Media(), emoji: emoji}
+
+	switch reply.Media().(type) {
+	case *tg.MessageMediaPhoto:
+		src.packType = "normal"
+	case *tg.MessageMediaDocument:
+		document := reply.Document()
+		if document == nil {
+			return nil, fmt.Errorf("that file is no longer available")
+		}
+		if document.Size > maxKangSourceBytes {
+			return nil, fmt.Errorf("that file is %s, the limit is %s", formatBytes(document.Size), formatBytes(maxKangSourceBytes))
+		}
+
+		animated := false
+		for _, attr := range document.Attributes {
+			switch attr := attr.(type) {
+			case *tg.DocumentAttributeSticker:
+				if src.emoji == "" && attr.Alt != "" {
+					src.emoji = attr.Alt
+				}
+			case *tg.DocumentAttributeAnimated:
+				animated = true
+			}
+		}
+
+		mime := document.MimeType
+		switch {
+		case mime == "application/x-tgsticker":
+			src.packType = "tgs"
+		case animated, mime == "image/gif", strings.HasPrefix(mime, "video/"):
+			src.packType = "webm"
+		case strings.HasPrefix(mime, "image/"):
+			src.packType = "normal"
+		default:
+			return nil, fmt.Errorf("can't make a sticker from %s files, send a photo, image, video or GIF", mime)
+		}
+	default:
+		return nil, fmt.Errorf("reply to a sticker, photo, video or GIF")
 	}
 
-	if !reply.IsMedia() {
-		m.Reply("Please reply to a sticker!")
+	if src.emoji == "" {
+		src.emoji = "👍"
 	}
+	return src, nil
+}
 
-	var packType string
-	var emoji string = "👍"
+// prepareKangDocument downloads the source, converts it to meet the sticker
+// limits for its pack type and uploads it so it can be added to a set.
+func prepareKangDocument(m *tg.NewMessage, src *kangSource) (tg.InputDocument, error) {
+	if src.packType != "tgs" {
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			return nil, fmt.Errorf("ffmpeg is not installed on the server")
+		}
+	}
 
-	args := m.Args()
-	if len(args) > 0 {
-		emoji = args
+	fi, err := m.Client.DownloadMedia(src.media)
+	if err != nil {
+		return nil, fmt.Errorf("download: %w", err)
 	}
+	defer os.Remove(fi)
 
-	var stickerFile struct {
-		ID            int64
-		AccessHash    int64
-		FileReference []byte
-		Type          string
-		fi            tg.MessageMedia
+	var out, mime string
+	switch src.packType {
+	case "tgs":
+		// Animated stickers can't be converted, only copied as they are.
+		if info, err := os.Stat(fi); err == nil && info.Size() > maxAnimatedStickerBytes {
+			return nil, fmt.Errorf("animated sticker is %s, over the %s limit", formatBytes(info.Size()), formatBytes(maxAnimatedStickerBytes))
+		}
+		out, mime = fi, "application/x-tgsticker"
+	case "webm":
+		out, err = convertVideoSticker(fi)
+		mime = "video/webm"
+	default:
+		out, err = convertStaticSticker(fi)
+		mime = "image/png"
+		if strings.HasSuffix(out, ".webp") {
+			mime = "image/webp"
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if out != fi {
+		defer os.Remove(out)
 	}
 
-	if reply.Media() != nil {
-		stickerFile.fi = reply.Media()
-		if doc, ok := reply.Media().(*tg.MessageMediaDocument); ok {
-			if document, ok := doc.Document.(*tg.DocumentObj); ok {
-				for _, attr := range document.Attributes {
-					if stickerAttr, ok := attr.(*tg.DocumentAttributeSticker); ok {
-						if emoji == "👍" && stickerAttr.Alt != "" {
-							emoji = stickerAttr.Alt
-						}
-					}
-					if _, ok := attr.(*tg.DocumentAttributeVideo); ok {
-						packType = "webm"
-					}
-					if fileName, ok := attr.(*tg.DocumentAttributeFilename); ok {
-						if strings.HasSuffix(fileName.FileName, ".tgs") {
-							packType = "tgs"
-						}
-					}
-				}
+	media, err := m.Client.GetSendableMedia(out, &tg.MediaMetadata{Inline: true, ForceDocument: true, MimeType: mime})
+	if err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+	doc, ok := media.(*tg.InputMediaDocument)
+	if !ok {
+		return nil, fmt.Errorf("upload: unexpected media type %T", media)
+	}
+	return doc.ID, nil
+}
 
-				if packType == "" {
-					if strings.Contains(document.MimeType, "video") {
-						packType = "webm"
-					} else if strings.Contains(document.MimeType, "application/x-tgsticker") {
-						packType = "tgs"
-					} else {
-						packType = "normal"
-					}
-				}
+// stickerScale fits the longer side to 512px, which is what Telegram wants
+// for both static and video stickers; round is -1 for any height or -2 to
+// keep it even for yuv420 video.
+func stickerScale(round int) string {
+	return fmt.Sprintf("scale='if(gte(iw,ih),%[1]d,%[2]d)':'if(gte(iw,ih),%[2]d,%[1]d)'", stickerSide, round)
+}
 
-				stickerFile.ID = document.ID
-				stickerFile.AccessHash = document.AccessHash
-				stickerFile.FileReference = document.FileReference
-				stickerFile.Type = packType
-			}
+// convertStaticSticker writes a 512px PNG, falling back to WEBP when the PNG
+// is over the size limit.
+func convertStaticSticker(in string) (string, error) {
+	out := in + "_sticker.png"
+	if err := runFFmpeg("-i", in, "-vf", stickerScale(-1), "-frames:v", "1", "-y", out); err != nil {
+		return "", err
+	}
+	if size := fileSize(out); size <= maxStaticStickerBytes {
+		return out, nil
+	}
+	os.Remove(out)
+
+	out = in + "_sticker.webp"
+	if err := runFFmpeg("-i", in, "-vf", stickerScale(-1), "-frames:v", "1", "-c:v", "libwebp", "-quality", "80", "-y", out); err != nil {
+		return "", err
+	}
+	if size := fileSize(out); size > maxStaticStickerBytes {
+		os.Remove(out)
+		return "", fmt.Errorf("image is still %s after compression, over the %s limit", formatBytes(size), formatBytes(maxStaticStickerBytes))
+	}
+	return out, nil
+}
+
+// convertVideoSticker writes a VP9 WEBM cut to 3 seconds at 30fps with no
+// audio, raising the CRF until it fits in 256 KB.
+func convertVideoSticker(in string) (string, error) {
+	out := in + "_sticker.webm"
+	var size int64
+	for _, crf := range []string{"30", "40", "50"} {
+		err := runFFmpeg("-i", in, "-t", strconv.Itoa(maxVideoStickerSeconds),
+			"-vf", stickerScale(-2)+",fps=30", "-c:v", "libvpx-vp9", "-pix_fmt", "yuva420p",
+			"-b:v", "0", "-crf", crf, "-an", "-y", out)
+		if err != nil {
+			os.Remove(out)
+			return "", err
 		}
-		if reply.Document().MimeType == "application/x-tgsticker" {
-			packType = "tgs"
-		} else if strings.HasPrefix(reply.Document().MimeType, "video/") {
-			packType = "webm"
-		} else {
-			packType = "normal"
+		if size = fileSize(out); size <= maxVideoStickerBytes {
+			return out, nil
 		}
 	}
+	os.Remove(out)
+	return "", fmt.Errorf("video is still %s after compression, over the %s limit; try a shorter or simpler clip", formatBytes(size), formatBytes(maxVideoStickerBytes))
+}
+
+// runFFmpeg runs ffmpeg and turns a failure into the last line it printed.
+func runFFmpeg(args ...string) error {
+	output, err := exec.Command("ffmpeg", append([]string{"-hide_banner", "-loglevel", "error"}, args...)...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return fmt.Errorf("ffmpeg: %s", last)
+	}
+	return fmt.Errorf("ffmpeg: %w", err)
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
 
+// createStickerPack creates the user's next pack of packType with doc as its
+// first sticker and makes it their active pack. An empty title gets the
+// default "<name>'s <Type> Stickers #N".
+func createStickerPack(m *tg.NewMessage, packType, title string, doc tg.InputDocument, emoji string) (*db.PackInfo, error) {
 	userID := m.SenderID()
 	username := m.Sender.Username
 	if username == "" {
 		username = fmt.Sprintf("user%d", userID)
 	}
 
-	pack, err := db.GetActivePack(userID, packType)
-
-	var shortName, title string
-	//var isNewPack bool
+	packNumber, err := db.ReservePackNumber(userID, packType)
+	if err != nil {
+		return nil, err
+	}
+	if title == "" {
+		title = fmt.Sprintf("%s's %s Stickers #%d", username, strings.Title(packType), packNumber)
+	}
 
-	if err != nil || pack == nil || pack.StickerCount >= MaxStickersPerPack {
-		//isNewPack = true
-		packs, _ := db.GetUserPacks(userID)
-		packNumber := len(packs[packType]) + 1
+	pack := &db.PackInfo{
+		ShortName:    fmt.Sprintf("x%s_%s_%d_by_%s", username, packType, packNumber, m.Client.Me().Username),
+		Title:        title,
+		Type:         packType,
+		StickerCount: 1,
+		PackNumber:   packNumber,
+	}
 
-		shortName = fmt.Sprintf("x%s_%s_%d_by_%s", username, packType, packNumber, m.Client.Me().Username)
-		title = fmt.Sprintf("%s's %s Stickers #%d", username, strings.Title(packType), packNumber)
+	_, err = m.Client.StickersCreateStickerSet(&tg.StickersCreateStickerSetParams{
+		UserID:    &tg.InputUserObj{UserID: userID, AccessHash: m.Sender.AccessHash},
+		Title:     pack.Title,
+		ShortName: pack.ShortName,
+		Stickers: []*tg.InputStickerSetItem{
+			{
+				Document: doc,
+				Emoji:    emoji,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		pack = &db.PackInfo{
-			ShortName:    shortName,
-			Title:        title,
-			Type:         packType,
-			StickerCount: 0,
-			PackNumber:   packNumber,
-		}
+	db.SavePack(userID, pack)
+	db.SetActivePack(userID, packType, packNumber)
+	return pack, nil
+}
 
-		var createErr error
-		switch packType {
-		case "tgs", "webm":
-			fi, err := m.Client.DownloadMedia(stickerFile.fi)
-			if err != nil {
-				m.Reply("Failed to download sticker media.")
-				return nil
-			}
-			defer os.Remove(fi)
-
-			var mediaSendable *tg.InputMediaDocument
-			if packType == "webm" {
-				ext := filepath.Ext(fi)
-				out := fi + "_resized" + ext
-				cmd := exec.Command("ffmpeg", "-i", fi, "-vf", "scale=512:512:force_original_aspect_ratio=decrease,pad=512:512:(512-iw)/2:(512-ih)/2:color=black@0,format=yuva420p", "-c:v", "libvpx-vp9", "-auto-alt-ref", "0", "-b:v", "0", "-crf", "30", "-an", "-y", out)
-				if err := cmd.Run(); err == nil {
-					defer os.Remove(out)
-					media, err := m.Client.GetSendableMedia(out, &tg.MediaMetadata{Inline: true})
-					if err != nil {
-						media, err = m.Client.GetSendableMedia(fi, &tg.MediaMetadata{Inline: true})
-						if err != nil {
-							m.Reply("Failed to prepare sticker media.")
-							return nil
-						}
-					}
-					mediaSendable = media.(*tg.InputMediaDocument)
-				} else {
-					media, err := m.Client.GetSendableMedia(fi, &tg.MediaMetadata{Inline: true})
-					if err != nil {
-						m.Reply("Failed to prepare sticker media.")
-						return nil
-					}
-					mediaSendable = media.(*tg.InputMediaDocument)
-				}
-			} else {
-				media, err := m.Client.GetSendableMedia(fi, &tg.MediaMetadata{Inline: true})
-				if err != nil {
-					m.Reply("Failed to prepare sticker media.")
-					return nil
-				}
-				mediaSendable = media.(*tg.InputMediaDocument)
-			}
+func KangSticker(m *tg.NewMessage) error {
+	if !m.IsReply() {
+		m.Reply("Reply to a sticker, photo, video or GIF to kang it!\nUsage: <code>/kang [emoji]</code>")
+		return nil
+	}
 
-			_, createErr = m.Client.StickersCreateStickerSet(&tg.StickersCreateStickerSetParams{
-				UserID:    &tg.InputUserObj{UserID: userID, AccessHash: m.Sender.AccessHash},
-				Title:     title,
-				ShortName: shortName,
-				Stickers: []*tg.InputStickerSetItem{
-					{
-						Document: mediaSendable.ID,
-						Emoji:    emoji,
-					},
-				},
-			})
-		default:
-			_, createErr = m.Client.StickersCreateStickerSet(&tg.StickersCreateStickerSetParams{
-				UserID:    &tg.InputUserObj{UserID: userID, AccessHash: m.Sender.AccessHash},
-				Title:     title,
-				ShortName: shortName,
-				Stickers: []*tg.InputStickerSetItem{
-					{
-						Document: &tg.InputDocumentObj{
-							ID:            stickerFile.ID,
-							AccessHash:    stickerFile.AccessHash,
-							FileReference: stickerFile.FileReference,
-						},
-						Emoji: emoji,
-					},
-				},
-			})
-		}
+	reply, err := m.GetReplyMessage()
+	if err != nil {
+		m.Reply("Failed to get replied message.")
+		return nil
+	}
 
-		if createErr != nil {
-			m.Reply(fmt.Sprintf("Failed to create sticker pack: %v", createErr))
-			return nil
-		}
+	src, err := getKangSource(reply, m.Args())
+	if err != nil {
+		m.Reply(fmt.Sprintf("<b>Can't kang that:</b> %v", err))
+		return nil
+	}
 
-		pack.StickerCount = 1
-		db.SavePack(userID, pack)
+	progress, _ := m.Reply("Converting...")
+	doc, err := prepareKangDocument(m, src)
+	progress.Delete()
+	if err != nil {
+		m.Reply(fmt.Sprintf("<b>Can't kang that:</b> %v", err))
+		return nil
+	}
 
-		m.Reply(fmt.Sprintf(
-			"<b>Created new %s sticker pack!</b>\n"+
-				"Pack: <a href='https://t.me/addstickers/%s'>%s</a>\n"+
-				"Stickers: 1/%d",
-			packType, shortName, title, MaxStickersPerPack,
-		))
+	text, err := kangDocument(m, src.packType, doc, src.emoji)
+	if err != nil {
+		m.Reply(err.Error())
 		return nil
 	}
+	m.Reply(text)
+	return nil
+}
 
-	var doc tg.InputDocument
+// kangDocument adds doc to the sender's active pack of packType, starting a
+// new pack when there is none or it is full. It returns the message to reply
+// with; errors are meant to be shown to the user as-is.
+func kangDocument(m *tg.NewMessage, packType string, doc tg.InputDocument, emoji string) (string, error) {
+	userID := m.SenderID()
+	pack, err := db.GetActivePack(userID, packType)
 
-	switch packType {
-	case "tgs", "webm":
-		fi, err := m.Client.DownloadMedia(stickerFile.fi)
-		if err != nil {
-			m.Reply("Failed to download sticker media.")
-			return nil
-		}
-		defer os.Remove(fi)
-
-		if packType == "webm" {
-			ext := filepath.Ext(fi)
-			out := fi + "_resized" + ext
-			cmd := exec.Command("ffmpeg", "-i", fi, "-vf", "scale=512:512:force_original_aspect_ratio=decrease,pad=512:512:(512-iw)/2:(512-ih)/2:color=black@0,format=yuva420p", "-c:v", "libvpx-vp9", "-auto-alt-ref", "0", "-b:v", "0", "-crf", "30", "-an", "-y", out)
-			if err := cmd.Run(); err == nil {
-				defer os.Remove(out)
-				media, err := m.Client.GetSendableMedia(out, &tg.MediaMetadata{Inline: true})
-				if err != nil {
-					media, err = m.Client.GetSendableMedia(fi, &tg.MediaMetadata{Inline: true})
-					if err != nil {
-						m.Reply("Failed to prepare sticker media.")
-						return nil
-					}
-				}
-				doc = media.(*tg.InputMediaDocument).ID
-			} else {
-				media, err := m.Client.GetSendableMedia(fi, &tg.MediaMetadata{Inline: true})
-				if err != nil {
-					m.Reply("Failed to prepare sticker media.")
-					return nil
-				}
-				doc = media.(*tg.InputMediaDocument).ID
-			}
-		} else {
-			media, err := m.Client.GetSendableMedia(fi, &tg.MediaMetadata{Inline: true})
-			if err != nil {
-				m.Reply("Failed to prepare sticker media.")
-				return nil
-			}
-			doc = media.(*tg.InputMediaDocument).ID
-		}
-	default:
-		fi, err := m.Client.DownloadMedia(stickerFile.fi)
+	if err != nil || pack == nil || pack.StickerCount >= MaxStickersPerPack {
+		pack, err := createStickerPack(m, packType, "", doc, emoji)
 		if err != nil {
-			m.Reply("Failed to download sticker media.")
-			return nil
+			return "", fmt.Errorf("Failed to create sticker pack: %v", err)
 		}
-		defer os.Remove(fi)
-
-		ext := filepath.Ext(fi)
-		out := fi + "_resized" + ext
 
-		cmd := exec.Command("ffmpeg", "-i", fi, "-vf", "scale=w=512:h=512:force_original_aspect_ratio=decrease", "-y", out)
-		if err := cmd.Run(); err != nil {
-			media, err := m.Client.GetSendableMedia(fi, &tg.MediaMetadata{Inline: true})
-			if err != nil {
-				m.Reply("Failed to prepare sticker media.")
-				return nil
-			}
-			doc = media.(*tg.InputMediaDocument).ID
-		} else {
-			defer os.Remove(out)
-			media, err := m.Client.GetSendableMedia(out, &tg.MediaMetadata{Inline: true})
-			if err != nil {
-				media, err = m.Client.GetSendableMedia(fi, &tg.MediaMetadata{Inline: true})
-				if err != nil {
-					m.Reply("Failed to prepare sticker media.")
-					return nil
-				}
-			}
-			doc = media.(*tg.InputMediaDocument).ID
-		}
+		return fmt.Sprintf(
+			"<b>Created new %s sticker pack!</b>\n"+
+				"Pack: <a href='https://t.me/addstickers/%s'>%s</a>\n"+
+				"Stickers: 1/%d",
+			packType, pack.ShortName, html.EscapeString(pack.Title), MaxStickersPerPack,
+		), nil
 	}
 
 	_, addErr := m.Client.StickersAddStickerToSet(&tg.InputStickerSetShortName{ShortName: pack.ShortName}, &tg.InputStickerSetItem{
@@ -347,8 +370,7 @@ func KangSticker(m *tg.NewMessage) error {
 	})
 
 	if addErr != nil {
-		m.Reply(fmt.Sprintf("Failed to add sticker: %v", addErr))
-		return nil
+		return "", fmt.Errorf("Failed to add sticker: %v", addErr)
 	}
 
 	db.IncrementPackCount(userID, pack)
@@ -357,15 +379,14 @@ func KangSticker(m *tg.NewMessage) error {
 		"<b>Added to pack!</b>\n"+
 			"Pack: <a href='https://t.me/addstickers/%s'>%s</a>\n"+
 			"Stickers: %d/%d",
-		pack.ShortName, pack.Title, pack.StickerCount, MaxStickersPerPack,
+		pack.ShortName, html.EscapeString(pack.Title), pack.StickerCount, MaxStickersPerPack,
 	)
 
 	if pack.StickerCount >= MaxStickersPerPack {
 		msg += "\n\n⚠️ <b>Pack is full!</b> Next sticker will create a new pack."
 	}
 
-	m.Reply(msg)
-	return nil
+	return msg, nil
 }
 
 func RemoveKangedSticker(m *tg.NewMessage) error {
@@ -519,4 +540,26 @@ func registerStickersHandlers() {
 
 func init() {
 	QueueHandlerRegistration(registerStickersHandlers)
+
+	Mods.AddModule("Stickers", `<b>Stickers</b>
+
+Kang stickers into packs the bot makes for you.
+
+<b>Commands:</b>
+/kang [emoji] - Add the replied sticker, photo, image, video or GIF to your active pack
+/rmkang - Remove the replied sticker from your pack
+/pack - Info about the replied sticker's pack
+/gif - Turn the replied GIF or video into a video sticker
+/q [count] [kang] - Quote the replied message (and the next ones, up to 10) as a sticker; add <code>kang</code> to also add it to your pack
+
+<b>Managing Packs:</b>
+/mypacks - List your packs and pick the active one
+/newpack &lt;title&gt; - Start a new pack with the replied sticker
+/renamepack &lt;title&gt; - Rename the replied sticker's pack
+/movesticker &lt;position&gt; - Move the replied sticker (1 is first)
+/delpack [short name] - Delete a pack (reply or give its short name)
+/syncpacks - Reload titles and sticker counts from Telegram
+/clonepack - Copy the replied sticker's whole pack into your own packs
+
+Photos and images become static stickers, videos and GIFs become video stickers (cut to 3s). Static, video and animated stickers go into separate packs. A new pack is started when the active one reaches 120 stickers.`)
 }
@@ -2,6 +2,9 @@ package modules
 
 import (
 	"fmt"
+	"log"
+	"main/modules/db"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -10,20 +13,150 @@ import (
 	"github.com/amarnathcjd/gogram/telegram"
 )
 
-type timerData struct {
-	chatID   int64
-	userID   int64
-	message  string
-	media    telegram.MessageMedia
-	client   *telegram.Client
-	duration time.Duration
-}
-
 var (
-	activeTimers   = make(map[string]*timerData)
-	activeTimersMu sync.RWMutex
+	timerSchedule   = make(map[string]*time.Timer)
+	timerScheduleMu sync.Mutex
 )
 
+// scheduleTimer arms the in-memory callback for t's next run, replacing any
+// callback already armed for it.
+func scheduleTimer(client *telegram.Client, t *db.Timer) {
+	timerScheduleMu.Lock()
+	defer timerScheduleMu.Unlock()
+
+	if old, ok := timerSchedule[t.ID]; ok {
+		old.Stop()
+		delete(timerSchedule, t.ID)
+	}
+
+	next := t.NextRun()
+	if next.IsZero() {
+		return
+	}
+
+	id := t.ID
+	timerSchedule[id] = time.AfterFunc(time.Until(next), func() {
+		fireTimer(client, id)
+	})
+}
+
+func unscheduleTimer(id string) {
+	timerScheduleMu.Lock()
+	if old, ok := timerSchedule[id]; ok {
+		old.Stop()
+		delete(timerSchedule, id)
+	}
+	timerScheduleMu.Unlock()
+}
+
+// rescheduleTimers drops every armed callback and arms the timers in the
+// database again, after a restore swapped it out.
+func rescheduleTimers(client *telegram.Client) {
+	timerScheduleMu.Lock()
+	for id, t := range timerSchedule {
+		t.Stop()
+		delete(timerSchedule, id)
+	}
+	timerScheduleMu.Unlock()
+
+	restoreTimers(client)
+}
+
+func fireTimer(client *telegram.Client, id string) {
+	var due bool
+	t, err := db.UpdateTimer(id, func(t *db.Timer) bool {
+		now := time.Now()
+		snoozeDue := !t.SnoozeUntil.IsZero() && !now.Before(t.SnoozeUntil)
+		if snoozeDue {
+			t.SnoozeUntil = time.Time{}
+		}
+
+		regularDue := !t.Fired && !now.Before(t.FireAt)
+		if regularDue {
+			if t.IntervalSec > 0 {
+				// Skip runs missed while the bot was down.
+				for !t.FireAt.After(now) {
+					t.FireAt = t.FireAt.Add(t.Interval())
+				}
+			} else {
+				t.Fired = true
+			}
+		}
+
+		due = snoozeDue || regularDue
+		return due
+	})
+	if err != nil {
+		log.Printf("Failed to update timer %s: %v", id, err)
+		return
+	}
+	if t == nil {
+		return
+	}
+
+	if due {
+		sendTimerNotification(client, t)
+	}
+	scheduleTimer(client, t)
+}
+
+func sendTimerNotification(client *telegram.Client, t *db.Timer) {
+	text := "<b>Timer Alert!</b>"
+	if t.IntervalSec > 0 {
+		text = "<b>Reminder!</b>"
+	}
+	if t.Message != "" {
+		text += "\n" + t.Message
+	}
+
+	snoozeBtn := telegram.Button.Data("Snooze 5m", "snooze_"+t.ID).Primary()
+	dismissBtn := telegram.Button.Data("Dismiss", "dismiss_"+t.ID).Danger()
+	keyboard := telegram.NewKeyboard().AddRow(snoozeBtn).AddRow(dismissBtn).Build()
+
+	if t.MediaFileID != "" {
+		if media, err := telegram.ResolveBotFileID(t.MediaFileID); err == nil {
+			client.SendMedia(t.ChatID, media, &telegram.MediaOptions{
+				Caption:     text,
+				ReplyMarkup: keyboard,
+			})
+			return
+		}
+	}
+	client.SendMessage(t.ChatID, text, &telegram.SendOptions{
+		ReplyMarkup: keyboard,
+	})
+}
+
+// restoreTimers re-arms every stored timer at startup. Runs missed while the
+// bot was down go off straight away.
+func restoreTimers(client *telegram.Client) {
+	timers, err := db.GetTimers(0, 0)
+	if err != nil {
+		log.Printf("Failed to load timers: %v", err)
+		return
+	}
+
+	for _, t := range timers {
+		// Fired one-off timers nobody dismissed would otherwise pile up.
+		if t.Fired && t.SnoozeUntil.IsZero() && time.Since(t.FireAt) > 24*time.Hour {
+			db.DeleteTimer(t.ID)
+			continue
+		}
+		scheduleTimer(client, t)
+	}
+}
+
+func timerMediaFileID(m *telegram.NewMessage) string {
+	if !m.IsReply() {
+		return ""
+	}
+	reply, err := m.GetReplyMessage()
+	if err != nil || !reply.IsMedia() || reply.File == nil {
+		return ""
+	}
+	return reply.File.FileID
+}
+
 func SetTimerHandler(m *telegram.NewMessage) error {
 	args := m.Args()
 	if args == "" {
@@ -43,63 +176,150 @@ func SetTimerHandler(m *telegram.NewMessage) error {
 		return nil
 	}
 
-	timer := &timerData{
-		chatID:   m.ChatID(),
-		userID:   m.SenderID(),
-		message:  message,
-		client:   m.Client,
-		duration: duration,
+	t := &db.Timer{
+		ChatID:      m.ChatID(),
+		UserID:      m.SenderID(),
+		Message:     message,
+		MediaFileID: timerMediaFileID(m),
+		FireAt:      time.Now().Add(duration),
+		CreatedAt:   time.Now(),
 	}
 
-	if m.IsReply() {
-		reply, err := m.GetReplyMessage()
-		if err == nil && reply.IsMedia() {
-			timer.media = reply.Media()
+	if err := db.SaveTimer(t); err != nil {
+		m.Reply("<b>Error:</b> failed to save timer")
+		return nil
+	}
+	scheduleTimer(m.Client, t)
+
+	m.Reply(fmt.Sprintf("Timer <code>#%s</code> set for <b>%s</b>", t.ID, formatDuration(duration)))
+	return nil
+}
+
+// RemindHandler - /remind every <interval> [HH:MM] <message>, or /remind <duration> <message>
+func RemindHandler(m *telegram.NewMessage) error {
+	fields := strings.Fields(m.Args())
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "every") {
+		return SetTimerHandler(m)
+	}
+
+	if len(fields) < 2 {
+		m.Reply("<b>Usage:</b> <code>/remind every &lt;interval&gt; [HH:MM] &lt;message&gt;</code>\n<b>Example:</b> <code>/remind every 1d 09:00 standup</code>")
+		return nil
+	}
+
+	interval, err := parseDuration(fields[1])
+	if err != nil {
+		m.Reply("<b>Error:</b> " + err.Error())
+		return nil
+	}
+	if interval < time.Minute {
+		m.Reply("<b>Error:</b> interval must be at least 1 minute")
+		return nil
+	}
+
+	now := time.Now()
+	first := now.Add(interval)
+	rest := fields[2:]
+	if len(rest) > 0 {
+		if at, err := time.Parse("15:04", rest[0]); err == nil {
+			first = time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, time.Local)
+			if !first.After(now) {
+				first = first.Add(24 * time.Hour)
+			}
+			rest = rest[1:]
 		}
 	}
 
-	timerID := fmt.Sprintf("%d_%d_%d", m.ChatID(), m.SenderID(), time.Now().UnixNano())
+	t := &db.Timer{
+		ChatID:      m.ChatID(),
+		UserID:      m.SenderID(),
+		Message:     strings.Join(rest, " "),
+		MediaFileID: timerMediaFileID(m),
+		FireAt:      first,
+		IntervalSec: int64(interval / time.Second),
+		CreatedAt:   now,
+	}
+
+	if err := db.SaveTimer(t); err != nil {
+		m.Reply("<b>Error:</b> failed to save reminder")
+		return nil
+	}
+	scheduleTimer(m.Client, t)
+
+	m.Reply(fmt.Sprintf("Reminder <code>#%s</code> set every <b>%s</b>, first at <b>%s</b>",
+		t.ID, formatDuration(interval), first.Format("Mon 02 Jan 15:04")))
+	return nil
+}
 
-	activeTimersMu.Lock()
-	activeTimers[timerID] = timer
-	activeTimersMu.Unlock()
+// TimersHandler - /timers, lists your timers in this chat
+func TimersHandler(m *telegram.NewMessage) error {
+	timers, err := db.GetTimers(m.ChatID(), m.SenderID())
+	if err != nil || len(timers) == 0 {
+		m.Reply("You have no timers here")
+		return nil
+	}
 
-	time.AfterFunc(duration, func() {
-		sendTimerNotification(timerID)
+	sort.Slice(timers, func(i, j int) bool {
+		return timers[i].CreatedAt.Before(timers[j].CreatedAt)
 	})
 
-	m.Reply(fmt.Sprintf("Timer set for <b>%s</b>", formatDuration(duration)))
+	var sb strings.Builder
+	sb.WriteString("<b>Your timers</b>\n\n")
+	for _, t := range timers {
+		fmt.Fprintf(&sb, "<code>#%s</code> ", t.ID)
+		if next := t.NextRun(); next.IsZero() {
+			sb.WriteString("fired, waiting to be dismissed")
+		} else {
+			fmt.Fprintf(&sb, "in %s", formatDuration(time.Until(next).Round(time.Second)))
+		}
+		if t.IntervalSec > 0 {
+			fmt.Fprintf(&sb, ", every %s", formatDuration(t.Interval()))
+		}
+		if !t.SnoozeUntil.IsZero() {
+			sb.WriteString(" (snoozed)")
+		}
+		if t.Message != "" {
+			sb.WriteString("\n  " + trimString(t.Message, 60))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nUse /cancel &lt;id&gt; to remove one.")
+
+	m.Reply(sb.String())
 	return nil
 }
 
-func sendTimerNotification(timerID string) {
-	activeTimersMu.RLock()
-	timer, exists := activeTimers[timerID]
-	activeTimersMu.RUnlock()
-
-	if !exists {
-		return
+// CancelHandler - /cancel <id> removes a timer; without an id it falls back
+// to cancelling a download for the owner.
+func CancelHandler(m *telegram.NewMessage) error {
+	id := strings.TrimPrefix(strings.TrimSpace(m.Args()), "#")
+	if id == "" {
+		if m.SenderID() == OwnerId && m.IsReply() {
+			return CancelDownloadHandle(m)
+		}
+		m.Reply("<b>Usage:</b> <code>/cancel &lt;timer id&gt;</code>\nSee /timers for your timer ids.")
+		return nil
 	}
 
-	text := "<b>Timer Alert!</b>"
-	if timer.message != "" {
-		text += "\n" + timer.message
+	t, err := db.GetTimer(id)
+	if err != nil || t == nil || t.ChatID != m.ChatID() {
+		m.Reply(fmt.Sprintf("No timer <code>#%s</code> in this chat", id))
+		return nil
 	}
 
-	snoozeBtn := telegram.Button.Data("Snooze 5m", "snooze_"+timerID).Primary()
-	dismissBtn := telegram.Button.Data("Dismiss", "dismiss_"+timerID).Danger()
-	keyboard := telegram.NewKeyboard().AddRow(snoozeBtn).AddRow(dismissBtn).Build()
+	if t.UserID != m.SenderID() && (m.IsPrivate() || !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "")) {
+		m.Reply("Only the timer setter or an admin can cancel it")
+		return nil
+	}
 
-	if timer.media != nil {
-		timer.client.SendMedia(timer.chatID, timer.media, &telegram.MediaOptions{
-			Caption:     text,
-			ReplyMarkup: keyboard,
-		})
-	} else {
-		timer.client.SendMessage(timer.chatID, text, &telegram.SendOptions{
-			ReplyMarkup: keyboard,
-		})
+	if err := db.DeleteTimer(t.ID); err != nil {
+		m.Reply("<b>Error:</b> failed to cancel timer")
+		return nil
 	}
+	unscheduleTimer(t.ID)
+
+	m.Reply(fmt.Sprintf("Timer <code>#%s</code> cancelled", t.ID))
+	return nil
 }
 
 func TimerCallbackHandler(cb *telegram.CallbackQuery) error {
@@ -116,33 +336,45 @@ func TimerCallbackHandler(cb *telegram.CallbackQuery) error {
 		return nil
 	}
 
-	activeTimersMu.RLock()
-	timer, exists := activeTimers[timerID]
-	activeTimersMu.RUnlock()
-
-	if !exists {
+	timer, err := db.GetTimer(timerID)
+	if err != nil || timer == nil {
 		cb.Answer("Timer expired", &telegram.CallbackOptions{Alert: true})
 		return nil
 	}
 
-	if cb.Sender.ID != timer.userID {
+	if cb.Sender.ID != timer.UserID {
 		cb.Answer("Only the timer setter can do this!", &telegram.CallbackOptions{Alert: true})
 		return nil
 	}
 
 	switch action {
 	case "snooze":
-		snoozeDuration := 5 * time.Minute
-		time.AfterFunc(snoozeDuration, func() {
-			sendTimerNotification(timerID)
+		timer, err := db.UpdateTimer(timer.ID, func(t *db.Timer) bool {
+			t.SnoozeUntil = time.Now().Add(5 * time.Minute)
+			return true
 		})
+		if err != nil || timer == nil {
+			cb.Answer("Failed to snooze", &telegram.CallbackOptions{Alert: true})
+			return nil
+		}
+		scheduleTimer(cb.Client, timer)
 		cb.Edit("<b>Snoozed for 5 minutes</b>")
 		cb.Answer("Snoozed!")
 
 	case "dismiss":
-		activeTimersMu.Lock()
-		delete(activeTimers, timerID)
-		activeTimersMu.Unlock()
+		if timer.IntervalSec > 0 {
+			// Recurring reminders keep running; only a pending snooze is dropped.
+			updated, err := db.UpdateTimer(timer.ID, func(t *db.Timer) bool {
+				t.SnoozeUntil = time.Time{}
+				return true
+			})
+			if err == nil && updated != nil {
+				scheduleTimer(cb.Client, updated)
+			}
+		} else {
+			db.DeleteTimer(timer.ID)
+			unscheduleTimer(timer.ID)
+		}
 		cb.Edit("<b>Timer dismissed</b>")
 		cb.Answer("Dismissed!")
 	}
@@ -234,10 +466,31 @@ func formatDuration(d time.Duration) string {
 func registerTimerHandlers() {
 	c := Client
 	c.On("command:timer", SetTimerHandler)
+	c.On("cmd:remind", RemindHandler)
+	c.On("cmd:timers", TimersHandler)
+	c.On("cmd:cancel", CancelHandler)
 	c.On("callback:snooze_", TimerCallbackHandler)
 	c.On("callback:dismiss_", TimerCallbackHandler)
+
+	go restoreTimers(c)
+	db.OnRestore(func() { rescheduleTimers(c) })
 }
 
 func init() {
 	QueueHandlerRegistration(registerTimerHandlers)
+
+	Mods.AddModule("Timers", `<b>Timers & Reminders</b>
+
+<b>Commands:</b>
+/timer <duration> <message> - One-off timer
+/remind <duration> <message> - Same as /timer
+/remind every <interval> [HH:MM] <message> - Recurring reminder
+/timers - List your timers in this chat
+/cancel <id> - Cancel a timer
+
+Reply to media to attach it to the alert. Timers are saved and survive restarts.
+
+<b>Examples:</b>
+<code>/timer 1h30m Take a break!</code>
+<code>/remind every 1d 09:00 standup</code>`)
 }
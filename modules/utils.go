@@ -3,6 +3,7 @@ package modules
 import (
 	"errors"
 	"fmt"
+	"html"
 	"math"
 	"os"
 	"os/exec"
@@ -154,6 +155,25 @@ func HumanBytes(bytes uint64) string {
 	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// splitLines breaks s into chunks of at most limit bytes, cutting only at
+// newlines so HTML tags that open and close on one line stay intact. A single
+// line longer than limit gets a chunk of its own.
+func splitLines(s string, limit int) []string {
+	var chunks []string
+	var cur strings.Builder
+	for line := range strings.SplitAfterSeq(s, "\n") {
+		if cur.Len() > 0 && cur.Len()+len(line) > limit {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(line)
+	}
+	if cur.Len() > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
+
 func trimString(s string, length int) string {
 	if len(s) > length {
 		return s[:length] + ""
@@ -162,50 +182,35 @@ func trimString(s string, length int) string {
 	return s
 }
 
+// IsUserAdmin reports whether userID is the creator, or an admin holding
+// right, using the cached admin list. Chats whose admins can't be listed
+// fall back to a single member lookup.
 func IsUserAdmin(bot *telegram.Client, userID int64, chatID int64, right string) bool {
+	admins, err := chatAdminCache.get(bot, chatID)
+	if err == nil {
+		return participantHasRight(admins.members[userID], right)
+	}
+
 	member, err := bot.GetChatMember(chatID, userID)
 	if err != nil {
 		return false
 	}
-
-	if member.Status == telegram.Admin || member.Status == telegram.Creator {
-		if member.Status == telegram.Creator {
-			return true
-		}
-		switch right {
-		case "promote":
-			return member.Rights.AddAdmins
-		case "ban":
-			return member.Rights.BanUsers
-		case "delete":
-			return member.Rights.DeleteMessages
-		case "change_info", "info":
-			return member.Rights.ChangeInfo
-		case "pin":
-			return member.Rights.PinMessages
-		case "invite":
-			return member.Rights.InviteUsers
-		case "":
-			return true
-		}
-	}
-	return false
+	return participantHasRight(member, right)
 }
 
+// CanBot reports whether the bot holds right in chat, preferring the cached
+// admin list over the rights the update happened to carry.
 func CanBot(bot *telegram.Client, chat *telegram.Channel, right string) bool {
-	if chat.AdminRights != nil {
-		switch right {
-		case "ban":
-			return chat.AdminRights.BanUsers
-		case "delete":
-			return chat.AdminRights.DeleteMessages
-		case "invite":
-			return chat.AdminRights.InviteUsers
-		case "promote":
-			return chat.AdminRights.AddAdmins
-		}
+	if chat == nil {
+		return false
+	}
+	if admins, err := chatAdminCache.get(bot, chat.ID); err == nil {
+		return participantHasRight(admins.members[bot.Me().ID], right)
 	}
-	return false
+	if chat.Creator {
+		return true
+	}
+	return chat.AdminRights != nil && hasAdminRight(chat.AdminRights, right)
 }
 
 func GetUserFromContext(m *telegram.NewMessage) (telegram.InputPeer, string, error) {
@@ -423,6 +428,14 @@ func polyeval(coeffs []float64, x float64) float64 {
 	return result
 }
 
+// userMention links name to userID, falling back to the ID when name is empty.
+func userMention(userID int64, name string) string {
+	if name == "" {
+		name = fmt.Sprint(userID)
+	}
+	return fmt.Sprintf("<a href=\"tg://user?id=%d\">%s</a>", userID, html.EscapeString(name))
+}
+
 func GetPeerDisplayName(client *telegram.Client, peer telegram.InputPeer) string {
 	switch p := peer.(type) {
 	case *telegram.InputPeerUser:
@@ -2,6 +2,8 @@ package modules
 
 import (
 	"fmt"
+	"html"
+	"log"
 	"main/modules/db"
 	"strconv"
 	"strings"
@@ -43,57 +45,42 @@ func WarnUserHandler(m *tg.NewMessage) error {
 		reason = "No reason specified"
 	}
 
-	warn := &db.Warn{
-		Reason:    reason,
-		AdminID:   m.SenderID(),
-		Timestamp: time.Now(),
-	}
-
-	count, err := db.AddWarn(m.ChatID(), userID, warn)
+	msg, count, err := performWarn(m.Client, m.ChatID(), user, reason, m.SenderID())
 	if err != nil {
 		m.Reply("Failed to add warning")
 		return nil
 	}
 
 	settings, _ := db.GetWarnSettings(m.ChatID())
-
-	userInfo, _ := m.Client.GetUser(userID)
-	userName := "User"
-	if userInfo != nil {
-		userName = userInfo.FirstName
-	}
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryWarns,
+		Action:   "warn",
+		TargetID: userID,
+		Reason:   reason,
+		Details:  fmt.Sprintf("%d/%d warnings", count, settings.MaxWarns),
+	})
 
 	if count >= settings.MaxWarns {
-		switch settings.Action {
-		case db.WarnActionBan:
-			m.Client.EditBanned(m.ChatID(), user, &tg.BannedOptions{Ban: true})
-			db.ResetWarns(m.ChatID(), userID)
-			m.Reply(fmt.Sprintf("%s has been banned for reaching %d warning(s)\nReason: %s",
-				userName, settings.MaxWarns, reason))
-		case db.WarnActionMute:
-			m.Client.EditBanned(m.ChatID(), user, &tg.BannedOptions{Mute: true})
-			db.ResetWarns(m.ChatID(), userID)
-			m.Reply(fmt.Sprintf("%s has been muted for reaching %d warning(s)\nReason: %s",
-				userName, settings.MaxWarns, reason))
-		case db.WarnActionKick:
-			m.Client.KickParticipant(m.ChatID(), user)
-			db.ResetWarns(m.ChatID(), userID)
-			m.Reply(fmt.Sprintf("%s has been removed for reaching %d warning(s)\nReason: %s",
-				userName, settings.MaxWarns, reason))
+		action := string(settings.Action)
+		if settings.Action != db.WarnActionBan && settings.Action != db.WarnActionKick {
+			action = "mute"
 		}
+		logMessageEvent(m, &LogEvent{
+			Category: actionLogCategory(action),
+			Action:   "warn " + action,
+			TargetID: userID,
+			Reason:   fmt.Sprintf("Reached %d warning(s): %s", settings.MaxWarns, reason),
+		})
+		m.Reply(msg)
 		return nil
 	}
 
 	b := tg.Button
-	m.Reply(
-		fmt.Sprintf("Warning issued to %s (%d/%d)\nReason: %s",
-			userName, count, settings.MaxWarns, reason),
-		&tg.SendOptions{
-			ReplyMarkup: tg.NewKeyboard().AddRow(
-				b.Data("Remove Warning", fmt.Sprintf("rmwarn_%d_%d", userID, m.SenderID())).Danger(),
-			).Build(),
-		},
-	)
+	m.Reply(msg, &tg.SendOptions{
+		ReplyMarkup: tg.NewKeyboard().AddRow(
+			b.Data("Remove Warning", fmt.Sprintf("rmwarn_%d_%d", userID, m.SenderID())).Danger(),
+		).Build(),
+	})
 	return nil
 }
 
@@ -132,6 +119,13 @@ func RemoveWarnCallback(c *tg.CallbackQuery) error {
 		db.AddWarn(c.ChatID, userID, warns[i])
 	}
 
+	logCallbackEvent(c, &LogEvent{
+		Category: LogCategoryWarns,
+		Action:   "rmwarn",
+		TargetID: userID,
+		Details:  fmt.Sprintf("%d/%d warnings", newCount, settings.MaxWarns),
+	})
+
 	c.Edit(fmt.Sprintf("Warn removed. User now has %d/%d warns", newCount, settings.MaxWarns))
 	return nil
 }
@@ -173,6 +167,9 @@ func ListWarnsHandler(m *tg.NewMessage) error {
 		}
 		resp.WriteString(fmt.Sprintf("%d. %s\n   By %s on %s\n",
 			i+1, warn.Reason, adminName, warn.Timestamp.Format("02 Jan 2006")))
+		if !warn.ExpiresAt.IsZero() {
+			resp.WriteString(fmt.Sprintf("   Expires in %s\n", formatDuration(time.Until(warn.ExpiresAt).Round(time.Minute))))
+		}
 	}
 
 	m.Reply(resp.String())
@@ -215,6 +212,13 @@ func ResetWarnsHandler(m *tg.NewMessage) error {
 		userName = userInfo.FirstName
 	}
 
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryWarns,
+		Action:   "resetwarns",
+		TargetID: userID,
+		Details:  fmt.Sprintf("Cleared %d warning(s)", len(warns)),
+	})
+
 	m.Reply(fmt.Sprintf("Cleared %d warning(s) for %s", len(warns), userName))
 	return nil
 }
@@ -259,6 +263,13 @@ func RemoveWarnHandler(m *tg.NewMessage) error {
 		userName = userInfo.FirstName
 	}
 
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryWarns,
+		Action:   "rmwarn",
+		TargetID: userID,
+		Details:  fmt.Sprintf("%d/%d warnings", newCount, settings.MaxWarns),
+	})
+
 	m.Reply(fmt.Sprintf("Removed last warning for %s. Current: %d/%d", userName, newCount, settings.MaxWarns))
 	return nil
 }
@@ -352,6 +363,53 @@ Available actions:
 	return nil
 }
 
+// SetWarnDecayHandler - /setwarnmode <days>, warnings older than this are removed
+func SetWarnDecayHandler(m *tg.NewMessage) error {
+	if m.IsPrivate() {
+		m.Reply("Warning settings can only be changed in groups")
+		return nil
+	}
+
+	if !IsUserAdmin(m.Client, m.SenderID(), m.ChatID(), "change_info") {
+		m.Reply("You need Change Info permission to modify warning settings")
+		return nil
+	}
+
+	args := strings.TrimSpace(m.Args())
+	if args == "" {
+		settings, _ := db.GetWarnSettings(m.ChatID())
+		m.Reply(fmt.Sprintf("Current warning decay: %s\n\nUsage: /setwarnmode <days>\nUse 0 to keep warnings forever (max 365)", warnDecayText(settings.DecayDays)))
+		return nil
+	}
+
+	days, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(args), "d"))
+	if err != nil || days < 0 || days > 365 {
+		m.Reply("Decay must be a number of days between 0 and 365")
+		return nil
+	}
+
+	settings, _ := db.GetWarnSettings(m.ChatID())
+	settings.DecayDays = days
+
+	if err := db.SetWarnSettings(m.ChatID(), settings); err != nil {
+		m.Reply("Failed to update warning decay")
+		return nil
+	}
+
+	m.Reply(fmt.Sprintf("Warning decay set to: %s", warnDecayText(days)))
+	return nil
+}
+
+func warnDecayText(days int) string {
+	switch days {
+	case 0:
+		return "off"
+	case 1:
+		return "after 1 day"
+	}
+	return fmt.Sprintf("after %d days", days)
+}
+
 func WarnSettingsHandler(m *tg.NewMessage) error {
 	if m.IsPrivate() {
 		m.Reply("Warning settings can only be viewed in groups")
@@ -364,9 +422,11 @@ func WarnSettingsHandler(m *tg.NewMessage) error {
 
 Limit: %d warnings
 Action: %s
+Decay: %s
 
 Use /setwarnlimit to change the limit
-Use /setwarnaction to change the action`, settings.MaxWarns, settings.Action))
+Use /setwarnaction to change the action
+Use /setwarnmode to change the decay`, settings.MaxWarns, settings.Action, warnDecayText(settings.DecayDays)))
 	return nil
 }
 
@@ -378,7 +438,7 @@ func registerWarnsHandlers() {
 	c.On("cmd:resetwarns", ResetWarnsHandler)
 	c.On("cmd:setwarnlimit", SetWarnLimitHandler)
 	c.On("cmd:setwarnaction", SetWarnActionHandler)
-	c.On("cmd:setwarnmode", SetWarnActionHandler)
+	c.On("cmd:setwarnmode", SetWarnDecayHandler)
 	c.On("cmd:warnsettings", WarnSettingsHandler)
 	c.On("cmd:twarn", TemporaryWarnHandler)
 	c.On("callback:rmwarn_", RemoveWarnCallback)
@@ -410,20 +470,43 @@ func init() {
 Actions: ban, mute, kick`)
 }
 
-type ActionHistory struct {
-	ActionID  string
-	Type      string
-	UserID    int64
-	ChatID    int64
-	AdminID   int64
-	Timestamp time.Time
-	Data      map[string]interface{}
-}
+// performWarn warns user, applying the chat's warn action once they reach
+// the limit. reason is plain text. It also returns the user's warn count
+// including this one.
+func performWarn(client *tg.Client, chatID int64, user tg.InputPeer, reason string, adminID int64) (string, int, error) {
+	userID := client.GetPeerID(user)
+
+	count, err := db.AddWarn(chatID, userID, &db.Warn{Reason: reason, AdminID: adminID, Timestamp: time.Now()})
+	if err != nil {
+		return "", 0, err
+	}
+	RecordAction(chatID, userID, adminID, "warn", map[string]interface{}{"reason": reason})
+
+	settings, err := db.GetWarnSettings(chatID)
+	if err != nil {
+		return "", count, err
+	}
+	if count < settings.MaxWarns {
+		return fmt.Sprintf("%s has been warned (%d/%d)\n<b>Reason:</b> %s",
+			html.EscapeString(GetPeerDisplayName(client, user)), count, settings.MaxWarns, html.EscapeString(reason)), count, nil
+	}
 
-var actionHistories = make(map[int64][]ActionHistory)
+	db.ResetWarns(chatID, userID)
+	limitReason := html.EscapeString(fmt.Sprintf("Reached %d warning(s): %s", settings.MaxWarns, reason))
+	var msg string
+	switch settings.Action {
+	case db.WarnActionBan:
+		msg, err = performBan(client, chatID, user, limitReason, adminID)
+	case db.WarnActionKick:
+		msg, err = performKick(client, chatID, user, limitReason, adminID)
+	default:
+		msg, err = performMute(client, chatID, user, limitReason, adminID)
+	}
+	return msg, count, err
+}
 
 func RecordAction(chatID, userID, adminID int64, actionType string, data map[string]interface{}) {
-	action := ActionHistory{
+	action := &db.ActionLog{
 		ActionID:  fmt.Sprintf("%d_%d_%s", chatID, time.Now().UnixNano(), actionType),
 		Type:      actionType,
 		UserID:    userID,
@@ -433,10 +516,8 @@ func RecordAction(chatID, userID, adminID int64, actionType string, data map[str
 		Data:      data,
 	}
 
-	actionHistories[chatID] = append(actionHistories[chatID], action)
-
-	if len(actionHistories[chatID]) > 50 {
-		actionHistories[chatID] = actionHistories[chatID][1:]
+	if err := db.AddActionLog(chatID, action); err != nil {
+		log.Printf("Failed to record '%s' action in %d: %v", actionType, chatID, err)
 	}
 }
 
@@ -484,6 +565,7 @@ func TemporaryWarnHandler(m *tg.NewMessage) error {
 		Reason:    reason,
 		AdminID:   m.SenderID(),
 		Timestamp: time.Now(),
+		ExpiresAt: time.Now().Add(duration),
 	}
 
 	count, _ := db.AddWarn(m.ChatID(), userID, warn)
@@ -499,6 +581,14 @@ func TemporaryWarnHandler(m *tg.NewMessage) error {
 		"reason":   reason,
 		"duration": duration.String(),
 	})
+	logMessageEvent(m, &LogEvent{
+		Category: LogCategoryWarns,
+		Action:   "twarn",
+		TargetID: userID,
+		Reason:   reason,
+		Duration: formatAdminDuration(duration),
+		Details:  fmt.Sprintf("%d/%d warnings", count, settings.MaxWarns),
+	})
 
 	b := tg.Button
 	m.Reply(
@@ -511,17 +601,6 @@ func TemporaryWarnHandler(m *tg.NewMessage) error {
 		},
 	)
 
-	go func() {
-		<-time.After(duration)
-		warns, _ := db.GetWarns(m.ChatID(), userID)
-		if len(warns) > 0 && warns[len(warns)-1].Reason == reason {
-			db.ResetWarns(m.ChatID(), userID)
-			for i := 0; i < len(warns)-1; i++ {
-				db.AddWarn(m.ChatID(), userID, warns[i])
-			}
-		}
-	}()
-
 	return nil
 }
 
@@ -547,27 +626,23 @@ func UndoActionHandler(c *tg.CallbackQuery) error {
 		return nil
 	}
 
-	if actionHistories[c.ChatID] == nil {
-		c.Answer("No recent actions to undo", &tg.CallbackOptions{Alert: true})
+	targetAction, err := db.FindRecentAction(c.ChatID, userID, actionType, 5*time.Minute)
+	if err != nil {
+		c.Answer("Failed to read action history", &tg.CallbackOptions{Alert: true})
 		return nil
 	}
 
-	var targetAction *ActionHistory
-	for i := len(actionHistories[c.ChatID]) - 1; i >= 0; i-- {
-		action := actionHistories[c.ChatID][i]
-		if action.Type == actionType &&
-			action.UserID == userID &&
-			time.Since(action.Timestamp) < 5*time.Minute {
-			targetAction = &action
-			break
-		}
-	}
-
 	if targetAction == nil {
 		c.Answer("Action not found or expired (5-minute window)", &tg.CallbackOptions{Alert: true})
 		return nil
 	}
 
+	logCallbackEvent(c, &LogEvent{
+		Category: actionLogCategory(actionType),
+		Action:   "undo " + actionType,
+		TargetID: userID,
+	})
+
 	// Handle different action types
 	switch actionType {
 	case "twarn", "warn":
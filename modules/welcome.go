@@ -129,6 +129,7 @@ Usage: /setwelcome <message> or reply to a message
 		mediaTag = " [with media]"
 	}
 
+	logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "setwelcome", Details: trimString(welcomeMsg.Content, 200) + mediaTag})
 	m.Reply(fmt.Sprintf("Welcome message saved%s", mediaTag))
 	return nil
 }
@@ -227,6 +228,7 @@ Usage: /setgoodbye <message> or reply to a message
 		return nil
 	}
 
+	logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "setgoodbye", Details: trimString(goodbyeMsg.Content, 200)})
 	m.Reply("Goodbye message saved")
 	return nil
 }
@@ -253,10 +255,12 @@ func WelcomeToggleHandler(m *tg.NewMessage) error {
 	case "on", "yes", "enable", "1":
 		welcomeMsg.Enabled = true
 		db.SetWelcome(m.ChatID(), welcomeMsg)
+		logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "welcome on"})
 		m.Reply("Welcome messages enabled")
 	case "off", "no", "disable", "0":
 		welcomeMsg.Enabled = false
 		db.SetWelcome(m.ChatID(), welcomeMsg)
+		logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "welcome off"})
 		m.Reply("Welcome messages disabled")
 	default:
 		status := "disabled"
@@ -290,10 +294,12 @@ func GoodbyeToggleHandler(m *tg.NewMessage) error {
 	case "on", "yes", "enable", "1":
 		goodbyeMsg.Enabled = true
 		db.SetGoodbye(m.ChatID(), goodbyeMsg)
+		logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "goodbye on"})
 		m.Reply("Goodbye messages enabled")
 	case "off", "no", "disable", "0":
 		goodbyeMsg.Enabled = false
 		db.SetGoodbye(m.ChatID(), goodbyeMsg)
+		logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "goodbye off"})
 		m.Reply("Goodbye messages disabled")
 	default:
 		status := "disabled"
@@ -317,6 +323,7 @@ func ClearWelcomeHandler(m *tg.NewMessage) error {
 	}
 
 	db.SetWelcome(m.ChatID(), &db.WelcomeMessage{})
+	logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "clearwelcome"})
 	m.Reply("Welcome message cleared")
 	return nil
 }
@@ -333,6 +340,7 @@ func ClearGoodbyeHandler(m *tg.NewMessage) error {
 	}
 
 	db.SetGoodbye(m.ChatID(), &db.WelcomeMessage{})
+	logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "cleargoodbye"})
 	m.Reply("Goodbye message cleared")
 	return nil
 }
@@ -349,14 +357,29 @@ func WelcomeHandler(p *tg.ParticipantUpdate) error {
 		return nil
 	}
 
+	if enforceFedBan(p.Client, chatID, user.ID) {
+		return nil
+	}
+
+	// Only self-joins get a captcha; whoever added the user vouched for them.
+	if p.IsJoined() && startCaptcha(p.Client, chatID, user) {
+		// The welcome is sent once the captcha is solved.
+		return nil
+	}
+
+	sendWelcome(p.Client, chatID, user)
+	return nil
+}
+
+func sendWelcome(client *tg.Client, chatID int64, user *tg.UserObj) {
 	welcomeMsg, err := db.GetWelcome(chatID)
 	if err != nil {
-		return nil
+		return
 	}
 
 	if welcomeMsg != nil && welcomeMsg.DeletePrevious {
 		if lastID, _ := db.GetLastWelcomeID(chatID); lastID > 0 {
-			p.Client.DeleteMessages(chatID, []int32{int32(lastID)})
+			client.DeleteMessages(chatID, []int32{int32(lastID)})
 		}
 	}
 
@@ -377,7 +400,7 @@ func WelcomeHandler(p *tg.ParticipantUpdate) error {
 		content = "Hey {mention}, welcome to {chatname}!"
 	}
 
-	channel, _ := p.Client.GetChannel(chatID)
+	channel, _ := client.GetChannel(chatID)
 	text := formatWelcomeText(content, user, channel)
 
 	var keyboard *tg.ReplyInlineMarkup
@@ -390,7 +413,7 @@ func WelcomeHandler(p *tg.ParticipantUpdate) error {
 	if fileID != "" {
 		media, err := tg.ResolveBotFileID(fileID)
 		if err == nil {
-			msg, err := p.Client.SendMedia(chatID, media, &tg.MediaOptions{Caption: text, ReplyMarkup: keyboard})
+			msg, err := client.SendMedia(chatID, media, &tg.MediaOptions{Caption: text, ReplyMarkup: keyboard})
 			if err == nil {
 				sentMsg = *msg
 			}
@@ -400,7 +423,7 @@ func WelcomeHandler(p *tg.ParticipantUpdate) error {
 		if keyboard != nil {
 			opts.ReplyMarkup = keyboard
 		}
-		msg, err := p.Client.SendMessage(chatID, text, opts)
+		msg, err := client.SendMessage(chatID, text, opts)
 		if err == nil {
 			sentMsg = *msg
 		}
@@ -412,12 +435,10 @@ func WelcomeHandler(p *tg.ParticipantUpdate) error {
 		if autoDelete > 0 {
 			go func() {
 				time.Sleep(time.Duration(autoDelete) * time.Second)
-				p.Client.DeleteMessages(chatID, []int32{int32(sentMsg.ID)})
+				client.DeleteMessages(chatID, []int32{int32(sentMsg.ID)})
 			}()
 		}
 	}
-
-	return nil
 }
 
 func WelcomeSettingsHandler(m *tg.NewMessage) error {
@@ -484,10 +505,12 @@ func CleanServiceHandler(m *tg.NewMessage) error {
 	case "on", "yes", "enable":
 		welcomeMsg.DeletePrevious = true
 		db.SetWelcome(m.ChatID(), welcomeMsg)
+		logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "cleanwelcome on"})
 		m.Reply("Previous welcome messages will be deleted")
 	case "off", "no", "disable":
 		welcomeMsg.DeletePrevious = false
 		db.SetWelcome(m.ChatID(), welcomeMsg)
+		logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "cleanwelcome off"})
 		m.Reply("Previous welcome messages will not be deleted")
 	default:
 		status := "disabled"
@@ -520,6 +543,7 @@ func WelcomeAutoDeleteHandler(m *tg.NewMessage) error {
 	if args == "off" || args == "0" {
 		welcomeMsg.AutoDeleteSec = 0
 		db.SetWelcome(m.ChatID(), welcomeMsg)
+		logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "wautodelete off"})
 		m.Reply("Welcome auto-delete disabled")
 		return nil
 	}
@@ -549,6 +573,7 @@ func WelcomeAutoDeleteHandler(m *tg.NewMessage) error {
 
 	welcomeMsg.AutoDeleteSec = value
 	db.SetWelcome(m.ChatID(), welcomeMsg)
+	logMessageEvent(m, &LogEvent{Category: LogCategoryWelcome, Action: "wautodelete", Duration: fmt.Sprintf("%d seconds", value)})
 	m.Reply(fmt.Sprintf("Welcome messages will be auto-deleted after <b>%d seconds</b>", value))
 	return nil
 }